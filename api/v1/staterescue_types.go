@@ -30,8 +30,192 @@ type StateRescueSpec struct {
 	// is determined from terraform Kubernetes backend configurations (secret_suffix)
 	// +required
 	StateSecretName string `json:"stateSecretName,omitempty"`
+
+	// specifies what happens to the backup secrets once the StateRescue resource is deleted
+	// Retain keeps the backups and labels them as orphaned so that a new StateRescue resource can adopt them,
+	// Delete removes the backups along with the StateRescue resource (default),
+	// Orphan keeps the backups without any reference to the StateRescue resource,
+	// the manager-level default applies if not set
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// runs the backup and rescue logic without applying any changes to the secrets,
+	// the actions that would be taken are only recorded as events and in the status
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// records a summary of the resource level changes against the previous backup
+	// in an annotation on the backup secret whenever the backup is updated
+	// +optional
+	DiffSummary bool `json:"diffSummary,omitempty"`
+
+	// specifies destinations that backups are replicated to in addition to the local backup secrets
+	// +optional
+	Destination *BackupDestination `json:"destination,omitempty"`
+
+	// number of immutable snapshots of each state secret that are kept in addition to its backup secret,
+	// a snapshot is written whenever the state changes and the oldest snapshots are deleted, 0 disables snapshots
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Generations int32 `json:"generations,omitempty"`
+
+	// signs backups and replicas so that modified snapshots are detected and never used to rescue or restore states
+	// +optional
+	Signing *SigningOptions `json:"signing,omitempty"`
+
+	// specifies what happens if terraform re-initialises a state secret with a new lineage, e.g. after it was
+	// deleted and terraform ran before the controller could rescue it, the backup is never overwritten in that case
+	// Hold keeps the new state and holds the backup of the previous lineage (default),
+	// Restore overwrites the new state with the backup of the previous lineage
+	// +optional
+	ReinitializationPolicy ReinitializationPolicy `json:"reinitializationPolicy,omitempty"`
+
+	// protects backups against states that suddenly lose most of their resources, e.g. after an accidental destroy
+	// +optional
+	Protection *ProtectionOptions `json:"protection,omitempty"`
+
+	// keeps the snapshots selected by the holds indefinitely, e.g. for a legal hold. Held snapshots are never
+	// pruned, are retained when the StateRescue resource is deleted regardless of the deletion policy
+	// and are copied to every backup destination
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Holds []SnapshotHold `json:"holds,omitempty"`
+}
+
+// BackupDestination describes where backups are replicated to
+type BackupDestination struct {
+	// replicates backups to another Kubernetes cluster, e.g. a standby cluster for disaster recovery
+	// +optional
+	RemoteCluster *RemoteClusterDestination `json:"remoteCluster,omitempty"`
 }
 
+// RemoteClusterDestination describes a remote Kubernetes cluster that backups are replicated to
+type RemoteClusterDestination struct {
+	// reference to a secret in the namespace of the StateRescue resource containing the kubeconfig of the remote cluster
+	// +required
+	KubeconfigSecretRef SecretKeyReference `json:"kubeconfigSecretRef"`
+	// namespace in the remote cluster that backups are written to, defaults to the namespace of the StateRescue resource
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// replaces sensitive values in the replicated states, e.g. for less trusted clusters,
+	// redacted replicas are marked as such and are never used to rescue or restore states
+	// +optional
+	Redact *RedactOptions `json:"redact,omitempty"`
+}
+
+// RedactOptions describes which values are replaced in redacted states, the values of outputs and attributes
+// that terraform marks as sensitive and the private data of resource instances are always replaced
+type RedactOptions struct {
+	// glob patterns of attribute paths and output names whose values are replaced as well, e.g. *password* or tags.*
+	// +optional
+	AttributePatterns []string `json:"attributePatterns,omitempty"`
+}
+
+// DefaultKubeconfigKey is the key of the kubeconfig in the kubeconfig secret of a remote cluster if none is specified
+const DefaultKubeconfigKey = "kubeconfig"
+
+// SecretKeyReference refers to a key of a secret in the namespace of the StateRescue resource
+type SecretKeyReference struct {
+	// name of the secret
+	// +required
+	Name string `json:"name"`
+	// key of the secret data
+	// +kubebuilder:default=kubeconfig
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// SnapshotTagLabelPrefix is the prefix of the labels that tag snapshots taken on request, the tag is the
+// name of the label, e.g. tag.terraform.hammadzf.github.io/pre-upgrade, so that a snapshot can carry several tags.
+// Tagged snapshots are kept beyond the number of kept generations.
+const SnapshotTagLabelPrefix = "tag.terraform.hammadzf.github.io/"
+
+// SnapshotHold keeps the snapshots it selects indefinitely, e.g. for a legal hold
+type SnapshotHold struct {
+	// name of the hold
+	// +required
+	Name string `json:"name"`
+	// holds the snapshots of the states with this serial, exactly one of serial and tag must be set
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Serial *int64 `json:"serial,omitempty"`
+	// holds the snapshots with this tag, exactly one of serial and tag must be set
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// why the snapshots are held, e.g. the number of an audit case
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// ProtectionOptions describes when a state is quarantined because it lost too many resources
+type ProtectionOptions struct {
+	// percentage of the resource instances of the backed up state that a new state may lose before it is quarantined,
+	// the snapshot of the previous state is then pinned so that it is never pruned
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +required
+	MaxResourceDropPercent int32 `json:"maxResourceDropPercent"`
+	// keeps the backup of the previous state until the resource drop of a quarantined state is acknowledged,
+	// otherwise the backup is updated once the previous state is pinned
+	// +optional
+	RequireAcknowledgement bool `json:"requireAcknowledgement,omitempty"`
+}
+
+// SigningAlgorithm is the algorithm used to sign backups
+// +kubebuilder:validation:Enum=HMAC-SHA256;Ed25519
+type SigningAlgorithm string
+
+const (
+	// SigningHMACSHA256 signs backups with an HMAC-SHA256 of a shared key
+	SigningHMACSHA256 SigningAlgorithm = "HMAC-SHA256"
+	// SigningEd25519 signs backups with an Ed25519 private key
+	SigningEd25519 SigningAlgorithm = "Ed25519"
+)
+
+// DefaultSigningKey is the key of the signing key in the signing key secret if none is specified
+const DefaultSigningKey = "key"
+
+// SigningOptions refers to the key that backups and replicas are signed with
+type SigningOptions struct {
+	// algorithm used to sign the backups
+	// +kubebuilder:default=HMAC-SHA256
+	// +optional
+	Algorithm SigningAlgorithm `json:"algorithm,omitempty"`
+	// name of the secret in the namespace of the StateRescue resource that holds the signing key
+	// +required
+	KeySecretName string `json:"keySecretName"`
+	// key of the secret data holding the signing key, the shared key for HMAC-SHA256
+	// or a PEM encoded PKCS #8 private key for Ed25519
+	// +kubebuilder:default=key
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// DeletionPolicy describes how backup secrets are handled when a StateRescue resource is deleted
+// +kubebuilder:validation:Enum=Retain;Delete;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps backup secrets and labels them as orphaned for later adoption
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete deletes backup secrets along with the StateRescue resource
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps backup secrets without any owner reference or orphan label
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// ReinitializationPolicy describes how state secrets that terraform re-initialised with a new lineage are handled
+// +kubebuilder:validation:Enum=Hold;Restore
+type ReinitializationPolicy string
+
+const (
+	// ReinitializationPolicyHold keeps the re-initialised state and holds the backup of the previous lineage
+	ReinitializationPolicyHold ReinitializationPolicy = "Hold"
+	// ReinitializationPolicyRestore overwrites the re-initialised state with the backup of the previous lineage
+	ReinitializationPolicyRestore ReinitializationPolicy = "Restore"
+)
+
 // StateRescueStatus defines the observed state of StateRescue.
 type StateRescueStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -42,10 +226,157 @@ type StateRescueStatus struct {
 	// time when the state files were last rescued from backup
 	// +optional
 	LastRescueTime metav1.Time `json:"lastRescueTime,omitempty"`
+	// actions that the controller would take on the secrets when running in dry-run mode
+	// +optional
+	PlannedActions []PlannedAction `json:"plannedActions,omitempty"`
+	// time when the backups were last verified against their checksums
+	// +optional
+	LastVerificationTime metav1.Time `json:"lastVerificationTime,omitempty"`
+	// status of the replication of backups to a remote cluster
+	// +optional
+	RemoteReplication *RemoteReplicationStatus `json:"remoteReplication,omitempty"`
+	// changes of the state secrets and their backups recorded by the audit webhook, from the oldest to the newest,
+	// only the most recent changes are kept
+	// +listType=atomic
+	// +optional
+	SecretHistory []SecretChange `json:"secretHistory,omitempty"`
+	// the last handled request for tagged snapshots of the state secrets
+	// +optional
+	LastSnapshotRequest *SnapshotRequest `json:"lastSnapshotRequest,omitempty"`
+	// snapshots that are kept indefinitely because of holds
+	// +listType=atomic
+	// +optional
+	HeldSnapshots []HeldSnapshot `json:"heldSnapshots,omitempty"`
+	// conditions represent the latest available observations of the StateRescue resource
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SecretChange describes who changed or deleted a state secret or its backup
+type SecretChange struct {
+	// name of the changed secret
+	// +required
+	Secret string `json:"secret"`
+	// operation of the change, UPDATE or DELETE
+	// +required
+	Operation string `json:"operation"`
+	// name of the user or service account that made the change
+	// +required
+	User string `json:"user"`
+	// groups of the user that made the change
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+	// time when the change was admitted
+	// +required
+	Time metav1.Time `json:"time"`
+}
+
+// SnapshotRequest describes a handled request for tagged snapshots of the state secrets
+type SnapshotRequest struct {
+	// tag of the requested snapshots
+	// +required
+	Tag string `json:"tag"`
+	// time when the request was handled
+	// +required
+	Time metav1.Time `json:"time"`
+	// names of the tagged snapshots
+	// +listType=atomic
+	// +optional
+	Snapshots []string `json:"snapshots,omitempty"`
+}
+
+// HeldSnapshot describes a snapshot that is kept indefinitely because of holds
+type HeldSnapshot struct {
+	// name of the snapshot
+	// +required
+	Snapshot string `json:"snapshot"`
+	// name of the state secret that the snapshot was taken of
+	// +required
+	Secret string `json:"secret"`
+	// names of the holds of the snapshot, annotation for a hold set with the hold annotation on the snapshot
+	// +listType=atomic
+	// +required
+	Holds []string `json:"holds"`
+}
+
+// RemoteReplicationStatus describes the replication of backups to a remote cluster
+type RemoteReplicationStatus struct {
+	// time when backups were last replicated to the remote cluster
+	// +optional
+	LastReplicationTime metav1.Time `json:"lastReplicationTime,omitempty"`
+	// time by which the replicated backups lag behind the local backups
+	// +optional
+	Lag metav1.Duration `json:"lag,omitempty"`
+}
+
+const (
+	// ConditionReplicated indicates whether backups are replicated to the remote cluster
+	ConditionReplicated = "Replicated"
+	// ConditionBackupVerified indicates whether all backups could be read and match their checksums
+	ConditionBackupVerified = "BackupVerified"
+	// ConditionBackupTampered indicates whether a backup or replica does not match its signature
+	ConditionBackupTampered = "BackupTampered"
+	// ConditionSigningKeyAvailable indicates whether the signing key could be loaded, backups are written unsigned
+	// and never used to rescue or restore a state while it cannot
+	ConditionSigningKeyAvailable = "SigningKeyAvailable"
+	// ConditionStateReinitialized indicates whether a state secret was re-initialised with a new lineage
+	// and its backup of the previous lineage is held
+	ConditionStateReinitialized = "StateReinitialized"
+	// ConditionStateQuarantined indicates whether a state lost more resources than allowed
+	// and its backup is kept until the resource drop is acknowledged
+	ConditionStateQuarantined = "StateQuarantined"
+	// ConditionRescueFlapping indicates whether a state secret is no longer rescued because
+	// it was rescued too often within a short time, e.g. because another system keeps deleting it
+	ConditionRescueFlapping = "RescueFlapping"
+)
+
+// ActionType describes an action taken by the controller on a secret
+type ActionType string
+
+const (
+	// ActionCreateBackup creates a backup secret for an original secret
+	ActionCreateBackup ActionType = "CreateBackup"
+	// ActionUpdateBackup copies the data of an original secret to its backup secret
+	ActionUpdateBackup ActionType = "UpdateBackup"
+	// ActionRescue recreates a deleted original secret from its backup secret
+	ActionRescue ActionType = "Rescue"
+	// ActionRestore overwrites an original secret with the data of its backup secret on request
+	ActionRestore ActionType = "Restore"
+	// ActionAdoptBackup takes over an orphaned backup secret
+	ActionAdoptBackup ActionType = "AdoptBackup"
+	// ActionReplicate copies the data of an original secret to its replica in a remote cluster
+	ActionReplicate ActionType = "Replicate"
+	// ActionRescueFromRemote recreates a deleted original secret from its replica in a remote cluster
+	ActionRescueFromRemote ActionType = "RescueFromRemote"
+	// ActionCreateSnapshot writes an immutable snapshot of the data of an original secret
+	ActionCreateSnapshot ActionType = "CreateSnapshot"
+	// ActionPruneSnapshot deletes a snapshot that exceeds the number of kept generations
+	ActionPruneSnapshot ActionType = "PruneSnapshot"
+	// ActionPinSnapshot pins the snapshot of a state so that it is never pruned
+	ActionPinSnapshot ActionType = "PinSnapshot"
+	// ActionTagSnapshot tags the snapshot of a state on request so that it is never pruned
+	ActionTagSnapshot ActionType = "TagSnapshot"
+	// ActionHoldSnapshot marks a snapshot selected by a hold so that it is kept indefinitely
+	ActionHoldSnapshot ActionType = "HoldSnapshot"
+	// ActionReleaseSnapshot unmarks a snapshot that is no longer selected by any hold
+	ActionReleaseSnapshot ActionType = "ReleaseSnapshot"
+)
+
+// PlannedAction is an action that the controller would take on a secret in dry-run mode
+type PlannedAction struct {
+	// type of the action
+	// +required
+	Action ActionType `json:"action"`
+	// name of the secret that the action would be applied to
+	// +required
+	Secret string `json:"secret"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 // StateRescue is the Schema for the staterescues API
 type StateRescue struct {
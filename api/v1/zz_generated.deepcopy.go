@@ -21,15 +21,236 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupDestination) DeepCopyInto(out *BackupDestination) {
+	*out = *in
+	if in.RemoteCluster != nil {
+		in, out := &in.RemoteCluster, &out.RemoteCluster
+		*out = new(RemoteClusterDestination)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupDestination.
+func (in *BackupDestination) DeepCopy() *BackupDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeldSnapshot) DeepCopyInto(out *HeldSnapshot) {
+	*out = *in
+	if in.Holds != nil {
+		in, out := &in.Holds, &out.Holds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeldSnapshot.
+func (in *HeldSnapshot) DeepCopy() *HeldSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(HeldSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlannedAction) DeepCopyInto(out *PlannedAction) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlannedAction.
+func (in *PlannedAction) DeepCopy() *PlannedAction {
+	if in == nil {
+		return nil
+	}
+	out := new(PlannedAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProtectionOptions) DeepCopyInto(out *ProtectionOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProtectionOptions.
+func (in *ProtectionOptions) DeepCopy() *ProtectionOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(ProtectionOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedactOptions) DeepCopyInto(out *RedactOptions) {
+	*out = *in
+	if in.AttributePatterns != nil {
+		in, out := &in.AttributePatterns, &out.AttributePatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedactOptions.
+func (in *RedactOptions) DeepCopy() *RedactOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(RedactOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteClusterDestination) DeepCopyInto(out *RemoteClusterDestination) {
+	*out = *in
+	out.KubeconfigSecretRef = in.KubeconfigSecretRef
+	if in.Redact != nil {
+		in, out := &in.Redact, &out.Redact
+		*out = new(RedactOptions)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteClusterDestination.
+func (in *RemoteClusterDestination) DeepCopy() *RemoteClusterDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteClusterDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteReplicationStatus) DeepCopyInto(out *RemoteReplicationStatus) {
+	*out = *in
+	in.LastReplicationTime.DeepCopyInto(&out.LastReplicationTime)
+	out.Lag = in.Lag
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteReplicationStatus.
+func (in *RemoteReplicationStatus) DeepCopy() *RemoteReplicationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteReplicationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretChange) DeepCopyInto(out *SecretChange) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretChange.
+func (in *SecretChange) DeepCopy() *SecretChange {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyReference) DeepCopyInto(out *SecretKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyReference.
+func (in *SecretKeyReference) DeepCopy() *SecretKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SigningOptions) DeepCopyInto(out *SigningOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SigningOptions.
+func (in *SigningOptions) DeepCopy() *SigningOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(SigningOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotHold) DeepCopyInto(out *SnapshotHold) {
+	*out = *in
+	if in.Serial != nil {
+		in, out := &in.Serial, &out.Serial
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotHold.
+func (in *SnapshotHold) DeepCopy() *SnapshotHold {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotHold)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SnapshotRequest) DeepCopyInto(out *SnapshotRequest) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	if in.Snapshots != nil {
+		in, out := &in.Snapshots, &out.Snapshots
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SnapshotRequest.
+func (in *SnapshotRequest) DeepCopy() *SnapshotRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(SnapshotRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StateRescue) DeepCopyInto(out *StateRescue) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -86,6 +307,28 @@ func (in *StateRescueList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StateRescueSpec) DeepCopyInto(out *StateRescueSpec) {
 	*out = *in
+	if in.Destination != nil {
+		in, out := &in.Destination, &out.Destination
+		*out = new(BackupDestination)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Signing != nil {
+		in, out := &in.Signing, &out.Signing
+		*out = new(SigningOptions)
+		**out = **in
+	}
+	if in.Protection != nil {
+		in, out := &in.Protection, &out.Protection
+		*out = new(ProtectionOptions)
+		**out = **in
+	}
+	if in.Holds != nil {
+		in, out := &in.Holds, &out.Holds
+		*out = make([]SnapshotHold, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateRescueSpec.
@@ -103,6 +346,43 @@ func (in *StateRescueStatus) DeepCopyInto(out *StateRescueStatus) {
 	*out = *in
 	in.LastBackupTime.DeepCopyInto(&out.LastBackupTime)
 	in.LastRescueTime.DeepCopyInto(&out.LastRescueTime)
+	if in.PlannedActions != nil {
+		in, out := &in.PlannedActions, &out.PlannedActions
+		*out = make([]PlannedAction, len(*in))
+		copy(*out, *in)
+	}
+	in.LastVerificationTime.DeepCopyInto(&out.LastVerificationTime)
+	if in.RemoteReplication != nil {
+		in, out := &in.RemoteReplication, &out.RemoteReplication
+		*out = new(RemoteReplicationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretHistory != nil {
+		in, out := &in.SecretHistory, &out.SecretHistory
+		*out = make([]SecretChange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastSnapshotRequest != nil {
+		in, out := &in.LastSnapshotRequest, &out.LastSnapshotRequest
+		*out = new(SnapshotRequest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HeldSnapshots != nil {
+		in, out := &in.HeldSnapshots, &out.HeldSnapshots
+		*out = make([]HeldSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StateRescueStatus.
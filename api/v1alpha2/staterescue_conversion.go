@@ -0,0 +1,199 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+// ConvertTo converts this StateRescue to the hub version v1
+func (src *StateRescue) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*terraformv1.StateRescue)
+	if !ok {
+		return fmt.Errorf("unsupported hub type %T", dstRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+
+	// the targets are limited to a single one, so that every v1alpha2 object has a lossless v1 representation
+	if len(src.Spec.Targets) > 1 {
+		return fmt.Errorf("StateRescue %s/%s has %d targets, only a single target is supported", src.Namespace, src.Name, len(src.Spec.Targets))
+	}
+	dst.Spec = terraformv1.StateRescueSpec{}
+	if len(src.Spec.Targets) == 1 {
+		dst.Spec.StateSecretName = src.Spec.Targets[0].SecretName
+	}
+	dst.Spec.DeletionPolicy = terraformv1.DeletionPolicy(src.Spec.Backup.DeletionPolicy)
+	dst.Spec.Generations = src.Spec.Backup.Generations
+	dst.Spec.DiffSummary = src.Spec.Backup.DiffSummary
+	for _, hold := range src.Spec.Backup.Holds {
+		dst.Spec.Holds = append(dst.Spec.Holds, terraformv1.SnapshotHold(hold))
+	}
+	if signing := src.Spec.Backup.Signing; signing != nil {
+		dst.Spec.Signing = &terraformv1.SigningOptions{
+			Algorithm:     terraformv1.SigningAlgorithm(signing.Algorithm),
+			KeySecretName: signing.KeySecretName,
+			Key:           signing.Key,
+		}
+	}
+	dst.Spec.DryRun = src.Spec.Rescue.Mode == RescueModeDryRun
+	dst.Spec.ReinitializationPolicy = terraformv1.ReinitializationPolicy(src.Spec.Rescue.ReinitializationPolicy)
+	if protection := src.Spec.Protection; protection != nil {
+		dst.Spec.Protection = &terraformv1.ProtectionOptions{
+			MaxResourceDropPercent: protection.MaxResourceDropPercent,
+			RequireAcknowledgement: protection.RequireAcknowledgement,
+		}
+	}
+	if destination := src.Spec.Destination; destination != nil {
+		dst.Spec.Destination = &terraformv1.BackupDestination{}
+		if remote := destination.RemoteCluster; remote != nil {
+			dst.Spec.Destination.RemoteCluster = &terraformv1.RemoteClusterDestination{
+				KubeconfigSecretRef: terraformv1.SecretKeyReference(remote.KubeconfigSecretRef),
+				Namespace:           remote.Namespace,
+			}
+			if remote.Redact != nil {
+				dst.Spec.Destination.RemoteCluster.Redact = &terraformv1.RedactOptions{
+					AttributePatterns: remote.Redact.AttributePatterns,
+				}
+			}
+		}
+	}
+
+	dst.Status = terraformv1.StateRescueStatus{
+		LastBackupTime:       src.Status.LastBackupTime,
+		LastRescueTime:       src.Status.LastRescueTime,
+		LastVerificationTime: src.Status.LastVerificationTime,
+		Conditions:           src.Status.Conditions,
+	}
+	for _, change := range src.Status.SecretHistory {
+		dst.Status.SecretHistory = append(dst.Status.SecretHistory, terraformv1.SecretChange(change))
+	}
+	for _, held := range src.Status.HeldSnapshots {
+		dst.Status.HeldSnapshots = append(dst.Status.HeldSnapshots, terraformv1.HeldSnapshot(held))
+	}
+	for _, action := range src.Status.PlannedActions {
+		dst.Status.PlannedActions = append(dst.Status.PlannedActions, terraformv1.PlannedAction{
+			Action: terraformv1.ActionType(action.Action),
+			Secret: action.Secret,
+		})
+	}
+	if replication := src.Status.RemoteReplication; replication != nil {
+		dst.Status.RemoteReplication = &terraformv1.RemoteReplicationStatus{
+			LastReplicationTime: replication.LastReplicationTime,
+			Lag:                 replication.Lag,
+		}
+	}
+	if request := src.Status.LastSnapshotRequest; request != nil {
+		dst.Status.LastSnapshotRequest = &terraformv1.SnapshotRequest{
+			Tag:       request.Tag,
+			Time:      request.Time,
+			Snapshots: request.Snapshots,
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts the hub version v1 to this StateRescue
+func (dst *StateRescue) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*terraformv1.StateRescue)
+	if !ok {
+		return fmt.Errorf("unsupported hub type %T", srcRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec = StateRescueSpec{
+		Targets: []Target{{SecretName: src.Spec.StateSecretName}},
+		Backup: BackupSpec{
+			DeletionPolicy: DeletionPolicy(src.Spec.DeletionPolicy),
+			Generations:    src.Spec.Generations,
+			DiffSummary:    src.Spec.DiffSummary,
+		},
+		Rescue: RescueSpec{
+			Mode:                   RescueModeAuto,
+			ReinitializationPolicy: ReinitializationPolicy(src.Spec.ReinitializationPolicy),
+		},
+	}
+	if signing := src.Spec.Signing; signing != nil {
+		dst.Spec.Backup.Signing = &SigningOptions{
+			Algorithm:     SigningAlgorithm(signing.Algorithm),
+			KeySecretName: signing.KeySecretName,
+			Key:           signing.Key,
+		}
+	}
+	for _, hold := range src.Spec.Holds {
+		dst.Spec.Backup.Holds = append(dst.Spec.Backup.Holds, SnapshotHold(hold))
+	}
+	if src.Spec.DryRun {
+		dst.Spec.Rescue.Mode = RescueModeDryRun
+	}
+	if protection := src.Spec.Protection; protection != nil {
+		dst.Spec.Protection = &ProtectionOptions{
+			MaxResourceDropPercent: protection.MaxResourceDropPercent,
+			RequireAcknowledgement: protection.RequireAcknowledgement,
+		}
+	}
+	if destination := src.Spec.Destination; destination != nil {
+		dst.Spec.Destination = &BackupDestination{}
+		if remote := destination.RemoteCluster; remote != nil {
+			dst.Spec.Destination.RemoteCluster = &RemoteClusterDestination{
+				KubeconfigSecretRef: SecretKeyReference(remote.KubeconfigSecretRef),
+				Namespace:           remote.Namespace,
+			}
+			if remote.Redact != nil {
+				dst.Spec.Destination.RemoteCluster.Redact = &RedactOptions{
+					AttributePatterns: remote.Redact.AttributePatterns,
+				}
+			}
+		}
+	}
+
+	dst.Status = StateRescueStatus{
+		LastBackupTime:       src.Status.LastBackupTime,
+		LastRescueTime:       src.Status.LastRescueTime,
+		LastVerificationTime: src.Status.LastVerificationTime,
+		Conditions:           src.Status.Conditions,
+	}
+	for _, change := range src.Status.SecretHistory {
+		dst.Status.SecretHistory = append(dst.Status.SecretHistory, SecretChange(change))
+	}
+	for _, held := range src.Status.HeldSnapshots {
+		dst.Status.HeldSnapshots = append(dst.Status.HeldSnapshots, HeldSnapshot(held))
+	}
+	for _, action := range src.Status.PlannedActions {
+		dst.Status.PlannedActions = append(dst.Status.PlannedActions, PlannedAction{
+			Action: string(action.Action),
+			Secret: action.Secret,
+		})
+	}
+	if replication := src.Status.RemoteReplication; replication != nil {
+		dst.Status.RemoteReplication = &RemoteReplicationStatus{
+			LastReplicationTime: replication.LastReplicationTime,
+			Lag:                 replication.Lag,
+		}
+	}
+	if request := src.Status.LastSnapshotRequest; request != nil {
+		dst.Status.LastSnapshotRequest = &SnapshotRequest{
+			Tag:       request.Tag,
+			Time:      request.Time,
+			Snapshots: request.Snapshots,
+		}
+	}
+	return nil
+}
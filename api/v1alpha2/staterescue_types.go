@@ -0,0 +1,352 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StateRescueSpec defines the desired state of StateRescue
+type StateRescueSpec struct {
+	// specifies the terraform state secrets that are backed up and rescued,
+	// a single target is supported until StateRescue resources can cover several targets
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=1
+	// +listType=atomic
+	// +required
+	Targets []Target `json:"targets"`
+
+	// specifies how the backups of the targets are written and kept
+	// +optional
+	Backup BackupSpec `json:"backup,omitempty,omitzero"`
+
+	// specifies how the targets are rescued from their backups
+	// +optional
+	Rescue RescueSpec `json:"rescue,omitempty,omitzero"`
+
+	// specifies destinations that backups are replicated to in addition to the local backup secrets
+	// +optional
+	Destination *BackupDestination `json:"destination,omitempty"`
+
+	// protects backups against states that suddenly lose most of their resources, e.g. after an accidental destroy
+	// +optional
+	Protection *ProtectionOptions `json:"protection,omitempty"`
+}
+
+// Target refers to the secrets containing terraform state files
+type Target struct {
+	// name of the secret object containing terraform state file, all secrets whose name starts with it are covered,
+	// is determined from terraform Kubernetes backend configurations (tfstate-{workspace}-{secret_suffix})
+	// +required
+	SecretName string `json:"secretName"`
+}
+
+// BackupSpec describes how the backups of the targets are written and kept
+type BackupSpec struct {
+	// specifies what happens to the backup secrets once the StateRescue resource is deleted
+	// Retain keeps the backups and labels them as orphaned so that a new StateRescue resource can adopt them,
+	// Delete removes the backups along with the StateRescue resource,
+	// Orphan keeps the backups without any reference to the StateRescue resource,
+	// the manager-level default applies if not set
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// number of immutable snapshots of each state secret that are kept in addition to its backup secret,
+	// a snapshot is written whenever the state changes and the oldest snapshots are deleted, 0 disables snapshots
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Generations int32 `json:"generations,omitempty"`
+
+	// records a summary of the resource level changes against the previous backup
+	// in an annotation on the backup secret whenever the backup is updated
+	// +optional
+	DiffSummary bool `json:"diffSummary,omitempty"`
+
+	// signs backups and replicas so that modified snapshots are detected and never used to rescue or restore states
+	// +optional
+	Signing *SigningOptions `json:"signing,omitempty"`
+
+	// keeps the snapshots selected by the holds indefinitely, e.g. for a legal hold. Held snapshots are never
+	// pruned, are retained when the StateRescue resource is deleted regardless of the deletion policy
+	// and are copied to every backup destination
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Holds []SnapshotHold `json:"holds,omitempty"`
+}
+
+// SnapshotHold keeps the snapshots it selects indefinitely, e.g. for a legal hold
+type SnapshotHold struct {
+	// name of the hold
+	// +required
+	Name string `json:"name"`
+	// holds the snapshots of the states with this serial, exactly one of serial and tag must be set
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Serial *int64 `json:"serial,omitempty"`
+	// holds the snapshots with this tag, exactly one of serial and tag must be set
+	// +optional
+	Tag string `json:"tag,omitempty"`
+	// why the snapshots are held, e.g. the number of an audit case
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// RescueMode describes whether the controller applies the backup and rescue actions
+// +kubebuilder:validation:Enum=Auto;DryRun
+type RescueMode string
+
+const (
+	// RescueModeAuto backs up and rescues the targets
+	RescueModeAuto RescueMode = "Auto"
+	// RescueModeDryRun only records the backup and rescue actions that would be taken
+	// as events and in the status without applying them
+	RescueModeDryRun RescueMode = "DryRun"
+)
+
+// RescueSpec describes how the targets are rescued from their backups
+type RescueSpec struct {
+	// specifies whether the backup and rescue actions are applied or only planned
+	// +kubebuilder:default=Auto
+	// +optional
+	Mode RescueMode `json:"mode,omitempty"`
+
+	// specifies what happens if terraform re-initialises a state secret with a new lineage, e.g. after it was
+	// deleted and terraform ran before the controller could rescue it, the backup is never overwritten in that case
+	// Hold keeps the new state and holds the backup of the previous lineage (default),
+	// Restore overwrites the new state with the backup of the previous lineage
+	// +optional
+	ReinitializationPolicy ReinitializationPolicy `json:"reinitializationPolicy,omitempty"`
+}
+
+// BackupDestination describes where backups are replicated to
+type BackupDestination struct {
+	// replicates backups to another Kubernetes cluster, e.g. a standby cluster for disaster recovery
+	// +optional
+	RemoteCluster *RemoteClusterDestination `json:"remoteCluster,omitempty"`
+}
+
+// RemoteClusterDestination describes a remote Kubernetes cluster that backups are replicated to
+type RemoteClusterDestination struct {
+	// reference to a secret in the namespace of the StateRescue resource containing the kubeconfig of the remote cluster
+	// +required
+	KubeconfigSecretRef SecretKeyReference `json:"kubeconfigSecretRef"`
+	// namespace in the remote cluster that backups are written to, defaults to the namespace of the StateRescue resource
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// replaces sensitive values in the replicated states, e.g. for less trusted clusters,
+	// redacted replicas are marked as such and are never used to rescue or restore states
+	// +optional
+	Redact *RedactOptions `json:"redact,omitempty"`
+}
+
+// RedactOptions describes which values are replaced in redacted states, the values of outputs and attributes
+// that terraform marks as sensitive and the private data of resource instances are always replaced
+type RedactOptions struct {
+	// glob patterns of attribute paths and output names whose values are replaced as well, e.g. *password* or tags.*
+	// +optional
+	AttributePatterns []string `json:"attributePatterns,omitempty"`
+}
+
+// SecretKeyReference refers to a key of a secret in the namespace of the StateRescue resource
+type SecretKeyReference struct {
+	// name of the secret
+	// +required
+	Name string `json:"name"`
+	// key of the secret data
+	// +kubebuilder:default=kubeconfig
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ProtectionOptions describes when a state is quarantined because it lost too many resources
+type ProtectionOptions struct {
+	// percentage of the resource instances of the backed up state that a new state may lose before it is quarantined,
+	// the snapshot of the previous state is then pinned so that it is never pruned
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +required
+	MaxResourceDropPercent int32 `json:"maxResourceDropPercent"`
+	// keeps the backup of the previous state until the resource drop of a quarantined state is acknowledged,
+	// otherwise the backup is updated once the previous state is pinned
+	// +optional
+	RequireAcknowledgement bool `json:"requireAcknowledgement,omitempty"`
+}
+
+// SigningAlgorithm is the algorithm used to sign backups
+// +kubebuilder:validation:Enum=HMAC-SHA256;Ed25519
+type SigningAlgorithm string
+
+// SigningOptions refers to the key that backups and replicas are signed with
+type SigningOptions struct {
+	// algorithm used to sign the backups
+	// +kubebuilder:default=HMAC-SHA256
+	// +optional
+	Algorithm SigningAlgorithm `json:"algorithm,omitempty"`
+	// name of the secret in the namespace of the StateRescue resource that holds the signing key
+	// +required
+	KeySecretName string `json:"keySecretName"`
+	// key of the secret data holding the signing key, the shared key for HMAC-SHA256
+	// or a PEM encoded PKCS #8 private key for Ed25519
+	// +kubebuilder:default=key
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ReinitializationPolicy describes how state secrets that terraform re-initialised with a new lineage are handled
+// +kubebuilder:validation:Enum=Hold;Restore
+type ReinitializationPolicy string
+
+// DeletionPolicy describes how backup secrets are handled when a StateRescue resource is deleted
+// +kubebuilder:validation:Enum=Retain;Delete;Orphan
+type DeletionPolicy string
+
+// StateRescueStatus defines the observed state of StateRescue.
+type StateRescueStatus struct {
+	// time when the state file secrets were last backed up
+	// +optional
+	LastBackupTime metav1.Time `json:"lastBackupTime,omitempty"`
+	// time when the state files were last rescued from backup
+	// +optional
+	LastRescueTime metav1.Time `json:"lastRescueTime,omitempty"`
+	// actions that the controller would take on the secrets when running in dry-run mode
+	// +optional
+	PlannedActions []PlannedAction `json:"plannedActions,omitempty"`
+	// time when the backups were last verified against their checksums
+	// +optional
+	LastVerificationTime metav1.Time `json:"lastVerificationTime,omitempty"`
+	// status of the replication of backups to a remote cluster
+	// +optional
+	RemoteReplication *RemoteReplicationStatus `json:"remoteReplication,omitempty"`
+	// changes of the state secrets and their backups recorded by the audit webhook, from the oldest to the newest,
+	// only the most recent changes are kept
+	// +listType=atomic
+	// +optional
+	SecretHistory []SecretChange `json:"secretHistory,omitempty"`
+	// the last handled request for tagged snapshots of the state secrets
+	// +optional
+	LastSnapshotRequest *SnapshotRequest `json:"lastSnapshotRequest,omitempty"`
+	// snapshots that are kept indefinitely because of holds
+	// +listType=atomic
+	// +optional
+	HeldSnapshots []HeldSnapshot `json:"heldSnapshots,omitempty"`
+	// conditions represent the latest available observations of the StateRescue resource
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// SecretChange describes who changed or deleted a state secret or its backup
+type SecretChange struct {
+	// name of the changed secret
+	// +required
+	Secret string `json:"secret"`
+	// operation of the change, UPDATE or DELETE
+	// +required
+	Operation string `json:"operation"`
+	// name of the user or service account that made the change
+	// +required
+	User string `json:"user"`
+	// groups of the user that made the change
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+	// time when the change was admitted
+	// +required
+	Time metav1.Time `json:"time"`
+}
+
+// SnapshotRequest describes a handled request for tagged snapshots of the state secrets
+type SnapshotRequest struct {
+	// tag of the requested snapshots
+	// +required
+	Tag string `json:"tag"`
+	// time when the request was handled
+	// +required
+	Time metav1.Time `json:"time"`
+	// names of the tagged snapshots
+	// +listType=atomic
+	// +optional
+	Snapshots []string `json:"snapshots,omitempty"`
+}
+
+// HeldSnapshot describes a snapshot that is kept indefinitely because of holds
+type HeldSnapshot struct {
+	// name of the snapshot
+	// +required
+	Snapshot string `json:"snapshot"`
+	// name of the state secret that the snapshot was taken of
+	// +required
+	Secret string `json:"secret"`
+	// names of the holds of the snapshot, annotation for a hold set with the hold annotation on the snapshot
+	// +listType=atomic
+	// +required
+	Holds []string `json:"holds"`
+}
+
+// RemoteReplicationStatus describes the replication of backups to a remote cluster
+type RemoteReplicationStatus struct {
+	// time when backups were last replicated to the remote cluster
+	// +optional
+	LastReplicationTime metav1.Time `json:"lastReplicationTime,omitempty"`
+	// time by which the replicated backups lag behind the local backups
+	// +optional
+	Lag metav1.Duration `json:"lag,omitempty"`
+}
+
+// PlannedAction is an action that the controller would take on a secret
+type PlannedAction struct {
+	// type of the action
+	// +required
+	Action string `json:"action"`
+	// name of the secret that the action would be applied to
+	// +required
+	Secret string `json:"secret"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// StateRescue is the Schema for the staterescues API
+type StateRescue struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of StateRescue
+	// +required
+	Spec StateRescueSpec `json:"spec"`
+
+	// status defines the observed state of StateRescue
+	// +optional
+	Status StateRescueStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// StateRescueList contains a list of StateRescue
+type StateRescueList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []StateRescue `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&StateRescue{}, &StateRescueList{})
+}
@@ -0,0 +1,444 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/controller"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+// newListCommand lists the backup secrets and snapshots with their decoded state metadata
+func newListCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List backups and snapshots of terraform state secrets",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, namespace, err := o.newClient()
+			if err != nil {
+				return err
+			}
+			listOpts := []client.ListOption{client.MatchingLabels{controller.TfStateLabelKey: controller.TfStateLabelValue}}
+			if !o.allNamespaces {
+				listOpts = append(listOpts, client.InNamespace(namespace))
+			}
+			secrets := &corev1.SecretList{}
+			if err := c.List(cmd.Context(), secrets, listOpts...); err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+			fmt.Fprintln(tw, "NAMESPACE\tNAME\tWORKSPACE\tSERIAL\tLINEAGE\tTERRAFORM\tRESOURCES\tSTATERESCUE")
+			for _, secret := range secrets.Items {
+				if !strings.HasPrefix(secret.Name, "backup-") && !strings.HasPrefix(secret.Name, "snapshot-") {
+					continue
+				}
+				serial, lineage, version, resources := "<unknown>", "<unknown>", "<unknown>", "<unknown>"
+				if s, err := state.FromSecret(&secret); err == nil {
+					serial, lineage, version = fmt.Sprint(s.Serial), s.Lineage, s.TerraformVersion
+					resources = fmt.Sprint(len(s.Addresses()))
+				}
+				owner := "<none>"
+				if ref := metav1.GetControllerOf(&secret); ref != nil {
+					owner = ref.Name
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", secret.Namespace, secret.Name,
+					state.Workspace(&secret), serial, lineage, version, resources, owner)
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "List backups and snapshots in all namespaces")
+	return cmd
+}
+
+// newShowCommand prints the decoded state metadata and resources of a state, backup or snapshot secret
+func newShowCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show SECRET",
+		Short: "Show the decoded terraform state of a state, backup or snapshot secret",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := o.newClient()
+			if err != nil {
+				return err
+			}
+			secret, s, err := getState(cmd, c, types.NamespacedName{Name: args[0], Namespace: namespace})
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			tw := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+			fmt.Fprintf(tw, "Name:\t%s\n", secret.Name)
+			fmt.Fprintf(tw, "Namespace:\t%s\n", secret.Namespace)
+			fmt.Fprintf(tw, "Workspace:\t%s\n", state.Workspace(secret))
+			fmt.Fprintf(tw, "Terraform:\t%s\n", s.TerraformVersion)
+			fmt.Fprintf(tw, "Serial:\t%d\n", s.Serial)
+			fmt.Fprintf(tw, "Lineage:\t%s\n", s.Lineage)
+			fmt.Fprintf(tw, "Redacted:\t%t\n", s.Redacted)
+			outputs := make([]string, 0, len(s.Outputs))
+			for name := range s.Outputs {
+				outputs = append(outputs, name)
+			}
+			sort.Strings(outputs)
+			fmt.Fprintf(tw, "Outputs:\t%s\n", strings.Join(outputs, ", "))
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintln(out, "Resources:")
+			for _, address := range s.Addresses() {
+				fmt.Fprintln(out, "  "+address)
+			}
+			return nil
+		},
+	}
+}
+
+// newDiffCommand compares the resources of a state secret with those of its backup or of one of its snapshots,
+// or of two state files
+func newDiffCommand(o *options) *cobra.Command {
+	var oldFile, newFile string
+	cmd := &cobra.Command{
+		Use:   "diff (SECRET | SNAPSHOT | --old FILE --new FILE)",
+		Short: "Compare the terraform state of a state secret with its backup or a snapshot, or of two files",
+		Long: "Compare the terraform state of a state secret with its backup by resource address, attribute, " +
+			"provider and output. Given a snapshot, the state secret is compared with the snapshot instead. " +
+			"With --old and --new, two secret manifests or snapshot files are compared " +
+			"without accessing a cluster. Sensitive values are masked.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var oldState, newState *state.State
+			switch {
+			case len(args) == 0 && oldFile != "" && newFile != "":
+				var err error
+				if oldState, err = readState(cmd.InOrStdin(), oldFile); err != nil {
+					return err
+				}
+				if newState, err = readState(cmd.InOrStdin(), newFile); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "--- %s (serial %d, lineage %s)\n", oldFile, oldState.Serial, oldState.Lineage)
+				fmt.Fprintf(cmd.OutOrStdout(), "+++ %s (serial %d, lineage %s)\n", newFile, newState.Serial, newState.Lineage)
+			case len(args) == 1 && oldFile == "" && newFile == "":
+				c, namespace, err := o.newClient()
+				if err != nil {
+					return err
+				}
+				name := strings.TrimPrefix(args[0], "backup-")
+				old := "backup-" + name
+				if strings.HasPrefix(args[0], "snapshot-") {
+					snapshot, err := getSnapshot(cmd, c, types.NamespacedName{Name: args[0], Namespace: namespace})
+					if err != nil {
+						return err
+					}
+					name, old = snapshot.Annotations[controller.SnapshotOfAnnotationKey], snapshot.Name
+				}
+				if _, newState, err = getState(cmd, c, types.NamespacedName{Name: name, Namespace: namespace}); err != nil {
+					return err
+				}
+				if _, oldState, err = getState(cmd, c, types.NamespacedName{Name: old, Namespace: namespace}); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "--- %s (serial %d, lineage %s)\n", old, oldState.Serial, oldState.Lineage)
+				fmt.Fprintf(cmd.OutOrStdout(), "+++ %s (serial %d, lineage %s)\n", name, newState.Serial, newState.Lineage)
+			default:
+				return fmt.Errorf("either a secret or both --old and --new must be given")
+			}
+			return state.Compare(oldState, newState).Write(cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&oldFile, "old", "", "Secret manifest or snapshot file of the old state")
+	cmd.Flags().StringVar(&newFile, "new", "", "Secret manifest or snapshot file of the new state")
+	return cmd
+}
+
+// newRestoreCommand requests the operator to restore a state secret from its backup or from one of its snapshots
+func newRestoreCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore (SECRET | SNAPSHOT)",
+		Short: "Request the operator to restore a state secret from its backup or a snapshot",
+		Long: "Request the operator to restore a state secret from its backup by annotating the StateRescue " +
+			"resource managing the secret. Given a snapshot, the state secret of the snapshot is restored from " +
+			"the snapshot by the StateRescue resource that took it. The secret itself is only written by the operator.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := o.newClient()
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(args[0], "snapshot-") {
+				return requestSnapshotRestore(cmd, c, types.NamespacedName{Name: args[0], Namespace: namespace})
+			}
+			name := strings.TrimPrefix(args[0], "backup-")
+			stateRescues := &terraformv1.StateRescueList{}
+			if err := c.List(cmd.Context(), stateRescues, client.InNamespace(namespace)); err != nil {
+				return err
+			}
+			// the controller manages all secrets whose name starts with the state secret name of a StateRescue resource,
+			// the StateRescue resource with the most specific state secret name is requested to restore the secret
+			var stateRescue *terraformv1.StateRescue
+			for i := range stateRescues.Items {
+				secretName := stateRescues.Items[i].Spec.StateSecretName
+				if strings.HasPrefix(name, secretName) &&
+					(stateRescue == nil || len(secretName) > len(stateRescue.Spec.StateSecretName)) {
+					stateRescue = &stateRescues.Items[i]
+				}
+			}
+			if stateRescue == nil {
+				return fmt.Errorf("no StateRescue resource manages secret %s in namespace %s", name, namespace)
+			}
+
+			patch := client.MergeFrom(stateRescue.DeepCopy())
+			metav1.SetMetaDataAnnotation(&stateRescue.ObjectMeta, controller.RestoreAnnotationKey, name)
+			if err := c.Patch(cmd.Context(), stateRescue, patch); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "staterescue/%s: restore of secret %s requested\n", stateRescue.Name, name)
+			return nil
+		},
+	}
+}
+
+// requestSnapshotRestore requests the StateRescue resource that took a snapshot to restore its state secret
+// from the snapshot, the controller only restores snapshots of the StateRescue resource itself
+func requestSnapshotRestore(cmd *cobra.Command, c client.Client, key types.NamespacedName) error {
+	snapshot, err := getSnapshot(cmd, c, key)
+	if err != nil {
+		return err
+	}
+	ref := metav1.GetControllerOf(snapshot)
+	if ref == nil {
+		return fmt.Errorf("no StateRescue resource manages snapshot %s in namespace %s", key.Name, key.Namespace)
+	}
+	stateRescue := &terraformv1.StateRescue{}
+	stateRescueKey := types.NamespacedName{Name: ref.Name, Namespace: key.Namespace}
+	if err := c.Get(cmd.Context(), stateRescueKey, stateRescue); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(stateRescue.DeepCopy())
+	metav1.SetMetaDataAnnotation(&stateRescue.ObjectMeta, controller.RestoreAnnotationKey, snapshot.Name)
+	if err := c.Patch(cmd.Context(), stateRescue, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "staterescue/%s: restore of secret %s from snapshot %s requested\n", stateRescue.Name,
+		snapshot.Annotations[controller.SnapshotOfAnnotationKey], snapshot.Name)
+	return nil
+}
+
+// newExportCommand writes the decoded terraform state of a state or backup secret to a file
+func newExportCommand(o *options) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "export SECRET",
+		Short: "Export the terraform state of a state or backup secret as a terraform.tfstate file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := o.newClient()
+			if err != nil {
+				return err
+			}
+			_, s, err := getState(cmd, c, types.NamespacedName{Name: args[0], Namespace: namespace})
+			if err != nil {
+				return err
+			}
+			warnRedacted(cmd, s)
+			return writeOutput(cmd.OutOrStdout(), output, s.Raw)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "File to write the state to, - for stdout")
+	return cmd
+}
+
+// newDecodeCommand decodes the terraform state of a secret manifest or snapshot file without accessing a cluster
+func newDecodeCommand() *cobra.Command {
+	var filename, output string
+	cmd := &cobra.Command{
+		Use:   "decode -f FILE",
+		Short: "Decode a backup secret manifest or snapshot file into a terraform.tfstate file",
+		Long: "Decode the terraform state of a secret manifest written by kubectl get -o yaml or -o json, " +
+			"or of a plain or gzipped snapshot file, into a terraform.tfstate file. No cluster access is needed.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			s, err := readState(cmd.InOrStdin(), filename)
+			if err != nil {
+				return err
+			}
+			warnRedacted(cmd, s)
+			return writeOutput(cmd.OutOrStdout(), output, s.Raw)
+		},
+	}
+	cmd.Flags().StringVarP(&filename, "filename", "f", "-", "Secret manifest or snapshot file to decode, - for stdin")
+	cmd.Flags().StringVarP(&output, "output", "o", "terraform.tfstate", "File to write the state to, - for stdout")
+	return cmd
+}
+
+// newPackCommand builds a state secret manifest for the Kubernetes backend from a local state file
+func newPackCommand(o *options) *cobra.Command {
+	var filename, output, workspace, suffix string
+	cmd := &cobra.Command{
+		Use:   "pack -f FILE --secret-suffix SUFFIX",
+		Short: "Build a Kubernetes backend state secret manifest from a terraform.tfstate file",
+		Long: "Build the manifest of the state secret that the Kubernetes backend of terraform reads for the given " +
+			"workspace and secret suffix from a local terraform.tfstate file. No cluster access is needed.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if suffix == "" {
+				return fmt.Errorf("--secret-suffix is required")
+			}
+			raw, err := readInput(cmd.InOrStdin(), filename)
+			if err != nil {
+				return err
+			}
+			secret, err := state.NewSecret(raw, o.namespace, workspace, suffix)
+			if err != nil {
+				return err
+			}
+			manifest, err := yaml.Marshal(secret)
+			if err != nil {
+				return err
+			}
+			return writeOutput(cmd.OutOrStdout(), output, manifest)
+		},
+	}
+	cmd.Flags().StringVarP(&filename, "filename", "f", "terraform.tfstate", "State file to pack, - for stdin")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "File to write the secret manifest to, - for stdout")
+	cmd.Flags().StringVar(&workspace, "workspace", "default", "Terraform workspace of the state")
+	cmd.Flags().StringVar(&suffix, "secret-suffix", "", "secret_suffix configured in the Kubernetes backend")
+	return cmd
+}
+
+// newStatusCommand prints the status of the StateRescue resources
+func newStatusCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of StateRescue resources",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			c, namespace, err := o.newClient()
+			if err != nil {
+				return err
+			}
+			listOpts := []client.ListOption{}
+			if !o.allNamespaces {
+				listOpts = append(listOpts, client.InNamespace(namespace))
+			}
+			stateRescues := &terraformv1.StateRescueList{}
+			if err := c.List(cmd.Context(), stateRescues, listOpts...); err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+			fmt.Fprintln(tw, "NAMESPACE\tNAME\tSECRET\tLAST BACKUP\tLAST RESCUE\tREPLICATED\tDRY RUN\tPLANNED")
+			for _, sr := range stateRescues.Items {
+				replicated := "<none>"
+				if condition := meta.FindStatusCondition(sr.Status.Conditions, terraformv1.ConditionReplicated); condition != nil {
+					replicated = string(condition.Status)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%t\t%d\n", sr.Namespace, sr.Name, sr.Spec.StateSecretName,
+					formatTime(sr.Status.LastBackupTime), formatTime(sr.Status.LastRescueTime), replicated,
+					sr.Spec.DryRun, len(sr.Status.PlannedActions))
+			}
+			return tw.Flush()
+		},
+	}
+	cmd.Flags().BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "Show StateRescue resources in all namespaces")
+	return cmd
+}
+
+// getState reads a secret and decodes the terraform state stored in it
+func getState(cmd *cobra.Command, c client.Client, key types.NamespacedName) (*corev1.Secret, *state.State, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(cmd.Context(), key, secret); err != nil {
+		return nil, nil, err
+	}
+	s, err := state.FromSecret(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+	return secret, s, nil
+}
+
+// getSnapshot reads a snapshot written by the operator
+func getSnapshot(cmd *cobra.Command, c client.Client, key types.NamespacedName) (*corev1.Secret, error) {
+	snapshot := &corev1.Secret{}
+	if err := c.Get(cmd.Context(), key, snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Labels[controller.SnapshotLabelKey] != "true" {
+		return nil, fmt.Errorf("secret %s in namespace %s is not a snapshot", key.Name, key.Namespace)
+	}
+	return snapshot, nil
+}
+
+// readState reads and decodes a secret manifest or snapshot file
+func readState(stdin io.Reader, file string) (*state.State, error) {
+	data, err := readInput(stdin, file)
+	if err != nil {
+		return nil, err
+	}
+	return state.FromManifest(data)
+}
+
+// warnRedacted warns that a redacted state must not be used to restore a state
+func warnRedacted(cmd *cobra.Command, s *state.State) {
+	if s.Redacted {
+		fmt.Fprintln(cmd.ErrOrStderr(), "warning: the state is redacted and cannot be restored")
+	}
+}
+
+// readInput reads the given file, or stdin if the file is -
+func readInput(stdin io.Reader, file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(file)
+}
+
+// writeOutput writes data to the given file, or to stdout if the file is -
+func writeOutput(stdout io.Writer, file string, data []byte) error {
+	if file == "-" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(file, data, 0o600)
+}
+
+// formatTime formats a status time, which is zero if it was never set
+func formatTime(t metav1.Time) string {
+	if t.IsZero() {
+		return "<never>"
+	}
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/controller"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+var _ = Describe("kubectl-tfrescue", func() {
+	var (
+		ctx context.Context
+		c   client.Client
+	)
+
+	stateSecret := func(name, namespace string, serial int, resources ...string) *corev1.Secret {
+		raw := fmt.Sprintf(`{"version": 4, "terraform_version": "1.9.0", "serial": %d, "lineage": "lineage-%s", `+
+			`"resources": [`, serial, namespace)
+		for i, resource := range resources {
+			if i > 0 {
+				raw += ","
+			}
+			raw += fmt.Sprintf(`{"mode": "managed", "type": "aws_instance", "name": %q, "provider": "aws", "instances": [{}]}`,
+				resource)
+		}
+		raw += "]}"
+		data, err := state.Encode([]byte(raw))
+		Expect(err).NotTo(HaveOccurred())
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					controller.TfStateLabelKey: controller.TfStateLabelValue,
+					"tfstate":                  "true",
+				},
+			},
+			Data: map[string][]byte{state.SecretKey: data},
+		}
+	}
+	backupSecret := func(name, namespace string, serial int, owner string, resources ...string) *corev1.Secret {
+		secret := stateSecret("backup-"+name, namespace, serial, resources...)
+		secret.Labels["tfstate"] = "false"
+		secret.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: terraformv1.GroupVersion.String(),
+			Kind:       "StateRescue",
+			Name:       owner,
+			UID:        types.UID(owner),
+			Controller: ptr.To(true),
+		}}
+		return secret
+	}
+	snapshotSecret := func(name, namespace string, serial int, owner string, resources ...string) *corev1.Secret {
+		secret := backupSecret(name, namespace, serial, owner, resources...)
+		secret.Name = "snapshot-" + name + "-0123456789"
+		secret.Labels[controller.SnapshotLabelKey] = "true"
+		secret.Annotations = map[string]string{controller.SnapshotOfAnnotationKey: name}
+		return secret
+	}
+	stateRescue := func(name, namespace, stateSecretName string) *terraformv1.StateRescue {
+		return &terraformv1.StateRescue{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       terraformv1.StateRescueSpec{StateSecretName: stateSecretName},
+			Status: terraformv1.StateRescueStatus{
+				LastBackupTime: metav1.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+				Conditions: []metav1.Condition{{
+					Type:               terraformv1.ConditionReplicated,
+					Status:             metav1.ConditionTrue,
+					Reason:             "Replicated",
+					LastTransitionTime: metav1.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+				}},
+			},
+		}
+	}
+
+	// run executes the plugin with the given arguments against the fake client and returns its output
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		root := newRootCommand(&options{client: c})
+		root.SetArgs(args)
+		root.SetOut(&out)
+		root.SetErr(&out)
+		err := root.ExecuteContext(ctx)
+		return out.String(), err
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			stateSecret("tfstate-default-app", "team-a", 2, "web", "worker"),
+			backupSecret("tfstate-default-app", "team-a", 1, "staterescue-app", "web"),
+			snapshotSecret("tfstate-default-app", "team-a", 1, "staterescue-app"),
+			backupSecret("tfstate-default-network", "team-b", 3, "staterescue-network", "gateway"),
+			stateRescue("staterescue-all", "team-a", "tfstate-"),
+			stateRescue("staterescue-app", "team-a", "tfstate-default-app"),
+			stateRescue("staterescue-network", "team-b", "tfstate-default-network"),
+		).WithStatusSubresource(&terraformv1.StateRescue{}).Build()
+	})
+
+	DescribeTable("running a command",
+		func(args []string, contains []string, excludes []string, expectedErr string) {
+			out, err := run(args...)
+			if expectedErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			for _, s := range contains {
+				Expect(out).To(ContainSubstring(s))
+			}
+			for _, s := range excludes {
+				Expect(out).NotTo(ContainSubstring(s))
+			}
+		},
+		Entry("list shows the backups and snapshots in the namespace with their state metadata",
+			[]string{"list", "-n", "team-a"},
+			[]string{"backup-tfstate-default-app", "snapshot-tfstate-default-app-0123456789", "lineage-team-a", "1.9.0",
+				"staterescue-app"},
+			[]string{"backup-tfstate-default-network", "   tfstate-default-app"}, ""),
+		Entry("list shows the backups in all namespaces",
+			[]string{"list", "-A"},
+			[]string{"backup-tfstate-default-app", "backup-tfstate-default-network", "staterescue-network"}, nil, ""),
+		Entry("show prints the decoded state of a state secret",
+			[]string{"show", "tfstate-default-app", "-n", "team-a"},
+			[]string{"Name:      tfstate-default-app", "Serial:    2", "Lineage:   lineage-team-a",
+				"aws_instance.web", "aws_instance.worker"}, nil, ""),
+		Entry("show prints the decoded state of a snapshot",
+			[]string{"show", "snapshot-tfstate-default-app-0123456789", "-n", "team-a"},
+			[]string{"Name:      snapshot-tfstate-default-app-0123456789", "Serial:    1"},
+			[]string{"aws_instance.web"}, ""),
+		Entry("show fails for a missing secret",
+			[]string{"show", "tfstate-default-missing", "-n", "team-a"}, nil, nil, "not found"),
+		Entry("diff compares a state secret with its backup",
+			[]string{"diff", "tfstate-default-app", "-n", "team-a"},
+			[]string{"--- backup-tfstate-default-app (serial 1, lineage lineage-team-a)",
+				"+++ tfstate-default-app (serial 2, lineage lineage-team-a)",
+				"+ aws_instance.worker", "1 added, 0 removed, 0 changed"}, nil, ""),
+		Entry("diff compares a state secret with its snapshot",
+			[]string{"diff", "snapshot-tfstate-default-app-0123456789", "-n", "team-a"},
+			[]string{"--- snapshot-tfstate-default-app-0123456789 (serial 1, lineage lineage-team-a)",
+				"+++ tfstate-default-app (serial 2, lineage lineage-team-a)",
+				"+ aws_instance.web", "+ aws_instance.worker", "2 added, 0 removed, 0 changed"}, nil, ""),
+		Entry("diff fails for a missing snapshot",
+			[]string{"diff", "snapshot-tfstate-default-missing", "-n", "team-a"}, nil, nil, "not found"),
+		Entry("diff fails without a secret or files",
+			[]string{"diff", "-n", "team-a"}, nil, nil, "either a secret or both --old and --new must be given"),
+		Entry("restore requests the StateRescue resource with the most specific state secret name",
+			[]string{"restore", "backup-tfstate-default-app", "-n", "team-a"},
+			[]string{"staterescue/staterescue-app: restore of secret tfstate-default-app requested"}, nil, ""),
+		Entry("restore requests the StateRescue resource that took the snapshot",
+			[]string{"restore", "snapshot-tfstate-default-app-0123456789", "-n", "team-a"},
+			[]string{"staterescue/staterescue-app: restore of secret tfstate-default-app " +
+				"from snapshot snapshot-tfstate-default-app-0123456789 requested"}, nil, ""),
+		Entry("restore fails if no StateRescue resource manages the secret",
+			[]string{"restore", "other-state", "-n", "team-a"}, nil, nil,
+			"no StateRescue resource manages secret other-state in namespace team-a"),
+		Entry("export writes the raw state of a backup secret",
+			[]string{"export", "backup-tfstate-default-network", "-n", "team-b"},
+			[]string{`"serial": 3`, `"name": "gateway"`}, nil, ""),
+		Entry("status shows the StateRescue resources in the namespace",
+			[]string{"status", "-n", "team-a"},
+			[]string{"staterescue-all", "staterescue-app", "2025-06-01T12:00:00Z", "True"},
+			[]string{"staterescue-network"}, ""),
+		Entry("status shows the StateRescue resources in all namespaces",
+			[]string{"status", "-A"},
+			[]string{"staterescue-app", "staterescue-network"}, nil, ""),
+	)
+
+	It("should annotate only the StateRescue resource that restores the secret", func() {
+		_, err := run("restore", "tfstate-default-app", "-n", "team-a")
+		Expect(err).NotTo(HaveOccurred())
+
+		requested := &terraformv1.StateRescue{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "staterescue-app", Namespace: "team-a"}, requested)).To(Succeed())
+		Expect(requested.Annotations).To(HaveKeyWithValue(controller.RestoreAnnotationKey, "tfstate-default-app"))
+		other := &terraformv1.StateRescue{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "staterescue-all", Namespace: "team-a"}, other)).To(Succeed())
+		Expect(other.Annotations).NotTo(HaveKey(controller.RestoreAnnotationKey))
+	})
+
+	It("should request the restore of a snapshot by its name", func() {
+		_, err := run("restore", "snapshot-tfstate-default-app-0123456789", "-n", "team-a")
+		Expect(err).NotTo(HaveOccurred())
+
+		requested := &terraformv1.StateRescue{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "staterescue-app", Namespace: "team-a"}, requested)).To(Succeed())
+		Expect(requested.Annotations).To(HaveKeyWithValue(controller.RestoreAnnotationKey,
+			"snapshot-tfstate-default-app-0123456789"))
+	})
+})
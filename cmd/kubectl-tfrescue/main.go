@@ -0,0 +1,111 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-tfrescue is a kubectl plugin for browsing, diffing and restoring the terraform state
+// backups managed by the tf-state-rescuer operator.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(terraformv1.AddToScheme(scheme))
+}
+
+// options holds the flags shared by all subcommands
+type options struct {
+	kubeconfig    string
+	context       string
+	namespace     string
+	allNamespaces bool
+	// client is used instead of a client for the cluster selected by the kubeconfig flags if set, e.g. in tests
+	client client.Client
+}
+
+// newClient returns a client for the cluster and the namespace selected by the kubeconfig flags
+func (o *options) newClient() (client.Client, string, error) {
+	if o.client != nil {
+		namespace := o.namespace
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		return o.client, namespace, nil
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = o.kubeconfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: o.context, Context: clientcmdapi.Context{Namespace: o.namespace}})
+	namespace, _, err := config.Namespace()
+	if err != nil {
+		return nil, "", err
+	}
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", err
+	}
+	return c, namespace, nil
+}
+
+// newRootCommand returns the kubectl-tfrescue command with all subcommands
+func newRootCommand(o *options) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "kubectl-tfrescue",
+		Short:         "Browse, diff and restore terraform state backups managed by tf-state-rescuer",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use")
+	root.PersistentFlags().StringVar(&o.context, "context", "", "The name of the kubeconfig context to use")
+	root.PersistentFlags().StringVarP(&o.namespace, "namespace", "n", "", "The namespace of the state secrets")
+	root.AddCommand(
+		newListCommand(o),
+		newShowCommand(o),
+		newDiffCommand(o),
+		newRestoreCommand(o),
+		newExportCommand(o),
+		newStatusCommand(o),
+		newDecodeCommand(),
+		newPackCommand(o),
+	)
+	return root
+}
+
+func main() {
+	if err := newRootCommand(&options{}).Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
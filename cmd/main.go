@@ -17,20 +17,29 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -38,11 +47,18 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	terraformv1alpha2 "github.com/hammadzf/tf-state-rescuer/api/v1alpha2"
+	"github.com/hammadzf/tf-state-rescuer/internal/certs"
+	"github.com/hammadzf/tf-state-rescuer/internal/config"
 	"github.com/hammadzf/tf-state-rescuer/internal/controller"
+	"github.com/hammadzf/tf-state-rescuer/internal/restore"
 	webhookv1 "github.com/hammadzf/tf-state-rescuer/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
 
+// serviceAccountNamespaceFile holds the namespace of the manager when it runs in a cluster
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -51,7 +67,10 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
 	utilruntime.Must(terraformv1.AddToScheme(scheme))
+	utilruntime.Must(terraformv1alpha2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -60,10 +79,24 @@ func main() {
 	var metricsAddr string
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
+	var webhookCertSecret, webhookCertNamespace, webhookServiceName string
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var dryRun bool
+	var secretMetadataOnly bool
+	var watchNamespaces string
+	var defaultsFile string
+	var configFile string
+	var verifyInterval time.Duration
+	var rescueFlappingThreshold int
+	var rescueFlappingWindow time.Duration
+	var restoreAll bool
+	var restoreOpts restore.Options
+	var restoreKubeconfig string
+	var restoreSigningKeyFile string
+	var restoreSigningAlgorithm string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -76,19 +109,87 @@ func main() {
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook certificate.")
 	flag.StringVar(&webhookCertName, "webhook-cert-name", "tls.crt", "The name of the webhook certificate file.")
 	flag.StringVar(&webhookCertKey, "webhook-cert-key", "tls.key", "The name of the webhook key file.")
+	flag.StringVar(&webhookCertSecret, "webhook-cert-secret", "",
+		"If set, the manager generates a self-signed webhook certificate, stores it in this Secret, rotates it "+
+			"and injects its CA into the webhook configurations and CRDs instead of relying on cert-manager.")
+	flag.StringVar(&webhookCertNamespace, "webhook-cert-namespace", "",
+		"The namespace of the webhook certificate Secret and the webhook Service, defaults to the namespace of the manager.")
+	flag.StringVar(&webhookServiceName, "webhook-service-name", "tf-state-rescuer-webhook-service",
+		"The name of the webhook Service that the self-managed webhook certificate is issued for.")
 	flag.StringVar(&metricsCertPath, "metrics-cert-path", "",
 		"The directory that contains the metrics server certificate.")
 	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics server certificate file.")
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, the controller only records the backup and rescue actions it would take "+
+			"as events, StateRescue status and metrics without applying them.")
+	flag.BoolVar(&secretMetadataOnly, "secret-metadata-only", false,
+		"If set, only the metadata of Terraform state secrets is cached and their data is read "+
+			"directly from the API server when needed.")
+	flag.DurationVar(&verifyInterval, "backup-verify-interval", time.Hour,
+		"The interval in which backups and replicas are re-read and verified against their checksums. "+
+			"Set to 0 to disable verification.")
+	flag.IntVar(&rescueFlappingThreshold, "rescue-flapping-threshold", 5,
+		"The number of rescues of a state secret within the rescue flapping window after which it is no longer "+
+			"rescued until the oldest rescue leaves the window. Set to 0 to rescue state secrets without limit.")
+	flag.DurationVar(&rescueFlappingWindow, "rescue-flapping-window", 10*time.Minute,
+		"The window in which the rescues of a state secret are counted.")
+	flag.StringVar(&defaultsFile, "staterescue-defaults", "",
+		"Path to a YAML file, e.g. mounted from a ConfigMap, with the defaults that the defaulting webhook "+
+			"applies to unset fields of the StateRescue spec.")
+	flag.StringVar(&configFile, "config", "",
+		"Path to the versioned configuration file of the manager, e.g. mounted from a ConfigMap. Its fields take "+
+			"precedence over the corresponding flags, and logLevel, dryRun and staterescueDefaults are reloaded "+
+			"when the file changes.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma-separated list of namespaces the manager watches for StateRescue resources and secrets. "+
+			"If empty, all namespaces are watched, which requires cluster-wide permissions.")
+	flag.BoolVar(&restoreAll, "restore-all", false,
+		"If set, the terraform state secrets are restored into this cluster from the replicas in the cluster "+
+			"given by --restore-from-kubeconfig, a summary report is printed and the manager exits without starting.")
+	flag.StringVar(&restoreKubeconfig, "restore-from-kubeconfig", "",
+		"Path to the kubeconfig of the cluster containing the replicas to restore from with --restore-all.")
+	flag.StringVar(&restoreOpts.SourceNamespace, "restore-from-namespace", "",
+		"Namespace in the cluster given by --restore-from-kubeconfig to read replicas from. "+
+			"If empty, replicas from all namespaces are restored.")
+	flag.BoolVar(&restoreOpts.RestoreStateRescues, "restore-staterescues", false,
+		"If set, --restore-all also recreates the StateRescue resources that replicated the secrets.")
+	flag.BoolVar(&restoreOpts.DryRun, "restore-dry-run", false,
+		"If set, --restore-all only reports what would be restored without creating any objects.")
+	flag.StringVar(&restoreSigningKeyFile, "restore-signing-key-file", "",
+		"Path to the signing key that the replicas were signed with, the shared key for HMAC-SHA256 or a PEM encoded "+
+			"PKCS #8 private key for Ed25519. If set, --restore-all only restores replicas that match their signature. "+
+			"Replicas written by StateRescue resources with signing are never restored without it.")
+	flag.StringVar(&restoreSigningAlgorithm, "restore-signing-algorithm", string(terraformv1.SigningHMACSHA256),
+		"Algorithm of the key given by --restore-signing-key-file, either HMAC-SHA256 or Ed25519.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	// The configuration is loaded before the logger is set up, so that the log level follows the configuration file
+	configStore, configErr := newConfigStore(configFile, &opts, dryRun, secretMetadataOnly, watchNamespaces,
+		defaultsFile, verifyInterval, rescueFlappingThreshold, rescueFlappingWindow)
+	if configErr == nil && configFile != "" {
+		opts.Level = configStore.LogLevel()
+	}
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	if configErr != nil {
+		setupLog.Error(configErr, "unable to load the manager configuration")
+		os.Exit(1)
+	}
+	managerConfig := configStore.Config()
+
+	if restoreAll {
+		if err := runRestoreAll(restoreKubeconfig, restoreSigningKeyFile, restoreSigningAlgorithm, restoreOpts); err != nil {
+			setupLog.Error(err, "unable to restore terraform state secrets")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -111,6 +212,18 @@ func main() {
 	// Initial webhook TLS options
 	webhookTLSOpts := tlsOpts
 
+	// The self-managed webhook certificate is written before the certificate watcher is created,
+	// so that the webhook server starts with a valid certificate
+	var webhookCertManager *certs.Manager
+	if webhookCertSecret != "" {
+		var err error
+		if webhookCertManager, err = newWebhookCertManager(webhookCertSecret, webhookCertNamespace,
+			webhookServiceName, &webhookCertPath, webhookCertName, webhookCertKey); err != nil {
+			setupLog.Error(err, "unable to set up self-managed webhook certificates")
+			os.Exit(1)
+		}
+	}
+
 	if len(webhookCertPath) > 0 {
 		setupLog.Info("Initializing webhook certificate watcher using provided certificates",
 			"webhook-cert-path", webhookCertPath, "webhook-cert-name", webhookCertName, "webhook-cert-key", webhookCertKey)
@@ -179,10 +292,26 @@ func main() {
 		})
 	}
 
+	// Only secrets carrying the Terraform label are cached instead of all secrets in the cluster
+	cacheOptions := cache.Options{
+		ByObject: controller.SecretCacheOptions(),
+	}
+
+	// Restrict the cache to the watched namespaces, so that namespaced Roles are sufficient for the manager
+	namespaces := managerConfig.WatchNamespaces
+	if len(namespaces) > 0 {
+		setupLog.Info("Restricting the manager to namespaces", "watch-namespaces", namespaces)
+		cacheOptions.DefaultNamespaces = make(map[string]cache.Config, len(namespaces))
+		for _, namespace := range namespaces {
+			cacheOptions.DefaultNamespaces[namespace] = cache.Config{}
+		}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
+		Cache:                  cacheOptions,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "848f21a1.hammadzf.github.io",
@@ -204,18 +333,30 @@ func main() {
 	}
 
 	if err := (&controller.StateRescueReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:             mgr.GetClient(),
+		Scheme:             mgr.GetScheme(),
+		Recorder:           mgr.GetEventRecorderFor("staterescue-controller"),
+		DryRun:             configStore.DryRun,
+		SecretMetadataOnly: *managerConfig.SecretMetadataOnly,
+		APIReader:          mgr.GetAPIReader(),
+		VerifyInterval:     managerConfig.BackupVerifyInterval.Duration,
+
+		RescueFlappingThreshold: *managerConfig.RescueFlappingThreshold,
+		RescueFlappingWindow:    managerConfig.RescueFlappingWindow.Duration,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "StateRescue")
 		os.Exit(1)
 	}
 	// nolint:goconst
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err := webhookv1.SetupStateRescueWebhookWithManager(mgr); err != nil {
+		if err := webhookv1.SetupStateRescueWebhookWithManager(mgr, namespaces, configStore); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "StateRescue")
 			os.Exit(1)
 		}
+		if err := webhookv1.SetupSecretAuditWebhookWithManager(mgr, namespaces); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SecretAudit")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -235,6 +376,27 @@ func main() {
 		}
 	}
 
+	if webhookCertManager != nil {
+		setupLog.Info("Adding webhook certificate manager to manager")
+		if err := mgr.Add(webhookCertManager); err != nil {
+			setupLog.Error(err, "unable to add webhook certificate manager to manager")
+			os.Exit(1)
+		}
+	}
+
+	if configFile != "" {
+		setupLog.Info("Adding manager configuration reloader to manager", "config", configFile)
+		if err := mgr.Add(configStore); err != nil {
+			setupLog.Error(err, "unable to add manager configuration reloader to manager")
+			os.Exit(1)
+		}
+	}
+	// The effective configuration is served next to the metrics and protected by the same authn/authz
+	if err := mgr.AddMetricsServerExtraHandler("/debug/config", configStore); err != nil {
+		setupLog.Error(err, "unable to add manager configuration endpoint")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -250,3 +412,133 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newConfigStore loads the configuration file of the manager, the fields that it does not set keep the values
+// of the flags. The configuration is only made of the flags if no configuration file is given.
+func newConfigStore(path string, opts *zap.Options, dryRun, secretMetadataOnly bool, watchNamespaces,
+	defaultsFile string, verifyInterval time.Duration, rescueFlappingThreshold int,
+	rescueFlappingWindow time.Duration) (*config.Store, error) {
+	// the log level of the flags, the development mode logs at the debug level by default
+	logLevel := flag.Lookup("zap-log-level").Value.String()
+	if logLevel == "" {
+		logLevel = "info"
+		if opts.Development {
+			logLevel = "debug"
+		}
+	}
+	base := &config.ManagerConfig{
+		TypeMeta:                metav1.TypeMeta{APIVersion: config.APIVersion, Kind: config.Kind},
+		LogLevel:                logLevel,
+		DryRun:                  &dryRun,
+		WatchNamespaces:         parseWatchNamespaces(watchNamespaces),
+		SecretMetadataOnly:      &secretMetadataOnly,
+		BackupVerifyInterval:    &metav1.Duration{Duration: verifyInterval},
+		RescueFlappingThreshold: &rescueFlappingThreshold,
+		RescueFlappingWindow:    &metav1.Duration{Duration: rescueFlappingWindow},
+	}
+	if defaultsFile != "" {
+		defaults, err := webhookv1.LoadStateRescueDefaults(defaultsFile)
+		if err != nil {
+			return nil, err
+		}
+		base.StateRescueDefaults = defaults
+	}
+	return config.NewStore(path, base)
+}
+
+// parseWatchNamespaces splits the comma-separated list of namespaces passed with --watch-namespaces
+func parseWatchNamespaces(value string) []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(value, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// newWebhookCertManager creates the manager of the self-managed webhook certificate and writes the current
+// certificate to the webhook certificate path, which defaults to the directory of the webhook server
+func newWebhookCertManager(secretName, namespace, serviceName string, certPath *string,
+	certName, keyName string) (*certs.Manager, error) {
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("--webhook-cert-namespace is required outside of a cluster: %w", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	if *certPath == "" {
+		*certPath = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+	}
+	// the secret is read without the cache of the manager, which only holds the secrets written by Terraform
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	manager := &certs.Manager{
+		Client: c,
+		Options: certs.Options{
+			SecretName:  secretName,
+			Namespace:   namespace,
+			ServiceName: serviceName,
+			CertDir:     *certPath,
+			CertName:    certName,
+			KeyName:     keyName,
+		},
+	}
+	setupLog.Info("Managing webhook certificates", "webhook-cert-secret", secretName,
+		"webhook-cert-namespace", namespace, "webhook-service-name", serviceName, "webhook-cert-path", *certPath)
+	if err := manager.Ensure(context.Background()); err != nil {
+		return nil, err
+	}
+	return manager, nil
+}
+
+// runRestoreAll restores the terraform state secrets into the current cluster from the replicas
+// in the cluster of the given kubeconfig and prints a summary report. The replicas are verified
+// with the signing key in the given file, if any.
+func runRestoreAll(kubeconfig, signingKeyFile, signingAlgorithm string, opts restore.Options) error {
+	if kubeconfig == "" {
+		return fmt.Errorf("--restore-from-kubeconfig is required with --restore-all")
+	}
+	if signingKeyFile != "" {
+		algorithm := terraformv1.SigningAlgorithm(signingAlgorithm)
+		if algorithm != terraformv1.SigningHMACSHA256 && algorithm != terraformv1.SigningEd25519 {
+			return fmt.Errorf("unknown signing algorithm %s", signingAlgorithm)
+		}
+		key, err := os.ReadFile(signingKeyFile)
+		if err != nil {
+			return err
+		}
+		if opts.Signer, err = controller.NewReplicaSigner(algorithm, key, signingKeyFile); err != nil {
+			return err
+		}
+	}
+	local, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	remoteConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return err
+	}
+	remote, err := client.New(remoteConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	setupLog.Info("restoring terraform state secrets", "source-namespace", opts.SourceNamespace,
+		"restore-staterescues", opts.RestoreStateRescues, "dry-run", opts.DryRun, "verify-signatures", opts.Signer != nil)
+	summary, err := restore.RestoreAll(context.Background(), local, remote, opts)
+	if err != nil {
+		return err
+	}
+	if err := summary.Write(os.Stdout); err != nil {
+		return err
+	}
+	if failed := summary.Failed(); failed > 0 {
+		return fmt.Errorf("%d objects could not be restored", failed)
+	}
+	return nil
+}
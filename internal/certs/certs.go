@@ -0,0 +1,450 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certs manages the serving certificate of the webhook server for clusters without cert-manager.
+// It generates a self-signed CA and a serving certificate, stores them in a Secret, rotates them before they
+// expire and injects the CA into the webhook configurations and the conversion webhooks of CRDs that call
+// the webhook service.
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// CACertKey is the key of the PEM encoded CA bundle in the certificate secret. The first certificate is the
+	// current CA, it is followed by the previous CA until it expires, so that serving certificates signed by the
+	// previous CA are still trusted while they are being replaced.
+	CACertKey = "ca.crt"
+	// CAKeyKey is the key of the PEM encoded private key of the current CA in the certificate secret
+	CAKeyKey = "ca.key"
+
+	// DefaultCAValidity is how long generated CAs are valid
+	DefaultCAValidity = 10 * 365 * 24 * time.Hour
+	// DefaultCertValidity is how long generated serving certificates are valid
+	DefaultCertValidity = 365 * 24 * time.Hour
+	// DefaultCheckInterval is how often the certificates are checked for rotation
+	DefaultCheckInterval = time.Minute
+)
+
+var certslog = logf.Log.WithName("webhook-certs")
+
+// Options configures the certificate manager
+type Options struct {
+	// SecretName is the name of the secret that stores the CA and the serving certificate
+	SecretName string
+	// Namespace is the namespace of the secret and the webhook service
+	Namespace string
+	// ServiceName is the name of the webhook service that the serving certificate is issued for
+	ServiceName string
+	// CertDir is the directory that the serving certificate is written to for the webhook server
+	CertDir string
+	// CertName is the name of the serving certificate file, tls.crt if empty
+	CertName string
+	// KeyName is the name of the serving key file, tls.key if empty
+	KeyName string
+	// CAValidity is how long generated CAs are valid, DefaultCAValidity if zero
+	CAValidity time.Duration
+	// CertValidity is how long generated serving certificates are valid, DefaultCertValidity if zero
+	CertValidity time.Duration
+	// CheckInterval is how often the certificates are checked for rotation, DefaultCheckInterval if zero
+	CheckInterval time.Duration
+}
+
+// Manager generates, rotates and distributes the serving certificate of the webhook server. Certificates are
+// rotated once two thirds of their validity have passed. Every replica of the controller manager runs it, so
+// that each replica serves the current certificate. Replicas coordinate through optimistic concurrency on the
+// certificate secret.
+type Manager struct {
+	// Client reads and writes the certificate secret, the webhook configurations and the CRDs.
+	// It must not be backed by a cache that is restricted to other secrets.
+	Client  client.Client
+	Options Options
+
+	// now returns the current time, it is replaced in tests
+	now func() time.Time
+}
+
+var _ manager.Runnable = &Manager{}
+var _ manager.LeaderElectionRunnable = &Manager{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every replica serves webhooks
+func (m *Manager) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable and periodically rotates the certificates until the context is done
+func (m *Manager) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.checkInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := m.Ensure(ctx); err != nil {
+				certslog.Error(err, "unable to rotate the webhook certificates")
+			}
+		}
+	}
+}
+
+// Ensure generates or rotates the certificates in the secret if needed, writes the serving certificate to the
+// certificate directory and injects the CA bundle into the webhook configurations and CRDs. It is called once
+// before the webhook server starts, so that the server starts with a valid certificate. A new CA is injected
+// along with the previous one before the serving certificate is issued by it, so that the API server trusts
+// the serving certificate at any time.
+func (m *Manager) Ensure(ctx context.Context) error {
+	secret, err := m.update(ctx, m.rotateCA)
+	if err != nil {
+		return err
+	}
+	if err := m.injectCABundle(ctx, secret.Data[CACertKey]); err != nil {
+		return err
+	}
+	if secret, err = m.update(ctx, m.rotateServing); err != nil {
+		return err
+	}
+	return m.writeFiles(secret)
+}
+
+// update creates or updates the certificate secret with the changes of mutate and returns the current secret.
+// Another replica may write the secret at the same time, so the secret is read again on conflicts.
+func (m *Manager) update(ctx context.Context, mutate func(*corev1.Secret) (bool, error)) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: m.Options.SecretName, Namespace: m.Options.Namespace}
+	if err := retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+	}, func() error {
+		secret = &corev1.Secret{}
+		err := m.Client.Get(ctx, key, secret)
+		if errors.IsNotFound(err) {
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Type:       corev1.SecretTypeTLS,
+			}
+			if _, err := mutate(secret); err != nil {
+				return err
+			}
+			certslog.Info("Creating the webhook certificates", "Secret", key.Name)
+			return m.Client.Create(ctx, secret)
+		} else if err != nil {
+			return err
+		}
+		rotated, err := mutate(secret)
+		if err != nil || !rotated {
+			return err
+		}
+		certslog.Info("Rotating the webhook certificates", "Secret", key.Name)
+		return m.Client.Update(ctx, secret)
+	}); err != nil {
+		return nil, fmt.Errorf("unable to write webhook certificate secret %s: %w", key.Name, err)
+	}
+	return secret, nil
+}
+
+// rotateCA generates a new CA in the secret if it is missing, invalid or due for rotation and keeps the previous
+// CA in the bundle while it is valid, it returns whether the secret was changed
+func (m *Manager) rotateCA(secret *corev1.Secret) (bool, error) {
+	now := m.currentTime()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	// the first certificate of the bundle is the current CA
+	ca, err := tls.X509KeyPair(secret.Data[CACertKey], secret.Data[CAKeyKey])
+	if err == nil && !due(ca.Leaf, now) {
+		return false, nil
+	}
+	cert, key, genErr := generate(nil, nil, now, m.caValidity(), nil)
+	if genErr != nil {
+		return false, fmt.Errorf("unable to generate webhook CA: %w", genErr)
+	}
+	bundle := slices.Clone(cert)
+	if err == nil && now.Before(ca.Leaf.NotAfter) {
+		bundle = append(bundle, encode(ca.Leaf.Raw)...)
+	}
+	secret.Data[CACertKey] = bundle
+	secret.Data[CAKeyKey] = key
+	return true, nil
+}
+
+// rotateServing issues a new serving certificate by the current CA in the secret if it is missing, invalid,
+// due for rotation or not issued by the current CA for the webhook service, it returns whether the secret was changed
+func (m *Manager) rotateServing(secret *corev1.Secret) (bool, error) {
+	now := m.currentTime()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	ca, err := tls.X509KeyPair(secret.Data[CACertKey], secret.Data[CAKeyKey])
+	if err != nil {
+		return false, fmt.Errorf("invalid webhook CA: %w", err)
+	}
+	serving, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err == nil && !due(serving.Leaf, now) && m.issuedFor(serving.Leaf, ca.Leaf) {
+		return false, nil
+	}
+	signer, ok := ca.PrivateKey.(crypto.Signer)
+	if !ok {
+		return false, fmt.Errorf("unable to sign webhook certificate: unsupported CA key")
+	}
+	cert, key, err := generate(ca.Leaf, signer, now, m.certValidity(), m.dnsNames())
+	if err != nil {
+		return false, fmt.Errorf("unable to generate webhook serving certificate: %w", err)
+	}
+	secret.Data[corev1.TLSCertKey] = cert
+	secret.Data[corev1.TLSPrivateKeyKey] = key
+	return true, nil
+}
+
+// due returns whether two thirds of the validity of the certificate have passed
+func due(cert *x509.Certificate, now time.Time) bool {
+	validity := cert.NotAfter.Sub(cert.NotBefore)
+	return !now.Before(cert.NotBefore.Add(validity * 2 / 3))
+}
+
+// issuedFor returns whether the serving certificate is signed by the CA and covers the DNS names of the service
+func (m *Manager) issuedFor(cert *x509.Certificate, ca *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(ca) == nil && slices.Equal(cert.DNSNames, m.dnsNames())
+}
+
+// dnsNames returns the DNS names of the webhook service
+func (m *Manager) dnsNames() []string {
+	service, namespace := m.Options.ServiceName, m.Options.Namespace
+	return []string{
+		service,
+		fmt.Sprintf("%s.%s", service, namespace),
+		fmt.Sprintf("%s.%s.svc", service, namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+	}
+}
+
+// generate generates a PEM encoded certificate and private key. A self-signed CA is generated if parent is nil,
+// a serving certificate for the DNS names signed by the parent otherwise.
+func generate(parent *x509.Certificate, parentKey crypto.Signer, now time.Time, validity time.Duration,
+	dnsNames []string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		// backdated to tolerate clock skew between the nodes and the API server
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		template.Subject = pkix.Name{CommonName: "tf-state-rescuer-webhook-ca"}
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		parent, parentKey = template, key
+	} else {
+		template.Subject = pkix.Name{CommonName: dnsNames[2]}
+		template.DNSNames = dnsNames
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encode(der), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// encode PEM encodes a DER encoded certificate
+func encode(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// writeFiles writes the serving certificate and key to the certificate directory if they changed. The files
+// are replaced atomically, so that the certificate watcher of the webhook server never reads partial files.
+func (m *Manager) writeFiles(secret *corev1.Secret) error {
+	if err := os.MkdirAll(m.Options.CertDir, 0o700); err != nil {
+		return fmt.Errorf("unable to create webhook certificate directory: %w", err)
+	}
+	// the key is written first, so that the certificate watcher reloads once both files are current
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{m.keyName(), secret.Data[corev1.TLSPrivateKeyKey]},
+		{m.certName(), secret.Data[corev1.TLSCertKey]},
+	} {
+		path := filepath.Join(m.Options.CertDir, file.name)
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, file.data) {
+			continue
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, file.data, 0o600); err != nil {
+			return fmt.Errorf("unable to write webhook certificate: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("unable to write webhook certificate: %w", err)
+		}
+	}
+	return nil
+}
+
+// calls returns whether the service reference points to the webhook service
+func (m *Manager) calls(service *admissionregistrationv1.ServiceReference) bool {
+	return service != nil && service.Name == m.Options.ServiceName && service.Namespace == m.Options.Namespace
+}
+
+// injectCABundle sets the CA bundle of the webhooks and CRD conversion webhooks that call the webhook service
+func (m *Manager) injectCABundle(ctx context.Context, bundle []byte) error {
+	validating := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	if err := m.Client.List(ctx, validating); err != nil {
+		return fmt.Errorf("unable to list validating webhook configurations: %w", err)
+	}
+	for i := range validating.Items {
+		item := &validating.Items[i]
+		changed := false
+		for j := range item.Webhooks {
+			config := &item.Webhooks[j].ClientConfig
+			if m.calls(config.Service) && !bytes.Equal(config.CABundle, bundle) {
+				config.CABundle = bundle
+				changed = true
+			}
+		}
+		if changed {
+			certslog.Info("Injecting the webhook CA", "ValidatingWebhookConfiguration", item.Name)
+			if err := m.Client.Update(ctx, item); err != nil {
+				return fmt.Errorf("unable to inject CA into %s: %w", item.Name, err)
+			}
+		}
+	}
+
+	mutating := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+	if err := m.Client.List(ctx, mutating); err != nil {
+		return fmt.Errorf("unable to list mutating webhook configurations: %w", err)
+	}
+	for i := range mutating.Items {
+		item := &mutating.Items[i]
+		changed := false
+		for j := range item.Webhooks {
+			config := &item.Webhooks[j].ClientConfig
+			if m.calls(config.Service) && !bytes.Equal(config.CABundle, bundle) {
+				config.CABundle = bundle
+				changed = true
+			}
+		}
+		if changed {
+			certslog.Info("Injecting the webhook CA", "MutatingWebhookConfiguration", item.Name)
+			if err := m.Client.Update(ctx, item); err != nil {
+				return fmt.Errorf("unable to inject CA into %s: %w", item.Name, err)
+			}
+		}
+	}
+
+	crds := &apiextensionsv1.CustomResourceDefinitionList{}
+	if err := m.Client.List(ctx, crds); err != nil {
+		return fmt.Errorf("unable to list CRDs: %w", err)
+	}
+	for i := range crds.Items {
+		item := &crds.Items[i]
+		conversion := item.Spec.Conversion
+		if conversion == nil || conversion.Webhook == nil || conversion.Webhook.ClientConfig == nil {
+			continue
+		}
+		config := conversion.Webhook.ClientConfig
+		if config.Service == nil || config.Service.Name != m.Options.ServiceName ||
+			config.Service.Namespace != m.Options.Namespace || bytes.Equal(config.CABundle, bundle) {
+			continue
+		}
+		config.CABundle = bundle
+		certslog.Info("Injecting the webhook CA", "CustomResourceDefinition", item.Name)
+		if err := m.Client.Update(ctx, item); err != nil {
+			return fmt.Errorf("unable to inject CA into %s: %w", item.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) currentTime() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+func (m *Manager) certName() string {
+	if m.Options.CertName != "" {
+		return m.Options.CertName
+	}
+	return corev1.TLSCertKey
+}
+
+func (m *Manager) keyName() string {
+	if m.Options.KeyName != "" {
+		return m.Options.KeyName
+	}
+	return corev1.TLSPrivateKeyKey
+}
+
+func (m *Manager) caValidity() time.Duration {
+	if m.Options.CAValidity > 0 {
+		return m.Options.CAValidity
+	}
+	return DefaultCAValidity
+}
+
+func (m *Manager) certValidity() time.Duration {
+	if m.Options.CertValidity > 0 {
+		return m.Options.CertValidity
+	}
+	return DefaultCertValidity
+}
+
+func (m *Manager) checkInterval() time.Duration {
+	if m.Options.CheckInterval > 0 {
+		return m.Options.CheckInterval
+	}
+	return DefaultCheckInterval
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("Manager", func() {
+	const namespace = "tf-state-rescuer-system"
+	var (
+		ctx context.Context
+		c   client.Client
+		m   *Manager
+		now time.Time
+	)
+
+	service := func(name string) *admissionregistrationv1.ServiceReference {
+		return &admissionregistrationv1.ServiceReference{Name: name, Namespace: namespace, Path: ptr.To("/validate")}
+	}
+
+	secret := func() *corev1.Secret {
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "webhook-server-cert", Namespace: namespace}, secret)).To(Succeed())
+		return secret
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		now = time.Now()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "tf-state-rescuer-validating-webhook-configuration"},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{
+					{Name: "vstaterescue-v1.kb.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: service("tf-state-rescuer-webhook-service")}},
+					{Name: "other.kb.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: service("other-webhook-service")}},
+				},
+			},
+			&admissionregistrationv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "tf-state-rescuer-mutating-webhook-configuration"},
+				Webhooks: []admissionregistrationv1.MutatingWebhook{
+					{Name: "mstaterescue-v1.kb.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: service("tf-state-rescuer-webhook-service")}},
+				},
+			},
+			&apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: "staterescues.terraform.hammadzf.github.io"},
+				Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+					Conversion: &apiextensionsv1.CustomResourceConversion{
+						Strategy: apiextensionsv1.WebhookConverter,
+						Webhook: &apiextensionsv1.WebhookConversion{
+							ClientConfig: &apiextensionsv1.WebhookClientConfig{
+								Service: &apiextensionsv1.ServiceReference{
+									Name: "tf-state-rescuer-webhook-service", Namespace: namespace},
+							},
+						},
+					},
+				},
+			},
+		).Build()
+		m = &Manager{
+			Client: c,
+			Options: Options{
+				SecretName:  "webhook-server-cert",
+				Namespace:   namespace,
+				ServiceName: "tf-state-rescuer-webhook-service",
+				CertDir:     GinkgoT().TempDir(),
+			},
+			now: func() time.Time { return now },
+		}
+	})
+
+	It("should generate a serving certificate signed by the CA for the webhook service", func() {
+		Expect(m.Ensure(ctx)).To(Succeed())
+
+		data := secret().Data
+		pool := x509.NewCertPool()
+		Expect(pool.AppendCertsFromPEM(data[CACertKey])).To(BeTrue())
+		serving, err := tls.X509KeyPair(data[corev1.TLSCertKey], data[corev1.TLSPrivateKeyKey])
+		Expect(err).NotTo(HaveOccurred())
+		_, err = serving.Leaf.Verify(x509.VerifyOptions{
+			DNSName: "tf-state-rescuer-webhook-service.tf-state-rescuer-system.svc",
+			Roots:   pool,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("writing the serving certificate for the webhook server")
+		cert, err := os.ReadFile(filepath.Join(m.Options.CertDir, "tls.crt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cert).To(Equal(data[corev1.TLSCertKey]))
+		key, err := os.ReadFile(filepath.Join(m.Options.CertDir, "tls.key"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(Equal(data[corev1.TLSPrivateKeyKey]))
+	})
+
+	It("should inject the CA into the webhooks and conversion webhooks calling the webhook service", func() {
+		Expect(m.Ensure(ctx)).To(Succeed())
+		bundle := secret().Data[CACertKey]
+
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "tf-state-rescuer-validating-webhook-configuration"}, validating)).To(Succeed())
+		Expect(validating.Webhooks[0].ClientConfig.CABundle).To(Equal(bundle))
+		Expect(validating.Webhooks[1].ClientConfig.CABundle).To(BeEmpty())
+
+		mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "tf-state-rescuer-mutating-webhook-configuration"}, mutating)).To(Succeed())
+		Expect(mutating.Webhooks[0].ClientConfig.CABundle).To(Equal(bundle))
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "staterescues.terraform.hammadzf.github.io"}, crd)).To(Succeed())
+		Expect(crd.Spec.Conversion.Webhook.ClientConfig.CABundle).To(Equal(bundle))
+	})
+
+	It("should keep the certificates until they are due for rotation", func() {
+		Expect(m.Ensure(ctx)).To(Succeed())
+		before := secret()
+
+		now = now.Add(DefaultCertValidity / 2)
+		Expect(m.Ensure(ctx)).To(Succeed())
+		Expect(secret().ResourceVersion).To(Equal(before.ResourceVersion))
+	})
+
+	It("should rotate the serving certificate before it expires", func() {
+		Expect(m.Ensure(ctx)).To(Succeed())
+		before := secret()
+
+		now = now.Add(DefaultCertValidity * 3 / 4)
+		Expect(m.Ensure(ctx)).To(Succeed())
+		after := secret()
+		Expect(after.Data[corev1.TLSCertKey]).NotTo(Equal(before.Data[corev1.TLSCertKey]))
+		Expect(after.Data[CACertKey]).To(Equal(before.Data[CACertKey]))
+
+		cert, err := os.ReadFile(filepath.Join(m.Options.CertDir, "tls.crt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cert).To(Equal(after.Data[corev1.TLSCertKey]))
+	})
+
+	It("should keep trusting the previous CA after the CA is rotated", func() {
+		Expect(m.Ensure(ctx)).To(Succeed())
+		before := secret()
+
+		now = now.Add(DefaultCAValidity * 3 / 4)
+		Expect(m.Ensure(ctx)).To(Succeed())
+		after := secret()
+		Expect(after.Data[CAKeyKey]).NotTo(Equal(before.Data[CAKeyKey]))
+		Expect(string(after.Data[CACertKey])).To(HaveSuffix(string(before.Data[CACertKey])))
+
+		validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "tf-state-rescuer-validating-webhook-configuration"}, validating)).To(Succeed())
+		Expect(validating.Webhooks[0].ClientConfig.CABundle).To(Equal(after.Data[CACertKey]))
+	})
+
+	It("should only switch to a serving certificate of a new CA once the CA is injected", func() {
+		Expect(m.Ensure(ctx)).To(Succeed())
+		before := secret()
+
+		By("failing to inject the CA bundle")
+		m.Client = interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if _, ok := obj.(*admissionregistrationv1.ValidatingWebhookConfiguration); ok {
+					return fmt.Errorf("injection failed")
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		})
+		now = now.Add(DefaultCAValidity * 3 / 4)
+		Expect(m.Ensure(ctx)).To(MatchError(ContainSubstring("injection failed")))
+		after := secret()
+		Expect(after.Data[CAKeyKey]).NotTo(Equal(before.Data[CAKeyKey]))
+		Expect(after.Data[corev1.TLSCertKey]).To(Equal(before.Data[corev1.TLSCertKey]))
+		cert, err := os.ReadFile(filepath.Join(m.Options.CertDir, "tls.crt"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cert).To(Equal(before.Data[corev1.TLSCertKey]))
+
+		By("switching the serving certificate once the CA bundle is injected")
+		m.Client = c
+		Expect(m.Ensure(ctx)).To(Succeed())
+		Expect(secret().Data[CACertKey]).To(Equal(after.Data[CACertKey]))
+		serving, err := tls.X509KeyPair(secret().Data[corev1.TLSCertKey], secret().Data[corev1.TLSPrivateKeyKey])
+		Expect(err).NotTo(HaveOccurred())
+		ca, err := tls.X509KeyPair(after.Data[CACertKey], after.Data[CAKeyKey])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serving.Leaf.CheckSignatureFrom(ca.Leaf)).To(Succeed())
+	})
+
+	It("should reissue the serving certificate when the webhook service changes", func() {
+		Expect(m.Ensure(ctx)).To(Succeed())
+		before := secret()
+
+		m.Options.ServiceName = "renamed-webhook-service"
+		Expect(m.Ensure(ctx)).To(Succeed())
+		serving, err := tls.X509KeyPair(secret().Data[corev1.TLSCertKey], secret().Data[corev1.TLSPrivateKeyKey])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(serving.Leaf.DNSNames).To(ContainElement("renamed-webhook-service.tf-state-rescuer-system.svc"))
+		Expect(secret().Data[CACertKey]).To(Equal(before.Data[CACertKey]))
+	})
+})
@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config loads the versioned configuration file of the controller manager, e.g. mounted from a ConfigMap,
+// and reloads the fields that can be changed while the manager is running whenever the file changes.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	webhookv1 "github.com/hammadzf/tf-state-rescuer/internal/webhook/v1"
+)
+
+const (
+	// APIVersion is the version of the configuration file format
+	APIVersion = "config.terraform.hammadzf.github.io/v1alpha1"
+	// Kind is the kind of the configuration file
+	Kind = "ManagerConfig"
+)
+
+// ManagerConfig is the configuration of the controller manager. Fields that are not set in the configuration
+// file keep the values of the corresponding flags. LogLevel, DryRun and StateRescueDefaults are reloaded when
+// the file changes, changes of the other fields only take effect when the manager is restarted.
+type ManagerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// LogLevel is the level of the manager logs, debug, info, error, panic or an integer verbosity greater than 0
+	LogLevel string `json:"logLevel,omitempty"`
+	// DryRun makes the controller only plan backup and rescue actions for all StateRescue resources
+	DryRun *bool `json:"dryRun,omitempty"`
+	// StateRescueDefaults are the defaults that the defaulting webhook applies to unset fields of the StateRescue spec
+	StateRescueDefaults *webhookv1.StateRescueDefaults `json:"staterescueDefaults,omitempty"`
+
+	// WatchNamespaces are the namespaces the manager watches for StateRescue resources and secrets,
+	// all namespaces are watched if empty
+	WatchNamespaces []string `json:"watchNamespaces,omitempty"`
+	// SecretMetadataOnly makes the manager cache only the metadata of Terraform state secrets
+	SecretMetadataOnly *bool `json:"secretMetadataOnly,omitempty"`
+	// BackupVerifyInterval is the interval in which backups and replicas are verified, 0 disables verification
+	BackupVerifyInterval *metav1.Duration `json:"backupVerifyInterval,omitempty"`
+	// RescueFlappingThreshold is the number of rescues of a state secret within the rescue flapping window
+	// after which it is no longer rescued, 0 disables the limit
+	RescueFlappingThreshold *int `json:"rescueFlappingThreshold,omitempty"`
+	// RescueFlappingWindow is the window in which the rescues of a state secret are counted
+	RescueFlappingWindow *metav1.Duration `json:"rescueFlappingWindow,omitempty"`
+}
+
+// Load reads the configuration from a YAML file and validates it
+func Load(path string) (*ManagerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &ManagerConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("invalid manager configuration in %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manager configuration in %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Validate checks the version and the fields of the configuration
+func (c *ManagerConfig) Validate() error {
+	if c.APIVersion != APIVersion || c.Kind != Kind {
+		return fmt.Errorf("unsupported configuration %s/%s, expected %s/%s", c.APIVersion, c.Kind, APIVersion, Kind)
+	}
+	if _, err := c.Level(); err != nil {
+		return err
+	}
+	if c.StateRescueDefaults != nil {
+		if err := c.StateRescueDefaults.Validate(); err != nil {
+			return fmt.Errorf("invalid staterescueDefaults: %w", err)
+		}
+	}
+	if c.BackupVerifyInterval != nil && c.BackupVerifyInterval.Duration < 0 {
+		return fmt.Errorf("backupVerifyInterval must not be negative")
+	}
+	if c.RescueFlappingThreshold != nil && *c.RescueFlappingThreshold < 0 {
+		return fmt.Errorf("rescueFlappingThreshold must not be negative")
+	}
+	if c.RescueFlappingWindow != nil && c.RescueFlappingWindow.Duration <= 0 {
+		return fmt.Errorf("rescueFlappingWindow must be positive")
+	}
+	return nil
+}
+
+// Level parses the log level like the --zap-log-level flag, it returns the info level if the log level is not set
+func (c *ManagerConfig) Level() (zapcore.Level, error) {
+	switch strings.ToLower(c.LogLevel) {
+	case "", "info":
+		return zapcore.InfoLevel, nil
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	case "panic":
+		return zapcore.PanicLevel, nil
+	}
+	// an integer verbosity enables the debug levels of logr, e.g. V(2)
+	verbosity, err := strconv.Atoi(c.LogLevel)
+	if err != nil || verbosity <= 0 || verbosity > 127 {
+		return zapcore.InfoLevel, fmt.Errorf("invalid log level %q", c.LogLevel)
+	}
+	return zapcore.Level(int8(-verbosity)), nil
+}
+
+// complete sets the fields that are not set in the configuration to the values in base, i.e. the flags
+func (c *ManagerConfig) complete(base *ManagerConfig) {
+	if c.LogLevel == "" {
+		c.LogLevel = base.LogLevel
+	}
+	if c.DryRun == nil {
+		c.DryRun = base.DryRun
+	}
+	if c.StateRescueDefaults == nil {
+		c.StateRescueDefaults = base.StateRescueDefaults
+	}
+	if c.WatchNamespaces == nil {
+		c.WatchNamespaces = base.WatchNamespaces
+	}
+	if c.SecretMetadataOnly == nil {
+		c.SecretMetadataOnly = base.SecretMetadataOnly
+	}
+	if c.BackupVerifyInterval == nil {
+		c.BackupVerifyInterval = base.BackupVerifyInterval
+	}
+	if c.RescueFlappingThreshold == nil {
+		c.RescueFlappingThreshold = base.RescueFlappingThreshold
+	}
+	if c.RescueFlappingWindow == nil {
+		c.RescueFlappingWindow = base.RescueFlappingWindow
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+var _ = Describe("ManagerConfig", func() {
+	var path string
+
+	write := func(content string) {
+		Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+	}
+
+	base := func() *ManagerConfig {
+		return &ManagerConfig{
+			TypeMeta:                metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+			LogLevel:                "info",
+			DryRun:                  ptr.To(false),
+			SecretMetadataOnly:      ptr.To(false),
+			BackupVerifyInterval:    &metav1.Duration{Duration: time.Hour},
+			RescueFlappingThreshold: ptr.To(5),
+			RescueFlappingWindow:    &metav1.Duration{Duration: 10 * time.Minute},
+		}
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+	})
+
+	It("should load a valid configuration", func() {
+		write(`
+apiVersion: config.terraform.hammadzf.github.io/v1alpha1
+kind: ManagerConfig
+logLevel: "2"
+dryRun: true
+watchNamespaces: [team-a, team-b]
+backupVerifyInterval: 30m
+staterescueDefaults:
+  deletionPolicy: Retain
+`)
+		config, err := Load(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.DryRun).To(HaveValue(BeTrue()))
+		Expect(config.WatchNamespaces).To(Equal([]string{"team-a", "team-b"}))
+		Expect(config.BackupVerifyInterval.Duration).To(Equal(30 * time.Minute))
+		Expect(config.StateRescueDefaults.DeletionPolicy).To(Equal(terraformv1.DeletionPolicyRetain))
+		Expect(config.Level()).To(Equal(zapcore.Level(-2)))
+	})
+
+	It("should reject invalid configurations", func() {
+		By("rejecting an unsupported version")
+		write("apiVersion: config.terraform.hammadzf.github.io/v2\nkind: ManagerConfig\n")
+		Expect(Load(path)).Error().To(MatchError(ContainSubstring("unsupported configuration")))
+
+		By("rejecting unknown fields")
+		write("apiVersion: config.terraform.hammadzf.github.io/v1alpha1\nkind: ManagerConfig\nretention: 3\n")
+		Expect(Load(path)).Error().To(HaveOccurred())
+
+		By("rejecting an invalid log level")
+		write("apiVersion: config.terraform.hammadzf.github.io/v1alpha1\nkind: ManagerConfig\nlogLevel: loud\n")
+		Expect(Load(path)).Error().To(MatchError(ContainSubstring("invalid log level")))
+
+		By("rejecting invalid StateRescue defaults")
+		write("apiVersion: config.terraform.hammadzf.github.io/v1alpha1\nkind: ManagerConfig\n" +
+			"staterescueDefaults:\n  generations: -1\n")
+		Expect(Load(path)).Error().To(MatchError(ContainSubstring("generations must not be negative")))
+	})
+
+	It("should keep the values of the flags for fields that are not set", func() {
+		write("apiVersion: config.terraform.hammadzf.github.io/v1alpha1\nkind: ManagerConfig\ndryRun: true\n")
+		store, err := NewStore(path, base())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.DryRun()).To(BeTrue())
+		Expect(store.Config().LogLevel).To(Equal("info"))
+		Expect(store.Config().RescueFlappingThreshold).To(HaveValue(Equal(5)))
+		Expect(store.StateRescueDefaults()).To(BeNil())
+	})
+
+	It("should reload the configuration when the file changes", func() {
+		write("apiVersion: config.terraform.hammadzf.github.io/v1alpha1\nkind: ManagerConfig\n")
+		store, err := NewStore(path, base())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.DryRun()).To(BeFalse())
+		Expect(store.LogLevel().Level()).To(Equal(zapcore.InfoLevel))
+
+		By("reloading the fields that can be changed while the manager is running")
+		write(`
+apiVersion: config.terraform.hammadzf.github.io/v1alpha1
+kind: ManagerConfig
+logLevel: debug
+dryRun: true
+staterescueDefaults:
+  generations: 3
+rescueFlappingThreshold: 10
+`)
+		Expect(store.reload()).To(BeTrue())
+		Expect(store.DryRun()).To(BeTrue())
+		Expect(store.LogLevel().Level()).To(Equal(zapcore.DebugLevel))
+		Expect(store.StateRescueDefaults().Generations).To(BeEquivalentTo(3))
+
+		By("keeping the fields that only take effect at startup")
+		Expect(store.Config().RescueFlappingThreshold).To(HaveValue(Equal(5)))
+
+		By("skipping unchanged files")
+		Expect(store.reload()).To(BeFalse())
+
+		By("keeping the current configuration if the file is invalid")
+		write("apiVersion: config.terraform.hammadzf.github.io/v1alpha1\nkind: ManagerConfig\ndryRun: maybe\n")
+		Expect(store.reload()).Error().To(HaveOccurred())
+		Expect(store.DryRun()).To(BeTrue())
+	})
+
+	It("should serve the effective configuration", func() {
+		store, err := NewStore("", base())
+		Expect(err).NotTo(HaveOccurred())
+
+		recorder := httptest.NewRecorder()
+		store.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/config", nil))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		served := map[string]any{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &served)).To(Succeed())
+		Expect(served).To(HaveKeyWithValue("kind", Kind))
+		Expect(served).To(HaveKeyWithValue("dryRun", false))
+		Expect(served).To(HaveKeyWithValue("backupVerifyInterval", "1h0m0s"))
+	})
+})
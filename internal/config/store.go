@@ -0,0 +1,176 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	webhookv1 "github.com/hammadzf/tf-state-rescuer/internal/webhook/v1"
+)
+
+// DefaultReloadInterval is how often the configuration file is checked for changes. Files mounted from a
+// ConfigMap are replaced through a symlink by the kubelet, so the file is polled instead of watched.
+const DefaultReloadInterval = 10 * time.Second
+
+var configlog = logf.Log.WithName("config")
+
+// Store holds the effective configuration of the controller manager and reloads it when the configuration
+// file changes. It serves the effective configuration as JSON, e.g. on a debug endpoint.
+type Store struct {
+	// path is the path of the configuration file, the configuration is never reloaded if empty
+	path string
+	// base holds the values of the flags for the fields that are not set in the configuration file
+	base *ManagerConfig
+	// level is the log level of the manager, it is changed when the configuration is reloaded
+	level zap.AtomicLevel
+
+	mu sync.Mutex
+	// current is the effective configuration, it is replaced but never modified when the configuration is reloaded
+	current *ManagerConfig
+	// data is the content of the configuration file that the current configuration was loaded from
+	data []byte
+}
+
+var _ manager.Runnable = &Store{}
+var _ manager.LeaderElectionRunnable = &Store{}
+var _ webhookv1.StateRescueDefaultsSource = &Store{}
+var _ http.Handler = &Store{}
+
+// NewStore loads the configuration file and sets the fields that it does not set to the values in base,
+// i.e. the flags of the manager. The configuration is only made of base if path is empty.
+func NewStore(path string, base *ManagerConfig) (*Store, error) {
+	level, err := base.Level()
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, base: base, current: base, level: zap.NewAtomicLevelAt(level)}
+	if path == "" {
+		return s, nil
+	}
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Config returns the effective configuration, it must not be modified
+func (s *Store) Config() *ManagerConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// LogLevel returns the log level of the manager that follows the reloaded configuration
+func (s *Store) LogLevel() zap.AtomicLevel {
+	return s.level
+}
+
+// DryRun returns whether the controller only plans backup and rescue actions for all StateRescue resources
+func (s *Store) DryRun() bool {
+	dryRun := s.Config().DryRun
+	return dryRun != nil && *dryRun
+}
+
+// StateRescueDefaults implements webhookv1.StateRescueDefaultsSource
+func (s *Store) StateRescueDefaults() *webhookv1.StateRescueDefaults {
+	return s.Config().StateRescueDefaults
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every replica follows the configuration
+func (s *Store) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable and reloads the configuration whenever the file changes until the
+// context is done. An invalid configuration is logged and the current configuration is kept.
+func (s *Store) Start(ctx context.Context) error {
+	if s.path == "" {
+		return nil
+	}
+	ticker := time.NewTicker(DefaultReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := s.reload(); err != nil {
+				configlog.Error(err, "unable to reload the manager configuration, keeping the current configuration")
+			}
+		}
+	}
+}
+
+// reload loads the configuration file if it changed since it was last loaded and applies the fields that can
+// be changed while the manager is running, it returns whether the configuration was reloaded
+func (s *Store) reload() (bool, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data != nil && bytes.Equal(s.data, data) {
+		return false, nil
+	}
+
+	config, err := Load(s.path)
+	if err != nil {
+		return false, err
+	}
+	config.complete(s.base)
+	level, _ := config.Level()
+	if s.data != nil {
+		// the other fields are only applied at startup, so the running values stay in effect
+		reloaded := *config
+		config.WatchNamespaces = s.current.WatchNamespaces
+		config.SecretMetadataOnly = s.current.SecretMetadataOnly
+		config.BackupVerifyInterval = s.current.BackupVerifyInterval
+		config.RescueFlappingThreshold = s.current.RescueFlappingThreshold
+		config.RescueFlappingWindow = s.current.RescueFlappingWindow
+		if !equality.Semantic.DeepEqual(&reloaded, config) {
+			configlog.Info("The manager configuration changed fields that only take effect when the manager is restarted")
+		}
+		configlog.Info("Reloaded the manager configuration", "path", s.path, "logLevel", config.LogLevel,
+			"dryRun", config.DryRun != nil && *config.DryRun)
+	}
+	s.level.SetLevel(level)
+	s.current = config
+	s.data = data
+	return true, nil
+}
+
+// ServeHTTP serves the effective configuration as JSON
+func (s *Store) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s.Config()); err != nil {
+		configlog.Error(err, "unable to serve the manager configuration")
+	}
+}
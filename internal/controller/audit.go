@@ -0,0 +1,68 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+const (
+	// ChangedByAnnotationKey records on a snapshot who changed the state it was taken of,
+	// as the JSON encoded change from the secret history of the StateRescue resource
+	ChangedByAnnotationKey = "terraform.hammadzf.github.io/changed-by"
+
+	// operationUpdate and operationDelete are the operations recorded in the secret history
+	operationUpdate = "UPDATE"
+	operationDelete = "DELETE"
+)
+
+// lastChange returns the most recent change of a secret with the given operation
+// from the secret history of the StateRescue resource, or nil if none was recorded
+func lastChange(stateRescue *terraformv1.StateRescue, secretName, operation string) *terraformv1.SecretChange {
+	history := stateRescue.Status.SecretHistory
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Secret == secretName && history[i].Operation == operation {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+// rescueMessage returns the message of the event of a rescue from the given copy of the secret,
+// naming who deleted the secret if it was recorded
+func rescueMessage(stateRescue *terraformv1.StateRescue, secretName string, from string) string {
+	message := fmt.Sprintf("Rescued secret %s from %s", secretName, from)
+	if change := lastChange(stateRescue, secretName, operationDelete); change != nil {
+		message += fmt.Sprintf(", it was deleted by %s at %s", change.User, change.Time.UTC().Format(time.RFC3339))
+	}
+	return message
+}
+
+// setChangedBy records who made the most recent change of a secret in the annotations of its snapshot
+func setChangedBy(annotations map[string]string, stateRescue *terraformv1.StateRescue, secretName string) {
+	change := lastChange(stateRescue, secretName, operationUpdate)
+	if change == nil {
+		return
+	}
+	if value, err := json.Marshal(change); err == nil {
+		annotations[ChangedByAnnotationKey] = string(value)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+const (
+	benchmarkOtherSecrets = 1000
+	benchmarkStateSecrets = 10
+	benchmarkSecretSize   = 4096
+)
+
+// BenchmarkSecretCache compares the heap used by a cache of all secrets in the cluster with the
+// cache restricted to Terraform state secrets by SecretCacheOptions. It starts its own test
+// environment, run it with:
+//
+//	go test ./internal/controller/ -run '^$' -bench BenchmarkSecretCache
+func BenchmarkSecretCache(b *testing.B) {
+	env := &envtest.Environment{}
+	if getFirstFoundEnvTestBinaryDir() != "" {
+		env.BinaryAssetsDirectory = getFirstFoundEnvTestBinaryDir()
+	}
+	restCfg, err := env.Start()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		if err := env.Stop(); err != nil {
+			b.Error(err)
+		}
+	}()
+
+	c, err := client.New(restCfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		b.Fatal(err)
+	}
+	// fill the cluster with many unrelated secrets and a few Terraform state secrets
+	data := map[string][]byte{"data": make([]byte, benchmarkSecretSize)}
+	for i := 0; i < benchmarkOtherSecrets+benchmarkStateSecrets; i++ {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("other-secret-%d", i),
+				Namespace: "default",
+			},
+			Data: data,
+		}
+		if i < benchmarkStateSecrets {
+			secret.Name = fmt.Sprintf("tfstate-default-state-%d", i)
+			secret.Labels = map[string]string{TfStateLabelKey: TfStateLabelValue}
+		}
+		if err := c.Create(context.Background(), secret); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, bc := range []struct {
+		name     string
+		byObject map[client.Object]cache.ByObject
+	}{
+		{name: "AllSecrets"},
+		{name: "StateSecrets", byObject: SecretCacheOptions()},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var heapBytes int64
+			for i := 0; i < b.N; i++ {
+				heapBytes += cacheHeapBytes(b, restCfg, bc.byObject)
+			}
+			b.ReportMetric(float64(heapBytes)/float64(b.N), "heap-bytes/op")
+		})
+	}
+}
+
+// cacheHeapBytes starts a secret cache with the given configuration and returns
+// the heap retained by the cache once it has been synced
+func cacheHeapBytes(b *testing.B, restCfg *rest.Config, byObject map[client.Object]cache.ByObject) int64 {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	secretCache, err := cache.New(restCfg, cache.Options{Scheme: scheme.Scheme, ByObject: byObject})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := secretCache.GetInformer(ctx, &corev1.Secret{}); err != nil {
+		b.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := secretCache.Start(ctx); err != nil {
+			b.Error(err)
+		}
+	}()
+	if !secretCache.WaitForCacheSync(ctx) {
+		b.Fatal("secret cache did not sync")
+	}
+	withCache := heapAlloc()
+
+	// stop the cache and measure the heap again once it can be collected
+	cancel()
+	<-stopped
+	withoutCache := heapAlloc()
+	return int64(withCache) - int64(withoutCache)
+}
+
+// heapAlloc returns the bytes of allocated heap objects after a garbage collection
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+// FieldManager is the field manager of the state secrets rescued by the controller,
+// it is never reported as a system that deleted a state secret
+const FieldManager = "tf-state-rescuer"
+
+// rescueHistory tracks the recent rescues of state secrets and the last writers of deleted state secrets,
+// so that another system that keeps deleting a state secret can be detected. The history is kept in memory
+// and starts empty whenever the controller manager starts.
+type rescueHistory struct {
+	mu sync.Mutex
+	// rescues holds the times of the recent rescues of each state secret
+	rescues map[types.NamespacedName][]time.Time
+	// lastWriters holds the field managers of each state secret when it was last deleted
+	lastWriters map[types.NamespacedName][]string
+}
+
+// newRescueHistory returns an empty rescue history
+func newRescueHistory() *rescueHistory {
+	return &rescueHistory{
+		rescues:     map[types.NamespacedName][]time.Time{},
+		lastWriters: map[types.NamespacedName][]string{},
+	}
+}
+
+// recordRescue records a rescue of a state secret and forgets rescues outside of the window
+func (h *rescueHistory) recordRescue(key types.NamespacedName, now time.Time, window time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rescues[key] = append(h.recent(key, now, window), now)
+}
+
+// recordDeletion records the field managers of a deleted state secret as its last writers. The managers only show
+// which systems wrote the secret before it was deleted, the deleting user is not part of the object.
+func (h *rescueHistory) recordDeletion(obj client.Object) {
+	if h == nil {
+		return
+	}
+	managers := []string{}
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != "" && entry.Manager != FieldManager && !slices.Contains(managers, entry.Manager) {
+			managers = append(managers, entry.Manager)
+		}
+	}
+	// keep the managers of an earlier deletion if only the controller wrote the rescued secret since then
+	if len(managers) == 0 {
+		return
+	}
+	slices.Sort(managers)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastWriters[types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}] = managers
+}
+
+// flapping reports whether a state secret was rescued at least threshold times within the window, along with
+// the time until its oldest rescue leaves the window and a description of the rescues. deletedBy is the user
+// that last deleted the state secret as recorded by the secret audit webhook, if any.
+func (h *rescueHistory) flapping(key types.NamespacedName, now time.Time, threshold int, window time.Duration, deletedBy string) (time.Duration, string) {
+	if h == nil || threshold <= 0 {
+		return 0, ""
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	recent := h.recent(key, now, window)
+	if len(recent) < threshold {
+		return 0, ""
+	}
+	// the message only changes with the rescues, so that the condition does not trigger needless reconciliations
+	closes := recent[len(recent)-threshold].Add(window)
+	message := fmt.Sprintf("%s was rescued %d times within %s, rescuing it again after %s", key.Name, len(recent), window, closes.UTC().Format(time.RFC3339))
+	if deletedBy != "" {
+		message += fmt.Sprintf(", it was last deleted by %s", deletedBy)
+	}
+	if managers := h.lastWriters[key]; len(managers) > 0 {
+		message += fmt.Sprintf(", its last writers before it was deleted were %s", strings.Join(managers, ", "))
+	}
+	return closes.Sub(now), message
+}
+
+// recent returns the rescues of a state secret within the window, the caller must hold the lock
+func (h *rescueHistory) recent(key types.NamespacedName, now time.Time, window time.Duration) []time.Time {
+	recent := []time.Time{}
+	for _, rescued := range h.rescues[key] {
+		if now.Sub(rescued) < window {
+			recent = append(recent, rescued)
+		}
+	}
+	return recent
+}
+
+// deletionRecorder records the last writers of deleted state secrets in the rescue history
+// before passing the delete events on to the wrapped event handler
+type deletionRecorder struct {
+	handler.EventHandler
+	history *rescueHistory
+}
+
+// Delete implements handler.EventHandler
+func (d deletionRecorder) Delete(ctx context.Context, evt event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	if val, ok := evt.Object.GetLabels()[TfStateLabelKey]; ok && val == TfStateLabelValue {
+		d.history.recordDeletion(evt.Object)
+	}
+	d.EventHandler.Delete(ctx, evt, q)
+}
+
+// openBreakers collects the state secrets of a reconciliation that are not rescued since they are rescued too often,
+// along with the time until the first of their circuit breakers closes
+type openBreakers struct {
+	messages   []string
+	retryAfter time.Duration
+}
+
+// rescueTripped reports whether a state secret that is about to be rescued is rescued too often, e.g. because another
+// system keeps deleting it, and records its open circuit breaker. It is not rescued then until its oldest rescue
+// leaves the window, regardless of whether it would be rescued from its backup or from its replica.
+func (r *StateRescueReconciler) rescueTripped(stateRescue *terraformv1.StateRescue, key types.NamespacedName, breakers *openBreakers) bool {
+	deletedBy := ""
+	if change := lastChange(stateRescue, key.Name, operationDelete); change != nil {
+		deletedBy = change.User
+	}
+	wait, message := r.rescueHistory.flapping(key, time.Now(), r.RescueFlappingThreshold, r.RescueFlappingWindow, deletedBy)
+	if wait <= 0 {
+		return false
+	}
+	breakers.messages = append(breakers.messages, message)
+	if breakers.retryAfter == 0 || wait < breakers.retryAfter {
+		breakers.retryAfter = wait
+	}
+	return true
+}
+
+// recordRescued records the rescue of a state secret in the rescue history and emits a Rescued event
+func (r *StateRescueReconciler) recordRescued(stateRescue *terraformv1.StateRescue, key types.NamespacedName, from string) {
+	if r.planned != nil {
+		return
+	}
+	r.rescueHistory.recordRescue(key, time.Now(), r.RescueFlappingWindow)
+	r.Recorder.Event(stateRescue, corev1.EventTypeNormal, "Rescued", rescueMessage(stateRescue, key.Name, from))
+}
+
+// setFlapping sets the RescueFlapping condition from the state secrets that are no longer rescued
+// and reports whether the condition changed
+func setFlapping(stateRescue *terraformv1.StateRescue, flapping []string) bool {
+	condition := metav1.Condition{
+		Type:               terraformv1.ConditionRescueFlapping,
+		Status:             metav1.ConditionFalse,
+		Reason:             "RescuesWithinLimit",
+		Message:            "No state secret is rescued more often than allowed",
+		ObservedGeneration: stateRescue.Generation,
+	}
+	if len(flapping) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "CircuitBreakerOpen"
+		condition.Message = strings.Join(flapping, "; ")
+	}
+	return meta.SetStatusCondition(&stateRescue.Status.Conditions, condition)
+}
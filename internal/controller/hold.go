@@ -0,0 +1,206 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+const (
+	// HoldAnnotationKey holds a single snapshot indefinitely, the annotation value records why,
+	// e.g. the number of an audit case
+	HoldAnnotationKey = "terraform.hammadzf.github.io/hold"
+	// SnapshotHeldLabelKey marks snapshots that are held by a hold of their StateRescue resource or the hold annotation,
+	// held snapshots are never pruned and are retained when their StateRescue resource is deleted
+	SnapshotHeldLabelKey = "terraform.hammadzf.github.io/held"
+	// annotationHold is the name of a hold set with the hold annotation in the status
+	annotationHold = "annotation"
+)
+
+// holdsOf returns the names of the holds of a snapshot, snapshots whose states cannot be decoded are never
+// held by serial
+func holdsOf(stateRescue *terraformv1.StateRescue, snapshot *corev1.Secret) []string {
+	holds := []string{}
+	if _, ok := snapshot.Annotations[HoldAnnotationKey]; ok {
+		holds = append(holds, annotationHold)
+	}
+	var serial *int64
+	for _, hold := range stateRescue.Spec.Holds {
+		switch {
+		case hold.Tag != "":
+			if snapshot.Labels[terraformv1.SnapshotTagLabelPrefix+hold.Tag] == "true" {
+				holds = append(holds, hold.Name)
+			}
+		case hold.Serial != nil:
+			// the state is only decoded once it is needed
+			if serial == nil {
+				serial = ptr.To(int64(-1))
+				if current, err := state.FromSecret(snapshot); err == nil {
+					serial = ptr.To(current.Serial)
+				}
+			}
+			if *serial == *hold.Serial {
+				holds = append(holds, hold.Name)
+			}
+		}
+	}
+	return holds
+}
+
+// held reports whether a snapshot is held by a hold of the StateRescue resource, the hold annotation or the held label
+func held(stateRescue *terraformv1.StateRescue, snapshot *corev1.Secret) bool {
+	if snapshot.Labels[SnapshotLabelKey] != "true" {
+		return false
+	}
+	return snapshot.Labels[SnapshotHeldLabelKey] == "true" || len(holdsOf(stateRescue, snapshot)) > 0
+}
+
+// enforceHolds labels the snapshots of the StateRescue resource that are selected by its holds or the hold annotation
+// as held, removes the label from snapshots that are no longer held and records the held snapshots in the status
+func (r *StateRescueReconciler) enforceHolds(ctx context.Context, stateRescue *terraformv1.StateRescue) error {
+	log := logf.FromContext(ctx)
+
+	secrets := &corev1.SecretList{}
+	if err := r.secretReader().List(ctx, secrets, client.InNamespace(stateRescue.Namespace), client.MatchingLabels{SnapshotLabelKey: "true"}); err != nil {
+		log.Error(err, "unable to fetch snapshots in the state rescue resource namespace")
+		return err
+	}
+	sort.Slice(secrets.Items, func(i, j int) bool { return secrets.Items[i].Name < secrets.Items[j].Name })
+
+	held := []terraformv1.HeldSnapshot{}
+	for i := range secrets.Items {
+		item := &secrets.Items[i]
+		if !metav1.IsControlledBy(item, stateRescue) {
+			continue
+		}
+		holds := holdsOf(stateRescue, item)
+		if len(holds) > 0 {
+			held = append(held, terraformv1.HeldSnapshot{
+				Snapshot: item.Name,
+				Secret:   item.Annotations[SnapshotOfAnnotationKey],
+				Holds:    holds,
+			})
+		}
+		if (len(holds) > 0) == (item.Labels[SnapshotHeldLabelKey] == "true") {
+			continue
+		}
+
+		// only the data of snapshots is immutable, so they can still be labelled
+		patch := client.MergeFrom(item.DeepCopy())
+		if len(holds) > 0 {
+			if item.Labels == nil {
+				item.Labels = map[string]string{}
+			}
+			item.Labels[SnapshotHeldLabelKey] = "true"
+			r.recordAction(stateRescue, terraformv1.ActionHoldSnapshot, item.Annotations[SnapshotOfAnnotationKey])
+			log.Info("Holding the snapshot", "Snapshot", item.Name, "Holds", holds)
+		} else {
+			delete(item.Labels, SnapshotHeldLabelKey)
+			r.recordAction(stateRescue, terraformv1.ActionReleaseSnapshot, item.Annotations[SnapshotOfAnnotationKey])
+			log.Info("Releasing the snapshot that is no longer held", "Snapshot", item.Name)
+		}
+		if err := r.Patch(ctx, item, patch); err != nil {
+			log.Error(err, "unable to label the snapshot", "Snapshot", item.Name)
+			return err
+		}
+	}
+
+	// no held snapshots leave the status unset, so that it compares equal to a status without holds
+	if len(held) == 0 {
+		held = nil
+	}
+	if equality.Semantic.DeepEqual(stateRescue.Status.HeldSnapshots, held) {
+		return nil
+	}
+	stateRescue.Status.HeldSnapshots = held
+	if err := r.Status().Update(ctx, stateRescue); err != nil {
+		log.Error(err, "unable to update state rescue resource")
+		return err
+	}
+	return nil
+}
+
+// replicateHeld copies the held snapshots of the StateRescue resource to the remote cluster, so that the held states
+// are kept in every backup destination. The copies are only created and never updated or deleted by the controller.
+// They do not carry the replica label, so that they are never used to restore states into an empty cluster,
+// and are listed separately by the backup verification.
+func (r *StateRescueReconciler) replicateHeld(ctx context.Context, stateRescue *terraformv1.StateRescue, remoteClient client.Client, signer *signer) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	replicated := false
+	for _, held := range stateRescue.Status.HeldSnapshots {
+		err := remoteClient.Get(ctx, types.NamespacedName{Name: held.Snapshot, Namespace: remoteNamespace(stateRescue)}, &corev1.Secret{})
+		if err == nil {
+			continue
+		} else if !errors.IsNotFound(err) {
+			return replicated, fmt.Errorf("unable to fetch held snapshot %s: %w", held.Snapshot, err)
+		}
+		snapshot := &corev1.Secret{}
+		if err := r.secretReader().Get(ctx, types.NamespacedName{Name: held.Snapshot, Namespace: stateRescue.Namespace}, snapshot); err != nil {
+			return replicated, fmt.Errorf("unable to fetch held snapshot %s: %w", held.Snapshot, err)
+		}
+
+		annotations, err := replicaAnnotations(stateRescue, snapshot)
+		if err != nil {
+			return replicated, err
+		}
+		data := snapshot.Data
+		if redact := stateRescue.Spec.Destination.RemoteCluster.Redact; redact != nil {
+			if data, err = redactedData(snapshot, redact); err != nil {
+				return replicated, fmt.Errorf("unable to redact state of held snapshot %s: %w", held.Snapshot, err)
+			}
+			annotations[RedactedAnnotationKey] = "true"
+		}
+		setChecksums(annotations, data)
+		labels := maps.Clone(snapshot.Labels)
+		delete(labels, TfStateLabelKey)
+		labels[SnapshotHeldLabelKey] = "true"
+		copied := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        snapshot.Name,
+				Namespace:   remoteNamespace(stateRescue),
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Immutable: ptr.To(true),
+			Data:      data,
+		}
+		signer.sign(copied, snapshot.Name)
+		r.recordAction(stateRescue, terraformv1.ActionReplicate, held.Secret)
+		log.Info("Copying the held snapshot to the remote cluster", "Snapshot", held.Snapshot)
+		if err := remoteClient.Create(ctx, copied); err != nil {
+			return replicated, fmt.Errorf("unable to copy held snapshot %s: %w", held.Snapshot, err)
+		}
+		replicated = true
+	}
+	return replicated, nil
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+const (
+	// LockInfoAnnotationKey is the annotation in which the Kubernetes backend of terraform records the lock info
+	// on the lock lease of a state secret while a run holds the lock
+	LockInfoAnnotationKey = "app.terraform.io/lock-info"
+	// RunAnnotationKey records on a snapshot or backup secret the JSON encoded lock info of the terraform run
+	// that produced its state
+	RunAnnotationKey = "terraform.hammadzf.github.io/run"
+	// lockLeasePrefix is the prefix of the names of the lock leases of state secrets
+	lockLeasePrefix = "lock-"
+)
+
+// LockInfo is the lock info that terraform records on the lock lease of a state secret
+type LockInfo struct {
+	// ID is the unique ID of the lock
+	ID string `json:"ID"`
+	// Operation is the terraform operation that acquired the lock, e.g. OperationTypeApply
+	Operation string `json:"Operation"`
+	// Info is extra information recorded with the lock, e.g. by terraform state mv
+	Info string `json:"Info,omitempty"`
+	// Who is the user and host that acquired the lock
+	Who string `json:"Who"`
+	// Version is the terraform version that acquired the lock
+	Version string `json:"Version"`
+	// Created is when the lock was acquired
+	Created time.Time `json:"Created"`
+	// Path is the path of the locked state
+	Path string `json:"Path,omitempty"`
+}
+
+// lockInfo decodes the lock info on a lock lease, it returns nil if the lease is not locked
+func lockInfo(lease client.Object) *LockInfo {
+	value, ok := lease.GetAnnotations()[LockInfoAnnotationKey]
+	if !ok {
+		return nil
+	}
+	lock := &LockInfo{}
+	if err := json.Unmarshal([]byte(value), lock); err != nil || lock.ID == "" {
+		return nil
+	}
+	return lock
+}
+
+// runHistory holds the lock info of the most recently released lock of each state secret until the snapshot
+// and the backup of the state produced by the run have been attributed to it. The history is kept in memory
+// and starts empty whenever the controller manager starts.
+type runHistory struct {
+	mu sync.Mutex
+	// released holds the lock info of the most recently released lock of each state secret
+	released map[types.NamespacedName]LockInfo
+	// backedUp holds when the backup of each state secret was last updated with a changed state, backups have
+	// no snapshot time to decide whether a run changed the state
+	backedUp map[types.NamespacedName]time.Time
+}
+
+// newRunHistory returns an empty run history
+func newRunHistory() *runHistory {
+	return &runHistory{released: map[types.NamespacedName]LockInfo{}, backedUp: map[types.NamespacedName]time.Time{}}
+}
+
+// recordBackup records that the backup of a state secret was updated with a changed state
+func (h *runHistory) recordBackup(key types.NamespacedName, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.backedUp[key] = at
+}
+
+// lastBackup returns when the backup of a state secret was last updated with a changed state
+func (h *runHistory) lastBackup(key types.NamespacedName) (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	at, ok := h.backedUp[key]
+	return at, ok
+}
+
+// recordRelease records the lock info of a released lock of a state secret
+func (h *runHistory) recordRelease(key types.NamespacedName, lock LockInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.released[key] = lock
+}
+
+// releasedLock returns the lock info of the most recently released lock of a state secret
+func (h *runHistory) releasedLock(key types.NamespacedName) (LockInfo, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lock, ok := h.released[key]
+	return lock, ok
+}
+
+// forget removes the released lock of a state secret, unless another lock has been released in the meantime
+func (h *runHistory) forget(key types.NamespacedName, id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.released[key].ID == id {
+		delete(h.released, key)
+	}
+}
+
+// lockReleaseRecorder records the lock info of released lock leases in the run history and enqueues the
+// StateRescue resources of their state secrets, other events of lock leases are ignored
+func (r *StateRescueReconciler) lockReleaseRecorder() handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(ctx context.Context, evt event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			lock := lockInfo(evt.ObjectOld)
+			if lock == nil || lockInfo(evt.ObjectNew) != nil {
+				return
+			}
+			secretName, found := strings.CutPrefix(evt.ObjectNew.GetName(), lockLeasePrefix)
+			if !found {
+				return
+			}
+			r.runHistory.recordRelease(types.NamespacedName{Name: secretName, Namespace: evt.ObjectNew.GetNamespace()}, *lock)
+			// the lock lease carries the same labels as its state secret
+			secret := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: evt.ObjectNew.GetNamespace(),
+				Labels:    evt.ObjectNew.GetLabels(),
+			}}
+			for _, req := range r.findStateRescuesForSecret(ctx, secret) {
+				q.Add(req)
+			}
+		},
+	}
+}
+
+// attributeRuns attributes the snapshots and backups of the current states of the original secrets to the terraform
+// runs that released their locks. A snapshot or backup is only attributed if it was written after the lock was
+// acquired, i.e. the run changed the state. Released locks of runs that left the state unchanged, e.g. plans,
+// are forgotten. Backups are attributed as well, so that runs are recorded even if generations are disabled.
+func (r *StateRescueReconciler) attributeRuns(ctx context.Context, stateRescue *terraformv1.StateRescue, original *corev1.SecretList) error {
+	log := logf.FromContext(ctx)
+
+	for i := range original.Items {
+		item := &original.Items[i]
+		key := client.ObjectKeyFromObject(item)
+		lock, ok := r.runHistory.releasedLock(key)
+		if !ok {
+			continue
+		}
+		// the snapshot is read from the API server since it may have been written in this reconciliation
+		snapshot := &corev1.Secret{}
+		err := r.APIReader.Get(ctx, types.NamespacedName{Name: snapshotName(item.Name, item.Data), Namespace: item.Namespace}, snapshot)
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to fetch the snapshot of the original secret", "Secret", item.Name)
+			return err
+		}
+		if err == nil && metav1.IsControlledBy(snapshot, stateRescue) && snapshotTime(snapshot).After(lock.Created) {
+			if _, attributed := snapshot.Annotations[RunAnnotationKey]; !attributed {
+				// only the data of snapshots is immutable, so they can still be annotated
+				if err := r.attributeRun(ctx, snapshot, lock); err != nil {
+					log.Error(err, "unable to attribute the snapshot", "Secret", item.Name)
+					return err
+				}
+			}
+		}
+		if backedUp, ok := r.runHistory.lastBackup(key); ok && backedUp.After(lock.Created) {
+			backupSecret := &corev1.Secret{}
+			err := r.APIReader.Get(ctx, types.NamespacedName{Name: "backup-" + item.Name, Namespace: item.Namespace}, backupSecret)
+			if client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to fetch the backup of the original secret", "Secret", item.Name)
+				return err
+			}
+			// the backup is left alone if it no longer holds the state produced by the run
+			if err == nil && equality.Semantic.DeepEqual(backupSecret.Data, item.Data) {
+				if err := r.attributeRun(ctx, backupSecret, lock); err != nil {
+					log.Error(err, "unable to attribute the backup", "Secret", item.Name)
+					return err
+				}
+			}
+		}
+		r.runHistory.forget(key, lock.ID)
+	}
+	return nil
+}
+
+// attributeRun records the lock info of a terraform run on a snapshot or backup secret unless it is recorded already
+func (r *StateRescueReconciler) attributeRun(ctx context.Context, secret *corev1.Secret, lock LockInfo) error {
+	value, err := json.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	if secret.Annotations[RunAnnotationKey] == string(value) {
+		return nil
+	}
+	patch := client.MergeFrom(secret.DeepCopy())
+	metav1.SetMetaDataAnnotation(&secret.ObjectMeta, RunAnnotationKey, string(value))
+	logf.FromContext(ctx).Info("Attributing the state to the terraform run", "Secret", secret.Name,
+		"Operation", lock.Operation, "Who", lock.Who)
+	return r.Patch(ctx, secret, patch)
+}
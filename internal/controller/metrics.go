@@ -0,0 +1,65 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// plannedActions reports the number of actions per type that the controller
+	// would take for a StateRescue resource in dry-run mode
+	plannedActions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "staterescue_planned_actions",
+			Help: "Number of actions the controller would take for a StateRescue resource in dry-run mode",
+		},
+		[]string{"namespace", "staterescue", "action"},
+	)
+	// replicationLag reports the time by which the replicated backups in a remote cluster
+	// lag behind the local backups of a StateRescue resource
+	replicationLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "staterescue_replication_lag_seconds",
+			Help: "Time by which the backups replicated to a remote cluster lag behind the local backups",
+		},
+		[]string{"namespace", "staterescue"},
+	)
+	// backupVerified reports whether all backups of a StateRescue resource passed the last verification
+	backupVerified = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "staterescue_backup_verified",
+			Help: "Whether all backups of a StateRescue resource passed the last verification against their checksums",
+		},
+		[]string{"namespace", "staterescue"},
+	)
+	// resourceDropPercent reports the percentage of resource instances that a state lost against its backup
+	// when it was last changed, for StateRescue resources with protection against mass resource drops
+	resourceDropPercent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "staterescue_resource_drop_percent",
+			Help: "Percentage of resource instances that a state lost against its backup when it was last changed",
+		},
+		[]string{"namespace", "staterescue", "secret"},
+	)
+)
+
+func init() {
+	// register custom metrics with the global prometheus registry of controller-runtime
+	metrics.Registry.MustRegister(plannedActions, replicationLag, backupVerified, resourceDropPercent)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+// AcknowledgeResourceDropAnnotationKey acknowledges the resource drop of the quarantined state secret named
+// in the annotation value, so that its backup is updated again, the annotation is removed once it has been handled
+const AcknowledgeResourceDropAnnotationKey = "terraform.hammadzf.github.io/acknowledge-resource-drop"
+
+// resourceDrop returns the percentage of the resource instances of the backed up state that the state of
+// the original secret lost, states that cannot be decoded or have no resources never lose any
+func resourceDrop(backupSecret, original *corev1.Secret) (float64, string) {
+	previous, err := state.FromSecret(backupSecret)
+	if err != nil {
+		return 0, ""
+	}
+	current, err := state.FromSecret(original)
+	if err != nil {
+		return 0, ""
+	}
+	before, after := len(previous.Addresses()), len(current.Addresses())
+	if before == 0 || after >= before {
+		return 0, ""
+	}
+	percent := float64(before-after) * 100 / float64(before)
+	return percent, fmt.Sprintf("%d of %d resource instances (%.0f%%) were dropped", before-after, before, percent)
+}
+
+// guardResourceDrop detects original secrets whose states lost more resource instances than allowed against their
+// backups and pins the snapshots of their previous states. Unless the resource drop must be acknowledged first,
+// the backups are updated as usual. It returns the original secrets that may be backed up.
+func (r *StateRescueReconciler) guardResourceDrop(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.SecretList, backup *corev1.SecretList) (*corev1.SecretList, error) {
+	log := logf.FromContext(ctx)
+	protection := stateRescue.Spec.Protection
+	acknowledged, ackRequested := stateRescue.Annotations[AcknowledgeResourceDropAnnotationKey]
+
+	guarded := &corev1.SecretList{}
+	quarantined := []string{}
+	for i := range original.Items {
+		item := &original.Items[i]
+		backupSecret := findSecret(backup, "backup-"+item.Name)
+		if backupSecret == nil || equality.Semantic.DeepEqual(backupSecret.Data, item.Data) {
+			guarded.Items = append(guarded.Items, *item)
+			continue
+		}
+		percent, reason := resourceDrop(backupSecret, item)
+		resourceDropPercent.WithLabelValues(stateRescue.Namespace, stateRescue.Name, item.Name).Set(percent)
+		if percent <= float64(protection.MaxResourceDropPercent) {
+			guarded.Items = append(guarded.Items, *item)
+			continue
+		}
+
+		// keep the previous state regardless of the number of kept generations
+		if err := r.pinSnapshot(ctx, stateRescue, signer, item, backupSecret.Data); err != nil {
+			return nil, err
+		}
+		if !protection.RequireAcknowledgement || (ackRequested && acknowledged == item.Name) {
+			log.Info("backing up a state that dropped more resources than allowed", "Secret", item.Name, "reason", reason)
+			if r.planned == nil {
+				r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "MassResourceDrop",
+					"Secret %s: %s, the snapshot of the previous state is pinned", item.Name, reason)
+			}
+			guarded.Items = append(guarded.Items, *item)
+			continue
+		}
+		log.Info("quarantining a state that dropped more resources than allowed", "Secret", item.Name, "reason", reason)
+		quarantined = append(quarantined, fmt.Sprintf("%s: %s", item.Name, reason))
+	}
+
+	changed := setQuarantined(stateRescue, quarantined)
+	// the warning is only emitted when the quarantined states change to avoid an event on every reconciliation
+	if r.planned == nil && changed && len(quarantined) > 0 {
+		r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "MassResourceDrop",
+			"Keeping the backups of quarantined states until the resource drop is acknowledged: %s", strings.Join(quarantined, "; "))
+	}
+	// the status is updated before the acknowledgement is removed, since updates replace the in-memory object
+	// with the response of the API server
+	if changed {
+		if err := r.Status().Update(ctx, stateRescue); err != nil {
+			log.Error(err, "unable to update state rescue resource")
+			return nil, err
+		}
+	}
+	if ackRequested {
+		// remove the handled acknowledgement
+		patch := client.MergeFrom(stateRescue.DeepCopy())
+		delete(stateRescue.Annotations, AcknowledgeResourceDropAnnotationKey)
+		if err := r.Patch(ctx, stateRescue, patch); err != nil {
+			log.Error(err, "unable to remove the acknowledgement from state rescue resource")
+			return nil, err
+		}
+	}
+	return guarded, nil
+}
+
+// setQuarantined sets the StateQuarantined condition from the quarantined states and reports whether the condition changed
+func setQuarantined(stateRescue *terraformv1.StateRescue, quarantined []string) bool {
+	condition := metav1.Condition{
+		Type:               terraformv1.ConditionStateQuarantined,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NoMassResourceDrop",
+		Message:            "No state is quarantined",
+		ObservedGeneration: stateRescue.Generation,
+	}
+	if len(quarantined) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ResourceDropUnacknowledged"
+		condition.Message = strings.Join(quarantined, "; ")
+	}
+	return meta.SetStatusCondition(&stateRescue.Status.Conditions, condition)
+}
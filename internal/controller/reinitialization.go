@@ -0,0 +1,202 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+const (
+	// ReinitializedAnnotationKey marks the backup secret of a re-initialised state, the backup holds the previous
+	// lineage until the re-initialisation is acknowledged, even once the new lineage has caught up with it
+	ReinitializedAnnotationKey = "terraform.hammadzf.github.io/reinitialized"
+	// AcknowledgeReinitializationAnnotationKey acknowledges the re-initialisation of the state secret named in the
+	// annotation value, so that its backup is updated with the new lineage, the annotation is removed once it has
+	// been handled
+	AcknowledgeReinitializationAnnotationKey = "terraform.hammadzf.github.io/acknowledge-reinitialization"
+)
+
+// reinitialized reports why the state of an original secret replaced the lineage tracked by its backup,
+// or returns an empty string if it did not. Terraform never continues a state of another lineage, so a new
+// lineage of a fresh state, i.e. one without resources or with a lower serial than the backup, means that
+// terraform initialised it, e.g. because the state secret was deleted and terraform ran before the controller
+// could rescue it. A new lineage alone is not reported, e.g. a state migrated with terraform state push, unless
+// the backup is already held for a re-initialisation. States that cannot be decoded are never reported.
+func reinitialized(backupSecret, original *corev1.Secret) string {
+	if backupSecret == nil {
+		return ""
+	}
+	previous, err := state.FromSecret(backupSecret)
+	if err != nil || previous.Lineage == "" {
+		return ""
+	}
+	current, err := state.FromSecret(original)
+	if err != nil || current.Lineage == previous.Lineage {
+		return ""
+	}
+	reason := fmt.Sprintf("lineage %s (serial %d) replaced lineage %s (serial %d)",
+		current.Lineage, current.Serial, previous.Lineage, previous.Serial)
+	if _, held := backupSecret.Annotations[ReinitializedAnnotationKey]; held {
+		return reason
+	}
+	if len(current.Addresses()) > 0 && current.Serial >= previous.Serial {
+		return ""
+	}
+	return reason
+}
+
+// guardReinitialized detects original secrets that terraform re-initialised with a new lineage, their backups
+// are never overwritten until the re-initialisation is acknowledged. The previous lineage and the new state are
+// kept in pinned snapshots, then depending on the reinitialization policy, the backup of the previous lineage is
+// held or restored over the new state. It returns the original secrets that may be backed up.
+func (r *StateRescueReconciler) guardReinitialized(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.SecretList, backup *corev1.SecretList) (*corev1.SecretList, error) {
+	log := logf.FromContext(ctx)
+	acknowledged, ackRequested := stateRescue.Annotations[AcknowledgeReinitializationAnnotationKey]
+
+	guarded := &corev1.SecretList{}
+	held := []string{}
+	restored := []string{}
+	for i := range original.Items {
+		item := &original.Items[i]
+		backupSecret := findSecret(backup, "backup-"+item.Name)
+		reason := reinitialized(backupSecret, item)
+		if reason == "" {
+			guarded.Items = append(guarded.Items, *item)
+			continue
+		}
+		// keep the previous lineage regardless of the number of kept generations, and the new state as well,
+		// since it is never backed up while the backup is held
+		if err := r.pinSnapshot(ctx, stateRescue, signer, item, backupSecret.Data); err != nil {
+			return nil, err
+		}
+		if err := r.pinSnapshot(ctx, stateRescue, signer, item, item.Data); err != nil {
+			return nil, err
+		}
+		if ackRequested && acknowledged == item.Name {
+			// the backup loses the held mark once it is updated with the new lineage
+			log.Info("backing up the new lineage of an acknowledged re-initialisation", "Secret", item.Name, "reason", reason)
+			guarded.Items = append(guarded.Items, *item)
+			continue
+		}
+		if stateRescue.Spec.ReinitializationPolicy != terraformv1.ReinitializationPolicyRestore {
+			log.Info("holding the backup of a re-initialised state", "Secret", item.Name, "reason", reason)
+			if err := r.markReinitialized(ctx, backupSecret, reason); err != nil {
+				return nil, err
+			}
+			held = append(held, fmt.Sprintf("%s: %s", item.Name, reason))
+			continue
+		}
+		// never restore from a backup that has been modified since it was signed
+		if err := signer.verify(backupSecret.Name, backupSecret); err != nil {
+			if err := r.refuseTampered(ctx, stateRescue, err); err != nil {
+				return nil, err
+			}
+			if err := r.markReinitialized(ctx, backupSecret, reason); err != nil {
+				return nil, err
+			}
+			held = append(held, fmt.Sprintf("%s: %s", item.Name, reason))
+			continue
+		}
+		r.recordAction(stateRescue, terraformv1.ActionRestore, item.Name)
+		log.Info("Restoring the previous lineage over the re-initialised state", "Secret", item.Name, "reason", reason)
+		item.Data = backupSecret.Data
+		if err := r.Update(ctx, item); err != nil {
+			log.Error(err, "unable to restore the original secret")
+			return nil, err
+		}
+		restored = append(restored, item.Name)
+		guarded.Items = append(guarded.Items, *item)
+	}
+
+	changed := setReinitialized(stateRescue, held)
+	if r.planned == nil {
+		// the warning is only emitted when the held backups change to avoid an event on every reconciliation
+		if changed && len(held) > 0 {
+			r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "StateReinitialized",
+				"Holding the backups of re-initialised states: %s", strings.Join(held, "; "))
+		}
+		for _, name := range restored {
+			r.Recorder.Eventf(stateRescue, corev1.EventTypeNormal, "Restored", "Restored the previous lineage of re-initialised secret %s from its backup", name)
+		}
+	}
+	if len(restored) > 0 {
+		stateRescue.Status.LastRescueTime = metav1.Now()
+		changed = true
+	}
+	// the status is updated before the acknowledgement is removed, since updates replace the in-memory object
+	// with the response of the API server
+	if changed {
+		if err := r.Status().Update(ctx, stateRescue); err != nil {
+			log.Error(err, "unable to update state rescue resource")
+			return nil, err
+		}
+	}
+	if ackRequested {
+		// remove the handled acknowledgement
+		patch := client.MergeFrom(stateRescue.DeepCopy())
+		delete(stateRescue.Annotations, AcknowledgeReinitializationAnnotationKey)
+		if err := r.Patch(ctx, stateRescue, patch); err != nil {
+			log.Error(err, "unable to remove the acknowledgement from state rescue resource")
+			return nil, err
+		}
+	}
+	return guarded, nil
+}
+
+// markReinitialized marks the backup secret of a re-initialised state as held, so that it keeps holding the
+// previous lineage until the re-initialisation is acknowledged
+func (r *StateRescueReconciler) markReinitialized(ctx context.Context, backupSecret *corev1.Secret, reason string) error {
+	if _, held := backupSecret.Annotations[ReinitializedAnnotationKey]; held {
+		return nil
+	}
+	patch := client.MergeFrom(backupSecret.DeepCopy())
+	metav1.SetMetaDataAnnotation(&backupSecret.ObjectMeta, ReinitializedAnnotationKey, reason)
+	if err := r.Patch(ctx, backupSecret, patch); err != nil {
+		logf.FromContext(ctx).Error(err, "unable to mark the backup secret as held", "Secret", backupSecret.Name)
+		return err
+	}
+	return nil
+}
+
+// setReinitialized sets the StateReinitialized condition from the original secrets whose backups are held
+// and reports whether the condition changed
+func setReinitialized(stateRescue *terraformv1.StateRescue, held []string) bool {
+	condition := metav1.Condition{
+		Type:               terraformv1.ConditionStateReinitialized,
+		Status:             metav1.ConditionFalse,
+		Reason:             "LineageUnchanged",
+		Message:            "All states continue the lineage of their backups",
+		ObservedGeneration: stateRescue.Generation,
+	}
+	if len(held) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "BackupHeld"
+		condition.Message = strings.Join(held, "; ")
+	}
+	return meta.SetStatusCondition(&stateRescue.Status.Conditions, condition)
+}
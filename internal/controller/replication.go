@@ -0,0 +1,411 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+const (
+	// ReplicaLabelKey marks secrets in a remote cluster that are replicas of terraform state secrets
+	ReplicaLabelKey = "terraform.hammadzf.github.io/replica"
+	// SourceNamespaceAnnotationKey records the namespace of the original secret on its replica
+	SourceNamespaceAnnotationKey = "terraform.hammadzf.github.io/source-namespace"
+	// StateRescueAnnotationKey records the StateRescue resource that replicated a secret as JSON on the replica,
+	// so that the StateRescue resource can be recreated when restoring into an empty cluster
+	StateRescueAnnotationKey = "terraform.hammadzf.github.io/staterescue"
+	// RedactedAnnotationKey marks replicas with redacted states, which must never be used to rescue or restore states
+	RedactedAnnotationKey = "terraform.hammadzf.github.io/redacted"
+)
+
+// Redacted reports whether a replica holds a redacted state, which must never be used to rescue or restore a state.
+// The redaction marker in the state itself is authoritative, since the data of replicas is signed but the
+// annotation that the controller manages is not. The annotation is still honoured for states that cannot be decoded.
+func Redacted(replica *corev1.Secret) bool {
+	if replica.Annotations[RedactedAnnotationKey] == "true" {
+		return true
+	}
+	s, err := state.FromSecret(replica)
+	return err == nil && s.Redacted
+}
+
+// remoteClientCache holds the clients for remote clusters per kubeconfig secret and key, so that a client,
+// its REST mapper and its connections are only created again when the kubeconfig secret has changed.
+// The cache is kept in memory and starts empty whenever the controller manager starts.
+type remoteClientCache struct {
+	mu      sync.Mutex
+	entries map[remoteClientKey]remoteClientEntry
+}
+
+// remoteClientKey identifies a kubeconfig in a kubeconfig secret
+type remoteClientKey struct {
+	secret types.NamespacedName
+	key    string
+}
+
+// remoteClientEntry is a client created from the kubeconfig secret with the given resource version
+type remoteClientEntry struct {
+	resourceVersion string
+	client          client.Client
+}
+
+// newRemoteClientCache returns an empty remote client cache
+func newRemoteClientCache() *remoteClientCache {
+	return &remoteClientCache{entries: map[remoteClientKey]remoteClientEntry{}}
+}
+
+// get returns the cached client for the kubeconfig in a kubeconfig secret,
+// unless the secret has changed since the client was created
+func (c *remoteClientCache) get(secret types.NamespacedName, key string, resourceVersion string) (client.Client, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[remoteClientKey{secret: secret, key: key}]
+	if !ok || entry.resourceVersion != resourceVersion {
+		return nil, false
+	}
+	return entry.client, true
+}
+
+// put caches the client created from the kubeconfig in a kubeconfig secret with the given resource version,
+// replacing the client created from an older version of the secret
+func (c *remoteClientCache) put(secret types.NamespacedName, key string, resourceVersion string, remoteClient client.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[remoteClientKey{secret: secret, key: key}] = remoteClientEntry{resourceVersion: resourceVersion, client: remoteClient}
+}
+
+// remoteClient returns a client for the remote cluster from the kubeconfig secret referenced in the StateRescue resource,
+// the client is cached until the kubeconfig secret changes
+func (r *StateRescueReconciler) remoteClient(ctx context.Context, stateRescue *terraformv1.StateRescue) (client.Client, error) {
+	ref := stateRescue.Spec.Destination.RemoteCluster.KubeconfigSecretRef
+	key := ref.Key
+	if key == "" {
+		key = terraformv1.DefaultKubeconfigKey
+	}
+
+	// the kubeconfig secret does not carry the Terraform label, so it is read directly from the API server
+	secret := &corev1.Secret{}
+	secretKey := types.NamespacedName{Name: ref.Name, Namespace: stateRescue.Namespace}
+	if err := r.APIReader.Get(ctx, secretKey, secret); err != nil {
+		return nil, fmt.Errorf("unable to fetch kubeconfig secret %s: %w", ref.Name, err)
+	}
+	remoteClient, ok := r.remoteClients.get(secretKey, key, secret.ResourceVersion)
+	if !ok {
+		kubeconfig, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in kubeconfig secret %s", key, ref.Name)
+		}
+		restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig in secret %s: %w", ref.Name, err)
+		}
+		remoteClient, err = client.New(restConfig, client.Options{Scheme: r.Scheme})
+		if err != nil {
+			return nil, err
+		}
+		r.remoteClients.put(secretKey, key, secret.ResourceVersion, remoteClient)
+	}
+	// writes to the remote cluster are only planned in dry-run mode as well
+	if r.planned != nil {
+		return client.NewDryRunClient(remoteClient), nil
+	}
+	return remoteClient, nil
+}
+
+// remoteNamespace returns the namespace in the remote cluster that backups are replicated to
+func remoteNamespace(stateRescue *terraformv1.StateRescue) string {
+	if ns := stateRescue.Spec.Destination.RemoteCluster.Namespace; ns != "" {
+		return ns
+	}
+	return stateRescue.Namespace
+}
+
+// syncRemoteCluster rescues original secrets from their replicas in the remote cluster if neither the original
+// nor the local backup secret exists, replicates the original secrets and the held snapshots to the remote cluster
+// and updates the replication status of the StateRescue resource. Rescues share the circuit breakers of the rescues
+// from local backups.
+func (r *StateRescueReconciler) syncRemoteCluster(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.SecretList, backup *corev1.SecretList, breakers *openBreakers) error {
+	log := logf.FromContext(ctx)
+
+	var rescued, replicated bool
+	remoteClient, err := r.remoteClient(ctx, stateRescue)
+	if err == nil {
+		rescued, err = r.rescueFromRemote(ctx, stateRescue, remoteClient, signer, original, backup, breakers)
+	}
+	if err == nil {
+		replicated, err = r.replicateToRemote(ctx, stateRescue, remoteClient, signer, original)
+	}
+	if err == nil {
+		var copied bool
+		copied, err = r.replicateHeld(ctx, stateRescue, remoteClient, signer)
+		replicated = replicated || copied
+	}
+
+	condition := metav1.Condition{
+		Type:               terraformv1.ConditionReplicated,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Replicated",
+		Message:            "Backups are replicated to the remote cluster",
+		ObservedGeneration: stateRescue.Generation,
+	}
+	if err != nil {
+		log.Error(err, "unable to replicate backups to the remote cluster")
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ReplicationFailed"
+		condition.Message = err.Error()
+	}
+	changed := meta.SetStatusCondition(&stateRescue.Status.Conditions, condition)
+
+	if stateRescue.Status.RemoteReplication == nil {
+		stateRescue.Status.RemoteReplication = &terraformv1.RemoteReplicationStatus{}
+		changed = true
+	}
+	status := stateRescue.Status.RemoteReplication
+	if replicated {
+		status.LastReplicationTime = metav1.Now()
+		changed = true
+	}
+	if rescued {
+		stateRescue.Status.LastRescueTime = metav1.Now()
+		changed = true
+	}
+	// the lag is the time by which the last replication is behind the last local backup
+	lag := metav1.Duration{}
+	if !status.LastReplicationTime.IsZero() && stateRescue.Status.LastBackupTime.After(status.LastReplicationTime.Time) {
+		lag.Duration = stateRescue.Status.LastBackupTime.Sub(status.LastReplicationTime.Time)
+	}
+	if lag != status.Lag {
+		status.Lag = lag
+		changed = true
+	}
+	replicationLag.WithLabelValues(stateRescue.Namespace, stateRescue.Name).Set(lag.Seconds())
+
+	if changed {
+		if err := r.Status().Update(ctx, stateRescue); err != nil {
+			log.Error(err, "unable to update state rescue resource")
+			return err
+		}
+	}
+	return err
+}
+
+// rescueFromRemote recreates original secrets from their replicas in the remote cluster
+// if neither the original secret nor its local backup secret exists
+func (r *StateRescueReconciler) rescueFromRemote(ctx context.Context, stateRescue *terraformv1.StateRescue, remoteClient client.Client, signer *signer, original *corev1.SecretList, backup *corev1.SecretList, breakers *openBreakers) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	replicas := &corev1.SecretList{}
+	if err := remoteClient.List(ctx, replicas, client.InNamespace(remoteNamespace(stateRescue)), client.MatchingLabels{ReplicaLabelKey: "true"}); err != nil {
+		return false, fmt.Errorf("unable to list replicas in the remote cluster: %w", err)
+	}
+
+	rescued := false
+	for _, item := range replicas.Items {
+		origSecretNameStr := strings.TrimPrefix(item.Name, "backup-")
+		if !strings.HasPrefix(origSecretNameStr, stateRescue.Spec.StateSecretName) ||
+			containsSecret(original, origSecretNameStr) || containsSecret(backup, item.Name) {
+			continue
+		}
+		if Redacted(&item) {
+			log.Info("not rescuing the original secret from its redacted replica in the remote cluster", "Secret", origSecretNameStr)
+			continue
+		}
+		key := types.NamespacedName{Name: origSecretNameStr, Namespace: stateRescue.Namespace}
+		if r.rescueTripped(stateRescue, key, breakers) {
+			log.Info("not rescuing the original secret from the remote cluster since it is rescued too often", "Secret", origSecretNameStr)
+			continue
+		}
+		// never rescue from a replica that has been modified since it was signed
+		if err := signer.verify(item.Name, &item); err != nil {
+			if err := r.refuseTampered(ctx, stateRescue, err); err != nil {
+				return rescued, err
+			}
+			continue
+		}
+		// make sure that the original secret has not been created since the secrets were listed
+		originalSecret := &corev1.Secret{}
+		if err := r.secretReader().Get(ctx, key, originalSecret); err == nil {
+			continue
+		} else if !errors.IsNotFound(err) {
+			return rescued, err
+		}
+
+		originalSecret = SecretFromReplica(&item, stateRescue.Namespace)
+		r.recordAction(stateRescue, terraformv1.ActionRescueFromRemote, origSecretNameStr)
+		log.Info("creating an original secret from its replica in the remote cluster", "Secret", origSecretNameStr)
+		if err := r.Create(ctx, originalSecret); err != nil {
+			log.Error(err, "unable to create the original secret")
+			return rescued, err
+		}
+		r.recordRescued(stateRescue, key, "its replica in the remote cluster")
+		rescued = true
+	}
+	return rescued, nil
+}
+
+// replicateToRemote copies the data of the original secrets to their replicas in the remote cluster,
+// replicas do not carry the Terraform label so that they are never mistaken for state secrets
+func (r *StateRescueReconciler) replicateToRemote(ctx context.Context, stateRescue *terraformv1.StateRescue, remoteClient client.Client, signer *signer, original *corev1.SecretList) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	replicated := false
+	for _, item := range original.Items {
+		replica := &corev1.Secret{}
+		annotations, err := replicaAnnotations(stateRescue, &item)
+		if err != nil {
+			return replicated, err
+		}
+		data := item.Data
+		if redact := stateRescue.Spec.Destination.RemoteCluster.Redact; redact != nil {
+			if data, err = redactedData(&item, redact); err != nil {
+				return replicated, fmt.Errorf("unable to redact state of secret %s: %w", item.Name, err)
+			}
+			annotations[RedactedAnnotationKey] = "true"
+		}
+		setChecksums(annotations, data)
+		labels := maps.Clone(item.Labels)
+		delete(labels, TfStateLabelKey)
+		labels[ReplicaLabelKey] = "true"
+		labels["tfstate"] = "false"
+		desired := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "backup-" + item.Name,
+				Namespace:   remoteNamespace(stateRescue),
+				Labels:      labels,
+				Annotations: annotations,
+			},
+			Data: data,
+		}
+		signer.sign(desired, desired.Name)
+		err = remoteClient.Get(ctx, client.ObjectKeyFromObject(desired), replica)
+		if errors.IsNotFound(err) {
+			r.recordAction(stateRescue, terraformv1.ActionReplicate, item.Name)
+			log.Info("Creating the replica of the original secret in the remote cluster", "Secret", item.Name)
+			if err := remoteClient.Create(ctx, desired); err != nil {
+				return replicated, fmt.Errorf("unable to create replica of secret %s: %w", item.Name, err)
+			}
+			replicated = true
+			continue
+		} else if err != nil {
+			return replicated, fmt.Errorf("unable to fetch replica of secret %s: %w", item.Name, err)
+		}
+		// the labels are signed as well, so they are kept in line with the original secret
+		if equality.Semantic.DeepEqual(replica.Data, data) && equality.Semantic.DeepEqual(replica.Annotations, desired.Annotations) &&
+			equality.Semantic.DeepEqual(replica.Labels, labels) {
+			continue
+		}
+		replica.Labels = labels
+		replica.Data = data
+		replica.Annotations = desired.Annotations
+		r.recordAction(stateRescue, terraformv1.ActionReplicate, item.Name)
+		log.Info("Updating the replica of the original secret in the remote cluster", "Secret", item.Name)
+		if err := remoteClient.Update(ctx, replica); err != nil {
+			return replicated, fmt.Errorf("unable to update replica of secret %s: %w", item.Name, err)
+		}
+		replicated = true
+	}
+	return replicated, nil
+}
+
+// replicaAnnotations returns the annotations of the replica of an original secret, which record the namespace
+// of the original secret and the StateRescue resource in addition to the annotations of the original secret
+func replicaAnnotations(stateRescue *terraformv1.StateRescue, original *corev1.Secret) (map[string]string, error) {
+	metadata, err := json.Marshal(&terraformv1.StateRescue{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stateRescue.Name,
+			Namespace: stateRescue.Namespace,
+			Labels:    stateRescue.Labels,
+		},
+		Spec: stateRescue.Spec,
+	})
+	if err != nil {
+		return nil, err
+	}
+	annotations := maps.Clone(original.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SourceNamespaceAnnotationKey] = original.Namespace
+	annotations[StateRescueAnnotationKey] = string(metadata)
+	return annotations, nil
+}
+
+// SecretFromReplica returns the original terraform state secret in the given namespace for a replica,
+// restoring the Terraform labels and dropping the metadata that was only added to the replica
+func SecretFromReplica(replica *corev1.Secret, namespace string) *corev1.Secret {
+	// only the metadata that is signed is restored
+	labels := unmanaged(replica.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[TfStateLabelKey] = TfStateLabelValue
+	labels["tfstate"] = "true"
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        strings.TrimPrefix(replica.Name, "backup-"),
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: unmanaged(replica.Annotations),
+		},
+		Data: replica.Data,
+	}
+}
+
+// redactedData returns the data of a replica with the sensitive values in the state of the original secret replaced
+func redactedData(original *corev1.Secret, redact *terraformv1.RedactOptions) (map[string][]byte, error) {
+	s, err := state.FromSecret(original)
+	if err != nil {
+		return nil, err
+	}
+	redacted, err := state.Redact(s.Raw, redact.AttributePatterns)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := state.Encode(redacted)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{state.SecretKey: encoded}, nil
+}
+
+// containsSecret reports whether a secret with the given name is in the list
+func containsSecret(secrets *corev1.SecretList, name string) bool {
+	for _, item := range secrets.Items {
+		if item.Name == name {
+			return true
+		}
+	}
+	return false
+}
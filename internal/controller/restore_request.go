@@ -0,0 +1,146 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+// RestoreAnnotationKey requests the controller to overwrite the state secret named in the annotation value
+// with the data of its backup secret, or the state secret of the snapshot named in the annotation value with
+// the data of the snapshot. The annotation is removed once the request has been handled.
+const RestoreAnnotationKey = "terraform.hammadzf.github.io/restore"
+
+// handleRestoreRequest restores a state secret from its backup or from one of its snapshots if requested through
+// the restore annotation. Backups and snapshots that do not match their signature are never restored. The data of
+// the restored secret is also updated in the list of original secrets, so that the backup is not overwritten with
+// the data that was just replaced.
+func (r *StateRescueReconciler) handleRestoreRequest(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.SecretList, backup *corev1.SecretList) error {
+	log := logf.FromContext(ctx)
+
+	requested, ok := stateRescue.Annotations[RestoreAnnotationKey]
+	if !ok {
+		return nil
+	}
+
+	secretName, from := requested, "its backup"
+	source := findSecret(backup, "backup-"+requested)
+	notFound := fmt.Sprintf("No backup found for secret %s", requested)
+	fromSnapshot := strings.HasPrefix(requested, "snapshot-")
+	if fromSnapshot {
+		snapshot, err := r.requestedSnapshot(ctx, stateRescue, requested)
+		if err != nil {
+			return err
+		}
+		source, from = snapshot, "snapshot "+requested
+		notFound = fmt.Sprintf("No snapshot %s found", requested)
+		if snapshot != nil {
+			secretName = snapshot.Annotations[SnapshotOfAnnotationKey]
+		}
+	}
+
+	restored := false
+	originalSecret := findSecret(original, secretName)
+	var tampered error
+	if source != nil {
+		tampered = signer.verify(source.Name, source)
+	}
+	switch {
+	case source == nil:
+		if r.planned == nil {
+			r.Recorder.Event(stateRescue, corev1.EventTypeWarning, "RestoreFailed", notFound)
+		}
+	case tampered != nil:
+		if err := r.refuseTampered(ctx, stateRescue, tampered); err != nil {
+			return err
+		}
+	case originalSecret != nil:
+		r.recordAction(stateRescue, terraformv1.ActionRestore, secretName)
+		log.Info("Restoring the original secret", "Secret", secretName, "Source", source.Name)
+		originalSecret.Data = source.Data
+		if err := r.Update(ctx, originalSecret); err != nil {
+			log.Error(err, "unable to restore the original secret")
+			return err
+		}
+		restored = true
+	case fromSnapshot:
+		// a missing original secret is rescued from its backup, which would not be the requested state
+		if r.planned == nil {
+			r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "RestoreFailed",
+				"Secret %s of snapshot %s not found", secretName, requested)
+		}
+	}
+	// a missing original secret is rescued from its backup anyway
+
+	// remove the handled request
+	delete(stateRescue.Annotations, RestoreAnnotationKey)
+	if err := r.Update(ctx, stateRescue); err != nil {
+		log.Error(err, "unable to remove the restore request from state rescue resource")
+		return err
+	}
+	if !restored {
+		return nil
+	}
+	if r.planned == nil {
+		r.Recorder.Eventf(stateRescue, corev1.EventTypeNormal, "Restored", "Restored secret %s from %s", secretName, from)
+	}
+	stateRescue.Status.LastRescueTime = metav1.Now()
+	if err := r.Status().Update(ctx, stateRescue); err != nil {
+		log.Error(err, "unable to update state rescue resource")
+		return err
+	}
+	return nil
+}
+
+// requestedSnapshot returns the snapshot with the given name if it is a snapshot of the StateRescue resource,
+// or nil otherwise, so that no other secret of the namespace can be restored over a state secret
+func (r *StateRescueReconciler) requestedSnapshot(ctx context.Context, stateRescue *terraformv1.StateRescue, name string) (*corev1.Secret, error) {
+	log := logf.FromContext(ctx)
+
+	snapshot := &corev1.Secret{}
+	if err := r.secretReader().Get(ctx, types.NamespacedName{Name: name, Namespace: stateRescue.Namespace}, snapshot); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		log.Error(err, "unable to fetch the snapshot", "Snapshot", name)
+		return nil, err
+	}
+	if snapshot.Labels[SnapshotLabelKey] != "true" || !metav1.IsControlledBy(snapshot, stateRescue) {
+		return nil, nil
+	}
+	return snapshot, nil
+}
+
+// findSecret returns the secret with the given name from a list of secrets
+func findSecret(secrets *corev1.SecretList, name string) *corev1.Secret {
+	for i := range secrets.Items {
+		if secrets.Items[i].Name == name {
+			return &secrets.Items[i]
+		}
+	}
+	return nil
+}
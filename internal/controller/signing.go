@@ -0,0 +1,335 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+const (
+	// SignatureAnnotationKey holds the signature of a backup secret or replica as <algorithm>:<base64 signature>
+	SignatureAnnotationKey = "terraform.hammadzf.github.io/signature"
+	// managedKeyPrefix is the prefix of the labels and annotations that the controller manages
+	managedKeyPrefix = "terraform.hammadzf.github.io/"
+)
+
+// errSigningKeyUnavailable is returned when verifying a backup while the signing key cannot be loaded
+var errSigningKeyUnavailable = errors.New("the signing key is unavailable")
+
+// signer signs and verifies backups and replicas with the key referenced in a StateRescue resource,
+// a nil signer means that signing is disabled
+type signer struct {
+	algorithm  terraformv1.SigningAlgorithm
+	hmacKey    []byte
+	privateKey ed25519.PrivateKey
+	// unavailable is set if the signing key cannot be loaded, nothing is signed and every backup fails
+	// verification until it can be loaded again
+	unavailable error
+}
+
+// signerFor loads the signing key referenced in the StateRescue resource, it returns nil if signing is disabled
+func (r *StateRescueReconciler) signerFor(ctx context.Context, stateRescue *terraformv1.StateRescue) (*signer, error) {
+	opts := stateRescue.Spec.Signing
+	if opts == nil {
+		return nil, nil
+	}
+	key := opts.Key
+	if key == "" {
+		key = terraformv1.DefaultSigningKey
+	}
+
+	// the key secret does not carry the Terraform label, so it is read directly from the API server
+	secret := &corev1.Secret{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: opts.KeySecretName, Namespace: stateRescue.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("unable to fetch signing key secret %s: %w", opts.KeySecretName, err)
+	}
+	value, ok := secret.Data[key]
+	if !ok || len(value) == 0 {
+		return nil, fmt.Errorf("key %s not found in signing key secret %s", key, opts.KeySecretName)
+	}
+
+	return newSigner(opts.Algorithm, value, "signing key secret "+opts.KeySecretName)
+}
+
+// newSigner returns a signer for the shared key of HMAC-SHA256 or the PEM encoded PKCS #8 private key of Ed25519,
+// the source of the key is named in errors
+func newSigner(algorithm terraformv1.SigningAlgorithm, value []byte, source string) (*signer, error) {
+	switch algorithm {
+	case terraformv1.SigningEd25519:
+		block, _ := pem.Decode(value)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM encoded private key found in %s", source)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key in %s: %w", source, err)
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key in %s is no Ed25519 key", source)
+		}
+		return &signer{algorithm: terraformv1.SigningEd25519, privateKey: privateKey}, nil
+	default:
+		return &signer{algorithm: terraformv1.SigningHMACSHA256, hmacKey: value}, nil
+	}
+}
+
+// ReplicaSigner signs and verifies replicas outside of the controller, e.g. in the one-shot restore
+type ReplicaSigner struct {
+	signer *signer
+}
+
+// NewReplicaSigner returns a signer for the signing key of the given algorithm, see SigningOptions for its format,
+// the source of the key is named in errors
+func NewReplicaSigner(algorithm terraformv1.SigningAlgorithm, key []byte, source string) (*ReplicaSigner, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("no signing key found in %s", source)
+	}
+	s, err := newSigner(algorithm, key, source)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplicaSigner{signer: s}, nil
+}
+
+// Sign records the signature of a replica, which is signed under its own name
+func (s *ReplicaSigner) Sign(replica *corev1.Secret) {
+	s.signer.sign(replica, replica.Name)
+}
+
+// Verify checks the signature of a replica, which is signed under its own name
+func (s *ReplicaSigner) Verify(replica *corev1.Secret) error {
+	return s.signer.verify(replica.Name, replica)
+}
+
+// managedKey reports whether the controller manages a label or annotation of a backup, such labels and annotations
+// are not signed since they change after a backup is written and they are never restored to a state secret
+func managedKey(key string) bool {
+	return strings.HasPrefix(key, managedKeyPrefix) || strings.HasPrefix(key, terraformv1.SnapshotTagLabelPrefix) ||
+		key == "tfstate" || key == TfStateLabelKey
+}
+
+// unmanaged returns the labels or annotations of a backup that the controller does not manage, they are signed
+// along with the data and restored to the state secret when it is rescued from the backup
+func unmanaged(metadata map[string]string) map[string]string {
+	result := maps.Clone(metadata)
+	maps.DeleteFunc(result, func(key, _ string) bool { return managedKey(key) })
+	return result
+}
+
+// withMetadataOf returns the labels or annotations of a backup that the controller manages merged with the ones
+// of the original secret that it does not manage
+func withMetadataOf(backup, original map[string]string) map[string]string {
+	result := unmanaged(original)
+	if result == nil {
+		result = map[string]string{}
+	}
+	for key, value := range backup {
+		if managedKey(key) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// metadataChecksum returns the SHA-256 of the sorted labels and annotations of a secret that the controller
+// does not manage
+func metadataChecksum(secret *corev1.Secret) string {
+	hash := sha256.New()
+	for _, metadata := range []map[string]string{unmanaged(secret.Labels), unmanaged(secret.Annotations)} {
+		fmt.Fprintf(hash, "%d:", len(metadata))
+		for _, key := range slices.Sorted(maps.Keys(metadata)) {
+			fmt.Fprintf(hash, "%d:%s%d:%s", len(key), key, len(metadata[key]), metadata[key])
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// signedMessage returns the signed message of a snapshot, which binds its payload checksum and the metadata
+// that is restored with it to its name so that the data of one snapshot cannot be passed off as another
+func signedMessage(name string, secret *corev1.Secret) []byte {
+	return []byte(name + "\n" + payloadChecksum(secret.Data) + "\n" + metadataChecksum(secret))
+}
+
+// signature returns the raw signature of the data and metadata of a snapshot
+func (s *signer) signature(name string, secret *corev1.Secret) []byte {
+	if s.algorithm == terraformv1.SigningEd25519 {
+		return ed25519.Sign(s.privateKey, signedMessage(name, secret))
+	}
+	mac := hmac.New(sha256.New, s.hmacKey)
+	mac.Write(signedMessage(name, secret))
+	return mac.Sum(nil)
+}
+
+// sign records the signature of a snapshot under the given name in its annotations, the snapshot must not be
+// changed afterwards except for the labels and annotations that the controller manages
+func (s *signer) sign(secret *corev1.Secret, name string) {
+	if s == nil {
+		return
+	}
+	// a signature of the previous data would no longer match
+	if s.unavailable != nil {
+		delete(secret.Annotations, SignatureAnnotationKey)
+		return
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[SignatureAnnotationKey] = fmt.Sprintf("%s:%s", s.algorithm, base64.StdEncoding.EncodeToString(s.signature(name, secret)))
+}
+
+// verify checks the signature of a snapshot against the name it was signed under, every snapshot
+// is trusted if signing is disabled and none if the signing key is unavailable
+func (s *signer) verify(name string, secret *corev1.Secret) error {
+	if s == nil {
+		return nil
+	}
+	if s.unavailable != nil {
+		return fmt.Errorf("%s cannot be verified: %w", name, s.unavailable)
+	}
+	value, ok := secret.Annotations[SignatureAnnotationKey]
+	if !ok {
+		return fmt.Errorf("%s is not signed", name)
+	}
+	algorithm, encoded, _ := strings.Cut(value, ":")
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if algorithm != string(s.algorithm) || err != nil {
+		return fmt.Errorf("%s is not signed with %s", name, s.algorithm)
+	}
+	valid := false
+	if s.algorithm == terraformv1.SigningEd25519 {
+		valid = ed25519.Verify(s.privateKey.Public().(ed25519.PublicKey), signedMessage(name, secret), signature)
+	} else {
+		valid = hmac.Equal(signature, s.signature(name, secret))
+	}
+	if !valid {
+		return fmt.Errorf("%s does not match its signature", name)
+	}
+	return nil
+}
+
+// needsSigning reports whether a snapshot has to be signed (again), i.e. it does not match its signature
+// and the signing key is available
+func (s *signer) needsSigning(name string, secret *corev1.Secret) bool {
+	if s == nil || s.unavailable != nil {
+		return false
+	}
+	return s.verify(name, secret) != nil
+}
+
+// loadSigner loads the signing key of the StateRescue resource and reports in the SigningKeyAvailable condition
+// and a warning event whether it could be loaded. If it could not, the returned signer signs nothing and backups
+// are never used to rescue or restore a state since they cannot be verified, but they are still written.
+func (r *StateRescueReconciler) loadSigner(ctx context.Context, stateRescue *terraformv1.StateRescue) (*signer, error) {
+	log := logf.FromContext(ctx)
+
+	condition := metav1.Condition{
+		Type:               terraformv1.ConditionSigningKeyAvailable,
+		Status:             metav1.ConditionTrue,
+		Reason:             "KeyLoaded",
+		Message:            "Backups and replicas are signed",
+		ObservedGeneration: stateRescue.Generation,
+	}
+	loaded, loadErr := r.signerFor(ctx, stateRescue)
+	if loadErr != nil {
+		log.Error(loadErr, "unable to load the signing key, backups are written unsigned")
+		loaded = &signer{unavailable: fmt.Errorf("%w: %v", errSigningKeyUnavailable, loadErr)}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "KeyUnavailable"
+		condition.Message = loadErr.Error()
+	}
+	var changed bool
+	if stateRescue.Spec.Signing == nil {
+		changed = meta.RemoveStatusCondition(&stateRescue.Status.Conditions, terraformv1.ConditionSigningKeyAvailable)
+	} else {
+		changed = meta.SetStatusCondition(&stateRescue.Status.Conditions, condition)
+	}
+	if !changed {
+		return loaded, nil
+	}
+	if r.planned == nil && loadErr != nil {
+		r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "SigningKeyUnavailable",
+			"Writing unsigned backups and refusing to rescue or restore: %v", loadErr)
+	}
+	if err := r.Status().Update(ctx, stateRescue); err != nil {
+		log.Error(err, "unable to update state rescue resource")
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// refuseTampered reports a snapshot that does not match its signature and is therefore not used
+// to rescue or restore a state, in the BackupTampered condition and a warning event
+func (r *StateRescueReconciler) refuseTampered(ctx context.Context, stateRescue *terraformv1.StateRescue, err error) error {
+	log := logf.FromContext(ctx)
+
+	// backups that cannot be verified are reported in the SigningKeyAvailable condition instead
+	if errors.Is(err, errSigningKeyUnavailable) {
+		log.Info("refusing to rescue or restore from a backup that cannot be verified", "reason", err.Error())
+		return nil
+	}
+	log.Info("refusing to rescue or restore from a tampered backup", "reason", err.Error())
+	if r.planned == nil {
+		r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "BackupTampered", "Refusing to rescue or restore: %v", err)
+	}
+	if !setTampered(stateRescue, []string{err.Error()}) {
+		return nil
+	}
+	if updateErr := r.Status().Update(ctx, stateRescue); updateErr != nil {
+		log.Error(updateErr, "unable to update state rescue resource")
+		return updateErr
+	}
+	return nil
+}
+
+// setTampered sets the BackupTampered condition from the signature failures of the snapshots
+// and reports whether the condition changed
+func setTampered(stateRescue *terraformv1.StateRescue, failures []string) bool {
+	condition := metav1.Condition{
+		Type:               terraformv1.ConditionBackupTampered,
+		Status:             metav1.ConditionFalse,
+		Reason:             "SignaturesVerified",
+		Message:            "All backups match their signatures",
+		ObservedGeneration: stateRescue.Generation,
+	}
+	if len(failures) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SignatureMismatch"
+		condition.Message = strings.Join(failures, "; ")
+	}
+	return meta.SetStatusCondition(&stateRescue.Status.Conditions, condition)
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+const (
+	// SnapshotLabelKey marks the immutable snapshots of terraform state secrets
+	SnapshotLabelKey = "terraform.hammadzf.github.io/snapshot"
+	// SnapshotOfAnnotationKey records the name of the original secret on its snapshots
+	SnapshotOfAnnotationKey = "terraform.hammadzf.github.io/snapshot-of"
+	// SnapshotTimeAnnotationKey records when a snapshot was taken in RFC 3339 format with nanoseconds,
+	// so that snapshots taken within the same second are still ordered
+	SnapshotTimeAnnotationKey = "terraform.hammadzf.github.io/snapshot-time"
+	// SnapshotPinnedLabelKey marks snapshots that are kept beyond the number of kept generations
+	SnapshotPinnedLabelKey = "terraform.hammadzf.github.io/pinned"
+)
+
+// retained reports whether a snapshot is kept beyond the number of kept generations
+func retained(snapshot *corev1.Secret) bool {
+	for key := range snapshot.Labels {
+		if key == SnapshotPinnedLabelKey || key == SnapshotHeldLabelKey || strings.HasPrefix(key, terraformv1.SnapshotTagLabelPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotName returns the content derived name of the snapshot of an original secret with the given data,
+// snapshots of the same data share the same name so that every generation is only written once. The name of
+// the original secret is truncated and suffixed with its hash if the name would exceed the limit of secret names.
+func snapshotName(original string, data map[string][]byte) string {
+	if limit := validation.DNS1123SubdomainMaxLength - len("snapshot--") - 10; len(original) > limit {
+		sum := sha256.Sum256([]byte(original))
+		// the truncated name must not end with a dot, which has to be followed by an alphanumeric character
+		original = strings.TrimRight(original[:limit-9], ".-") + "-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return fmt.Sprintf("snapshot-%s-%s", original, payloadChecksum(data)[:10])
+}
+
+// snapshotTime returns when a snapshot was taken, falling back to its creation time
+func snapshotTime(snapshot *corev1.Secret) time.Time {
+	if taken, err := time.Parse(time.RFC3339Nano, snapshot.Annotations[SnapshotTimeAnnotationKey]); err == nil {
+		return taken
+	}
+	return snapshot.CreationTimestamp.Time
+}
+
+// listSnapshots returns the snapshots of an original secret ordered from the newest to the oldest
+func (r *StateRescueReconciler) listSnapshots(ctx context.Context, namespace, original string) (*corev1.SecretList, error) {
+	secrets := &corev1.SecretList{}
+	if err := r.secretReader().List(ctx, secrets, client.InNamespace(namespace), client.MatchingLabels{SnapshotLabelKey: "true"}); err != nil {
+		return nil, err
+	}
+	snapshots := &corev1.SecretList{}
+	for _, item := range secrets.Items {
+		if item.Annotations[SnapshotOfAnnotationKey] == original {
+			snapshots.Items = append(snapshots.Items, item)
+		}
+	}
+	sort.SliceStable(snapshots.Items, func(i, j int) bool {
+		return snapshotTime(&snapshots.Items[i]).After(snapshotTime(&snapshots.Items[j]))
+	})
+	return snapshots, nil
+}
+
+// snapshotForOriginal returns an immutable snapshot of the current data of an original secret
+func (r *StateRescueReconciler) snapshotForOriginal(stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.Secret) (*corev1.Secret, error) {
+	labels := maps.Clone(original.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["tfstate"] = "false"
+	labels[SnapshotLabelKey] = "true"
+	annotations := withoutSnapshotAnnotations(original.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[SnapshotOfAnnotationKey] = original.Name
+	annotations[SnapshotTimeAnnotationKey] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	snapshot := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        snapshotName(original.Name, original.Data),
+			Namespace:   original.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Immutable: ptr.To(true),
+		Data:      original.Data,
+	}
+	setChecksums(snapshot.Annotations, snapshot.Data)
+	signer.sign(snapshot, snapshot.Name)
+	if err := controllerutil.SetControllerReference(stateRescue, snapshot, r.Scheme); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// snapshotGeneration writes an immutable snapshot of the current data of an original secret unless it exists
+// already and deletes the oldest snapshots beyond the number of kept generations. The data of snapshots is never
+// updated, an existing snapshot only gets a new snapshot time when the state returns to its data.
+func (r *StateRescueReconciler) snapshotGeneration(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.Secret) error {
+	log := logf.FromContext(ctx)
+
+	snapshots, err := r.listSnapshots(ctx, original.Namespace, original.Name)
+	if err != nil {
+		log.Error(err, "unable to fetch snapshots of the original secret")
+		return err
+	}
+	// adopt snapshots retained by a previously deleted state rescue object for the same secret
+	if err := r.adoptOrphanedBackups(ctx, stateRescue, snapshots); err != nil {
+		return err
+	}
+
+	current := findSecret(snapshots, snapshotName(original.Name, original.Data))
+	if current == nil {
+		snapshot, err := r.snapshotForOriginal(stateRescue, signer, original)
+		if err != nil {
+			log.Error(err, "could not set controller reference for the snapshot")
+			return err
+		}
+		// attribute the new generation to the user that changed the state
+		setChangedBy(snapshot.Annotations, stateRescue, original.Name)
+		r.recordAction(stateRescue, terraformv1.ActionCreateSnapshot, original.Name)
+		log.Info("Creating a snapshot of the original secret", "Secret", original.Name, "Snapshot", snapshot.Name)
+		// the snapshot may exist already if the cache has not caught up with a previous reconciliation
+		if err := r.Create(ctx, snapshot); err != nil && !errors.IsAlreadyExists(err) {
+			log.Error(err, "unable to create the snapshot")
+			return err
+		}
+		snapshots.Items = append([]corev1.Secret{*snapshot}, snapshots.Items...)
+	} else if current.Name != snapshots.Items[0].Name {
+		// the state returned to the data of an older snapshot, e.g. after a revert, which becomes the newest
+		// generation again so that it is not pruned before the generations that it replaced
+		patch := client.MergeFrom(current.DeepCopy())
+		current.Annotations[SnapshotTimeAnnotationKey] = time.Now().UTC().Format(time.RFC3339Nano)
+		log.Info("Marking the snapshot of the original secret as the newest generation", "Secret", original.Name, "Snapshot", current.Name)
+		if err := r.Patch(ctx, current, patch); err != nil {
+			log.Error(err, "unable to update the snapshot time")
+			return err
+		}
+		sort.SliceStable(snapshots.Items, func(i, j int) bool {
+			return snapshotTime(&snapshots.Items[i]).After(snapshotTime(&snapshots.Items[j]))
+		})
+	}
+
+	// delete the oldest snapshots of this state rescue object beyond the number of kept generations,
+	// pinned, tagged and held snapshots are neither counted nor deleted
+	kept := int32(0)
+	for i := range snapshots.Items {
+		item := &snapshots.Items[i]
+		if !metav1.IsControlledBy(item, stateRescue) || retained(item) {
+			continue
+		}
+		if kept < stateRescue.Spec.Generations {
+			kept++
+			continue
+		}
+		r.recordAction(stateRescue, terraformv1.ActionPruneSnapshot, original.Name)
+		log.Info("Deleting the snapshot beyond the kept generations", "Secret", original.Name, "Snapshot", item.Name)
+		if err := r.Delete(ctx, item); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "unable to delete the snapshot")
+			return err
+		}
+	}
+	return nil
+}
+
+// pinSnapshot pins the snapshot of an original secret with the given data so that it is never pruned,
+// the snapshot is written first if it does not exist, e.g. because generations are disabled
+func (r *StateRescueReconciler) pinSnapshot(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.Secret, data map[string][]byte) error {
+	return r.labelSnapshot(ctx, stateRescue, signer, original, data, SnapshotPinnedLabelKey, terraformv1.ActionPinSnapshot)
+}
+
+// labelSnapshot sets a label of the snapshot of an original secret with the given data to true,
+// the snapshot is written first if it does not exist, e.g. because generations are disabled
+func (r *StateRescueReconciler) labelSnapshot(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.Secret, data map[string][]byte, label string, action terraformv1.ActionType) error {
+	log := logf.FromContext(ctx)
+
+	name := snapshotName(original.Name, data)
+	snapshot := &corev1.Secret{}
+	if err := r.secretReader().Get(ctx, types.NamespacedName{Name: name, Namespace: original.Namespace}, snapshot); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "unable to fetch the snapshot", "Snapshot", name)
+			return err
+		}
+		previous := original.DeepCopy()
+		previous.Data = data
+		if snapshot, err = r.snapshotForOriginal(stateRescue, signer, previous); err != nil {
+			log.Error(err, "could not set controller reference for the snapshot")
+			return err
+		}
+		snapshot.Labels[label] = "true"
+		r.recordAction(stateRescue, action, original.Name)
+		log.Info("Creating a labelled snapshot of the original secret", "Secret", original.Name, "Snapshot", name, "Label", label)
+		if err := r.Create(ctx, snapshot); err != nil && !errors.IsAlreadyExists(err) {
+			log.Error(err, "unable to create the snapshot")
+			return err
+		}
+		return nil
+	}
+	if snapshot.Labels[label] == "true" {
+		return nil
+	}
+	// only the data of snapshots is immutable, so they can still be labelled
+	patch := client.MergeFrom(snapshot.DeepCopy())
+	if snapshot.Labels == nil {
+		snapshot.Labels = map[string]string{}
+	}
+	snapshot.Labels[label] = "true"
+	r.recordAction(stateRescue, action, original.Name)
+	log.Info("Labelling the snapshot of the original secret", "Secret", original.Name, "Snapshot", name, "Label", label)
+	if err := r.Patch(ctx, snapshot, patch); err != nil {
+		log.Error(err, "unable to label the snapshot")
+		return err
+	}
+	return nil
+}
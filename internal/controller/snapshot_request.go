@@ -0,0 +1,86 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+// SnapshotNowAnnotationKey requests the controller to take a snapshot of all state secrets of a StateRescue
+// resource tagged with the annotation value, e.g. before a risky apply. The annotation is removed once the
+// request has been handled, so that the same tag can be requested again, and the request is recorded in the status.
+const SnapshotNowAnnotationKey = "tf-state-rescuer.io/snapshot-now"
+
+// handleSnapshotRequest takes tagged snapshots of the current states of the original secrets if requested
+// through the snapshot-now annotation. Snapshots are taken even if generations are disabled, an existing
+// snapshot of the same state is tagged instead.
+func (r *StateRescueReconciler) handleSnapshotRequest(ctx context.Context, stateRescue *terraformv1.StateRescue, signer *signer, original *corev1.SecretList) error {
+	log := logf.FromContext(ctx)
+
+	tag, ok := stateRescue.Annotations[SnapshotNowAnnotationKey]
+	if !ok {
+		return nil
+	}
+
+	request := &terraformv1.SnapshotRequest{Tag: tag, Time: metav1.Now()}
+	label := terraformv1.SnapshotTagLabelPrefix + tag
+	// an invalid tag is handled as well, so that the warning is only emitted once
+	if errs := validation.IsQualifiedName(label); len(errs) > 0 {
+		if r.planned == nil {
+			r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "SnapshotFailed",
+				"Invalid snapshot tag %q: %s", tag, strings.Join(errs, "; "))
+		}
+	} else {
+		for i := range original.Items {
+			item := &original.Items[i]
+			if err := r.labelSnapshot(ctx, stateRescue, signer, item, item.Data, label, terraformv1.ActionTagSnapshot); err != nil {
+				return err
+			}
+			request.Snapshots = append(request.Snapshots, snapshotName(item.Name, item.Data))
+		}
+		log.Info("Took the requested snapshots", "Tag", tag, "Snapshots", request.Snapshots)
+		if r.planned == nil {
+			r.Recorder.Eventf(stateRescue, corev1.EventTypeNormal, "SnapshotTaken",
+				"Took %d snapshots tagged %s", len(request.Snapshots), tag)
+		}
+	}
+
+	// the status is updated before the request is removed, since updates replace the in-memory object
+	// with the response of the API server
+	stateRescue.Status.LastSnapshotRequest = request
+	if err := r.Status().Update(ctx, stateRescue); err != nil {
+		log.Error(err, "unable to update state rescue resource")
+		return err
+	}
+	// remove the handled request
+	patch := client.MergeFrom(stateRescue.DeepCopy())
+	delete(stateRescue.Annotations, SnapshotNowAnnotationKey)
+	if err := r.Patch(ctx, stateRescue, patch); err != nil {
+		log.Error(err, "unable to remove the snapshot request from state rescue resource")
+		return err
+	}
+	return nil
+}
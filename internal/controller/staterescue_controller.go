@@ -18,15 +18,24 @@ package controller
 
 import (
 	"context"
+	"maps"
 	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -34,17 +43,50 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
 )
 
 const (
 	TfStateLabelKey   = "app.kubernetes.io/managed-by"
 	TfStateLabelValue = "terraform"
+	// OrphanedLabelKey marks backup secrets retained after their StateRescue resource was deleted
+	OrphanedLabelKey = "terraform.hammadzf.github.io/orphaned"
+	// StateRescueFinalizer makes sure the deletion policy is enforced before a StateRescue resource is removed
+	StateRescueFinalizer = "terraform.hammadzf.github.io/finalizer"
+	// DiffSummaryAnnotationKey holds the summary of the changes of a backup secret against its previous data
+	DiffSummaryAnnotationKey = "terraform.hammadzf.github.io/diff-summary"
 )
 
 // StateRescueReconciler reconciles a StateRescue object
 type StateRescueReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// DryRun reports whether the controller only plans backup and rescue actions for all StateRescue resources.
+	// It is called on every reconciliation, so that dry-run mode can be switched while the manager is running.
+	// Dry-run mode is disabled if nil.
+	DryRun func() bool
+	// SecretMetadataOnly makes the controller cache only the metadata of secrets
+	SecretMetadataOnly bool
+	// APIReader reads secrets directly from the API server that are not cached,
+	// e.g. kubeconfig secrets or state secrets when only their metadata is cached
+	APIReader client.Reader
+	// VerifyInterval is the interval in which backups are verified against their checksums, 0 disables verification
+	VerifyInterval time.Duration
+	// RescueFlappingThreshold is the number of rescues of a state secret within the RescueFlappingWindow
+	// after which it is no longer rescued until the oldest rescue leaves the window, 0 disables the limit
+	RescueFlappingThreshold int
+	// RescueFlappingWindow is the window in which the rescues of a state secret are counted
+	RescueFlappingWindow time.Duration
+
+	// rescueHistory tracks the recent rescues of state secrets, it is shared with the planner
+	rescueHistory *rescueHistory
+	// runHistory holds the released locks of state secrets until their snapshots and backups are attributed to the runs
+	runHistory *runHistory
+	// remoteClients caches the clients for remote clusters per kubeconfig secret, it is shared with the planner
+	remoteClients *remoteClientCache
+	// planned collects the actions that would be taken while planning in dry-run mode
+	planned *[]terraformv1.PlannedAction
 }
 
 // +kubebuilder:rbac:groups=terraform.hammadzf.github.io,resources=staterescues,verbs=get;list;watch;create;update;patch;delete
@@ -52,9 +94,13 @@ type StateRescueReconciler struct {
 // +kubebuilder:rbac:groups=terraform.hammadzf.github.io,resources=staterescues/finalizers,verbs=update
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=secrets/data,verbs=update
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
+// The conditions and other status fields that are derived on every reconciliation are only
+// written when they change, since every status update triggers another reconciliation.
 
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
@@ -77,8 +123,22 @@ func (r *StateRescueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	}
 
+	// enforce the deletion policy on backup secrets if the state rescue object is being deleted
+	if !stateRescue.DeletionTimestamp.IsZero() {
+		return r.finalizeStateRescue(ctx, &stateRescue)
+	}
+	// nothing but the planned actions is written in dry-run mode, not even the finalizer
+	dryRun := (r.DryRun != nil && r.DryRun()) || stateRescue.Spec.DryRun
+	// add finalizer so that the deletion policy can be enforced once the state rescue object is deleted
+	if !dryRun && controllerutil.AddFinalizer(&stateRescue, StateRescueFinalizer) {
+		if err := r.Update(ctx, &stateRescue); err != nil {
+			log.Error(err, "unable to add finalizer to state rescue resource")
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Load Kubernetes secrets that contains terraform state files in the state rescue namespace
-	if err := r.List(ctx, stateSecrets, client.InNamespace(stateRescue.Namespace), client.MatchingLabels{TfStateLabelKey: TfStateLabelValue}); err != nil {
+	if err := r.secretReader().List(ctx, stateSecrets, client.InNamespace(stateRescue.Namespace), client.MatchingLabels{TfStateLabelKey: TfStateLabelValue}); err != nil {
 		if errors.IsNotFound(err) {
 			log.Error(err, "no secrets containing tf state found in the namespace of state rescue resource")
 			return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -95,58 +155,116 @@ func (r *StateRescueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			backupSecrets.Items = append(backupSecrets.Items, item)
 		}
 	}
+	// only plan the backup and rescue actions in dry-run mode
+	if dryRun {
+		return r.planBackupAndRescue(ctx, stateRescue, originalSecrets, backupSecrets)
+	}
+	// clear actions planned while the state rescue object was in dry-run mode
+	if len(stateRescue.Status.PlannedActions) > 0 {
+		stateRescue.Status.PlannedActions = nil
+		if err := r.Status().Update(ctx, &stateRescue); err != nil {
+			log.Error(err, "unable to update state rescue resource")
+			return ctrl.Result{}, err
+		}
+		plannedActions.DeletePartialMatch(prometheus.Labels{"namespace": stateRescue.Namespace, "staterescue": stateRescue.Name})
+	}
 	// call backup and rescue logic to complete reconcilliation process
-	return r.backupAndRescue(ctx, stateRescue, originalSecrets, backupSecrets)
+	result, err := r.backupAndRescue(ctx, &stateRescue, originalSecrets, backupSecrets)
+	// verify the backups if due, even if backing up or rescuing failed
+	return r.withVerification(ctx, &stateRescue, result, err)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *StateRescueReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	r.rescueHistory = newRescueHistory()
+	// record the field managers of deleted state secrets to report them if the secrets keep being deleted
+	secretHandler := deletionRecorder{
+		EventHandler: handler.EnqueueRequestsFromMapFunc(r.findStateRescuesForSecret),
+		history:      r.rescueHistory,
+	}
+	r.runHistory = newRunHistory()
+	r.remoteClients = newRemoteClientCache()
+	// only the metadata of lock leases is needed to capture the lock info of terraform runs when they release their locks
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&terraformv1.StateRescue{}).
-		Owns(&corev1.Secret{}).
-		Watches(
-			&corev1.Secret{},
-			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
-				log := logf.FromContext(ctx)
-				// check if the secret is associated with a terraform state contains TF state label
-				if val, ok := obj.GetLabels()[TfStateLabelKey]; ok && val == TfStateLabelValue {
-					var stateRescueList terraformv1.StateRescueList
-					if err := r.List(ctx, &stateRescueList); err != nil {
-						if errors.IsNotFound(err) {
-							log.Info("no state rescue resources found")
-							return []reconcile.Request{}
-						} else {
-							log.Error(err, "unable to list stateRescue resources")
-						}
-					}
-					for _, item := range stateRescueList.Items {
-						if _, found := strings.CutPrefix(obj.GetName(), item.Spec.StateSecretName); found {
-							log.Info("TF state secret triggered a reconciliation event",
-								"Namespace", obj.GetNamespace(), "Secret", obj.GetName(),
-							)
-							return []reconcile.Request{
-								{
-									NamespacedName: types.NamespacedName{
-										Name:      item.Name,
-										Namespace: item.Namespace,
-									},
-								},
-							}
-						}
-					}
-				}
-				return []reconcile.Request{}
-			}),
-		).
+		Watches(&coordinationv1.Lease{}, r.lockReleaseRecorder(), builder.OnlyMetadata)
+	if r.SecretMetadataOnly {
+		bldr = bldr.
+			Owns(&corev1.Secret{}, builder.OnlyMetadata).
+			Watches(&corev1.Secret{}, secretHandler, builder.OnlyMetadata)
+	} else {
+		bldr = bldr.
+			Owns(&corev1.Secret{}).
+			Watches(&corev1.Secret{}, secretHandler)
+	}
+	return bldr.
 		Named("staterescue").
 		Complete(r)
 }
 
+// SecretCacheOptions returns the cache configuration that restricts the manager cache to secrets and leases
+// carrying the Terraform label. Every secret that the operator writes in the cluster of its StateRescue resources,
+// i.e. backups, snapshots and rescued state secrets, carries this label as well, so a selector on the operator
+// labels is not needed. Terraform sets the label on the lock leases of state secrets. Other secrets, e.g. signing
+// keys and kubeconfigs, are read directly from the API server.
+func SecretCacheOptions() map[client.Object]cache.ByObject {
+	return map[client.Object]cache.ByObject{
+		&corev1.Secret{}: {
+			Label: labels.SelectorFromSet(labels.Set{TfStateLabelKey: TfStateLabelValue}),
+		},
+		&coordinationv1.Lease{}: {
+			Label: labels.SelectorFromSet(labels.Set{TfStateLabelKey: TfStateLabelValue}),
+		},
+	}
+}
+
+// findStateRescuesForSecret maps events of terraform state secrets to the StateRescue resources in the same
+// namespace whose state secret name is a prefix of the secret name. The StateRescue resources are listed once
+// from the namespace index of the cache and matched in memory.
+func (r *StateRescueReconciler) findStateRescuesForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+	requests := []reconcile.Request{}
+
+	// check if the secret is associated with a terraform state contains TF state label
+	if val, ok := obj.GetLabels()[TfStateLabelKey]; !ok || val != TfStateLabelValue {
+		return requests
+	}
+	var stateRescueList terraformv1.StateRescueList
+	if err := r.List(ctx, &stateRescueList, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.Error(err, "unable to list stateRescue resources")
+		return requests
+	}
+	for _, item := range stateRescueList.Items {
+		if item.Spec.StateSecretName == "" || !strings.HasPrefix(obj.GetName(), item.Spec.StateSecretName) {
+			continue
+		}
+		log.Info("TF state secret triggered a reconciliation event",
+			"Namespace", obj.GetNamespace(), "Secret", obj.GetName(), "StateRescue", item.Name,
+		)
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      item.Name,
+				Namespace: item.Namespace,
+			},
+		})
+	}
+	return requests
+}
+
+// secretReader returns the reader for secrets, which are read directly from the API server
+// if only their metadata is cached so that no informer for complete secrets is started
+func (r *StateRescueReconciler) secretReader() client.Reader {
+	if r.SecretMetadataOnly {
+		return r.APIReader
+	}
+	return r.Client
+}
+
 // backupSecretForStaterescue returns a secret object for creating backup of an original secret
 // handled as a separate function to create a binding between backup objects and the CR
 // once the StateResuce CR is deleted, the controller will automatically delete backup objects
 // that were created during the lifecycle of the StateRescue resource
-func (r *StateRescueReconciler) backupsecretForStaterescue(ctx context.Context, staterescue *terraformv1.StateRescue, secret *corev1.Secret) (*corev1.Secret, error) {
+func (r *StateRescueReconciler) backupsecretForStaterescue(ctx context.Context, staterescue *terraformv1.StateRescue, signer *signer, secret *corev1.Secret) (*corev1.Secret, error) {
 	log := logf.FromContext(ctx)
 	backupString := "backup-"
 	// create backup Secret object
@@ -155,10 +273,15 @@ func (r *StateRescueReconciler) backupsecretForStaterescue(ctx context.Context,
 			Name:        backupString + secret.Name,
 			Namespace:   secret.Namespace,
 			Labels:      secret.Labels,
-			Annotations: secret.Annotations,
+			Annotations: maps.Clone(secret.Annotations),
 		},
 		Data: secret.Data,
 	}
+	if backupSecret.Annotations == nil {
+		backupSecret.Annotations = map[string]string{}
+	}
+	setChecksums(backupSecret.Annotations, backupSecret.Data)
+	signer.sign(backupSecret, backupSecret.Name)
 	// Set the ownerRef for the backup Secret, ensuring that the
 	// Secret will be deleted when the StateRescue CR is deleted.
 	if err := controllerutil.SetControllerReference(staterescue, backupSecret, r.Scheme); err != nil {
@@ -168,42 +291,250 @@ func (r *StateRescueReconciler) backupsecretForStaterescue(ctx context.Context,
 	return backupSecret, nil
 }
 
+// finalizeStateRescue enforces the deletion policy of the StateRescue resource on its backup secrets
+// before removing the finalizer, Retain removes owner references and labels the backups as orphaned,
+// Orphan only removes owner references and Delete removes the backups altogether
+func (r *StateRescueReconciler) finalizeStateRescue(ctx context.Context, stateRescue *terraformv1.StateRescue) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(stateRescue, StateRescueFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	secrets := &corev1.SecretList{}
+	if err := r.secretReader().List(ctx, secrets, client.InNamespace(stateRescue.Namespace), client.MatchingLabels{TfStateLabelKey: TfStateLabelValue}); err != nil {
+		log.Error(err, "unable to fetch secrets in the state rescue resource namespace")
+		return ctrl.Result{}, err
+	}
+	for _, item := range secrets.Items {
+		// only backups created or adopted by this state rescue object are affected
+		if !metav1.IsControlledBy(&item, stateRescue) {
+			continue
+		}
+		// the deletion policy is left empty if the manager has no default, backups are deleted then
+		policy := stateRescue.Spec.DeletionPolicy
+		if policy == "" {
+			policy = terraformv1.DeletionPolicyDelete
+		}
+		// held snapshots are always retained, so that a new StateRescue resource can adopt them. The holds are
+		// evaluated again, since holds added shortly before the deletion may not have been enforced yet.
+		if held(stateRescue, &item) {
+			policy = terraformv1.DeletionPolicyRetain
+		}
+		switch policy {
+		case terraformv1.DeletionPolicyRetain, terraformv1.DeletionPolicyOrphan:
+			if err := controllerutil.RemoveControllerReference(stateRescue, &item, r.Scheme); err != nil {
+				log.Error(err, "could not remove controller reference from the backup secret", "Secret", item.Name)
+				return ctrl.Result{}, err
+			}
+			if policy == terraformv1.DeletionPolicyRetain {
+				item.Labels[OrphanedLabelKey] = "true"
+			}
+			log.Info("Retaining the backup secret", "Secret", item.Name, "DeletionPolicy", policy)
+			if err := r.Update(ctx, &item); err != nil {
+				log.Error(err, "unable to update backup secret")
+				return ctrl.Result{}, err
+			}
+		case terraformv1.DeletionPolicyDelete:
+			log.Info("Deleting the backup secret", "Secret", item.Name)
+			if err := r.Delete(ctx, &item); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "unable to delete backup secret")
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	plannedActions.DeletePartialMatch(prometheus.Labels{"namespace": stateRescue.Namespace, "staterescue": stateRescue.Name})
+	replicationLag.DeleteLabelValues(stateRescue.Namespace, stateRescue.Name)
+	backupVerified.DeleteLabelValues(stateRescue.Namespace, stateRescue.Name)
+	resourceDropPercent.DeletePartialMatch(prometheus.Labels{"namespace": stateRescue.Namespace, "staterescue": stateRescue.Name})
+	controllerutil.RemoveFinalizer(stateRescue, StateRescueFinalizer)
+	if err := r.Update(ctx, stateRescue); err != nil {
+		log.Error(err, "unable to remove finalizer from state rescue resource")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// planBackupAndRescue runs the backup and rescue logic against a dry-run client, so that write requests
+// are validated by the API server but never persisted, and records the planned actions in the status
+func (r *StateRescueReconciler) planBackupAndRescue(ctx context.Context, stateRescue terraformv1.StateRescue, original *corev1.SecretList, backup *corev1.SecretList) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	planned := []terraformv1.PlannedAction{}
+	planner := &StateRescueReconciler{
+		Client:             client.NewDryRunClient(r.Client),
+		Scheme:             r.Scheme,
+		Recorder:           r.Recorder,
+		SecretMetadataOnly: r.SecretMetadataOnly,
+		APIReader:          r.APIReader,
+
+		RescueFlappingThreshold: r.RescueFlappingThreshold,
+		RescueFlappingWindow:    r.RescueFlappingWindow,
+		rescueHistory:           r.rescueHistory,
+		remoteClients:           r.remoteClients,
+		planned:                 &planned,
+	}
+	// the planner works on a copy, so that the status it sets while planning is never persisted
+	if result, err := planner.backupAndRescue(ctx, stateRescue.DeepCopy(), original, backup); err != nil {
+		// existing backups are verified in dry-run mode as well, even if planning failed
+		return r.withVerification(ctx, &stateRescue, result, err)
+	}
+
+	// report the number of planned actions per type
+	plannedActions.DeletePartialMatch(prometheus.Labels{"namespace": stateRescue.Namespace, "staterescue": stateRescue.Name})
+	for _, action := range planned {
+		plannedActions.WithLabelValues(stateRescue.Namespace, stateRescue.Name, string(action.Action)).Inc()
+	}
+
+	// only update the status if the planned actions have changed to avoid needless reconciliations
+	if !equality.Semantic.DeepEqual(stateRescue.Status.PlannedActions, planned) {
+		stateRescue.Status.PlannedActions = planned
+		if err := r.Status().Update(ctx, &stateRescue); err != nil {
+			log.Error(err, "unable to update state rescue resource")
+			return ctrl.Result{}, err
+		}
+	}
+	// existing backups are verified in dry-run mode as well
+	return r.withVerification(ctx, &stateRescue, ctrl.Result{}, nil)
+}
+
+// recordAction keeps track of an action in dry-run mode by emitting an event and adding it to the planned actions
+func (r *StateRescueReconciler) recordAction(stateRescue *terraformv1.StateRescue, action terraformv1.ActionType, secretName string) {
+	if r.planned == nil {
+		return
+	}
+	*r.planned = append(*r.planned, terraformv1.PlannedAction{Action: action, Secret: secretName})
+	r.Recorder.Eventf(stateRescue, corev1.EventTypeNormal, "DryRun", "Would %s for secret %s", action, secretName)
+}
+
+// adoptOrphanedBackups takes over backup secrets that were retained from a deleted StateRescue resource
+// by setting the controller reference to the current StateRescue resource and removing the orphan label
+func (r *StateRescueReconciler) adoptOrphanedBackups(ctx context.Context, stateRescue *terraformv1.StateRescue, backup *corev1.SecretList) error {
+	log := logf.FromContext(ctx)
+
+	for i := range backup.Items {
+		item := &backup.Items[i]
+		if _, ok := item.Labels[OrphanedLabelKey]; !ok || controllerutil.HasControllerReference(item) {
+			continue
+		}
+		if err := controllerutil.SetControllerReference(stateRescue, item, r.Scheme); err != nil {
+			log.Error(err, "could not set controller reference for the orphaned backup secret")
+			return err
+		}
+		delete(item.Labels, OrphanedLabelKey)
+		r.recordAction(stateRescue, terraformv1.ActionAdoptBackup, item.Name)
+		log.Info("Adopting the orphaned backup secret", "Secret", item.Name)
+		if err := r.Update(ctx, item); err != nil {
+			log.Error(err, "unable to adopt orphaned backup secret")
+			return err
+		}
+	}
+	return nil
+}
+
+// setDiffSummary annotates the backup secret with a summary of the resource level changes between the state
+// it currently holds and the state of the original secret it is updated with, states that cannot be decoded
+// are not summarized
+func setDiffSummary(ctx context.Context, backupSecret *corev1.Secret, original *corev1.Secret) {
+	log := logf.FromContext(ctx)
+
+	previous, err := state.FromSecret(backupSecret)
+	if err != nil {
+		log.Info("unable to decode the state of the backup secret", "Secret", backupSecret.Name, "reason", err.Error())
+		delete(backupSecret.Annotations, DiffSummaryAnnotationKey)
+		return
+	}
+	current, err := state.FromSecret(original)
+	if err != nil {
+		log.Info("unable to decode the state of the original secret", "Secret", original.Name, "reason", err.Error())
+		delete(backupSecret.Annotations, DiffSummaryAnnotationKey)
+		return
+	}
+	metav1.SetMetaDataAnnotation(&backupSecret.ObjectMeta, DiffSummaryAnnotationKey, state.Compare(previous, current).Summary())
+}
+
 // logic for creating backup secrets and rescuing originals if they are deleted
 // original secrets have the tfstate label set to true while it is false for backup secrets
 // to avoid issues when reading/updating state in the original secret(s) by the terraform client
-func (r *StateRescueReconciler) backupAndRescue(ctx context.Context, stateRescue terraformv1.StateRescue, original *corev1.SecretList, backup *corev1.SecretList) (ctrl.Result, error) {
+func (r *StateRescueReconciler) backupAndRescue(ctx context.Context, stateRescue *terraformv1.StateRescue, original *corev1.SecretList, backup *corev1.SecretList) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 
+	// load the signing key before any backup is written or used to rescue a state
+	signer, err := r.loadSigner(ctx, stateRescue)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// adopt backups retained by a previously deleted state rescue object for the same secrets
+	if err := r.adoptOrphanedBackups(ctx, stateRescue, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// restore an original secret from its backup if requested
+	if err := r.handleRestoreRequest(ctx, stateRescue, signer, original, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+	// take tagged snapshots of the states if requested
+	if err := r.handleSnapshotRequest(ctx, stateRescue, signer, original); err != nil {
+		return ctrl.Result{}, err
+	}
+	// mark the held snapshots before any snapshot is pruned
+	if err := r.enforceHolds(ctx, stateRescue); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// check if original secret is missing against a backup one
 	// and rescue the original from back up if needed
+	breakers := &openBreakers{}
 	for _, item := range backup.Items {
 		origSecretNameStr := strings.TrimPrefix(item.Name, "backup-")
 		originalSecret := &corev1.Secret{}
 
-		if err := r.Get(ctx, types.NamespacedName{Name: origSecretNameStr, Namespace: item.Namespace}, originalSecret); err != nil {
+		if err := r.secretReader().Get(ctx, types.NamespacedName{Name: origSecretNameStr, Namespace: item.Namespace}, originalSecret); err != nil {
 			if errors.IsNotFound(err) {
 				log.Info("original secret with terraform state not found in the state rescue namespace")
-				// create state secret object from backup data
+				// stop rescuing a secret that another system keeps deleting until its oldest rescue leaves the window
+				key := types.NamespacedName{Name: origSecretNameStr, Namespace: item.Namespace}
+				if r.rescueTripped(stateRescue, key, breakers) {
+					log.Info("not rescuing the original secret since it is rescued too often", "Secret", origSecretNameStr)
+					continue
+				}
+				// never rescue from a backup that has been modified since it was signed
+				if err := signer.verify(item.Name, &item); err != nil {
+					if err := r.refuseTampered(ctx, stateRescue, err); err != nil {
+						return ctrl.Result{}, err
+					}
+					continue
+				}
+				// create state secret object from backup data and the signed metadata of the backup
+				rescuedLabels := unmanaged(item.Labels)
+				if rescuedLabels == nil {
+					rescuedLabels = map[string]string{}
+				}
 				originalSecret = &corev1.Secret{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:        origSecretNameStr,
 						Namespace:   item.Namespace,
-						Labels:      item.Labels,
-						Annotations: item.Annotations,
+						Labels:      rescuedLabels,
+						Annotations: unmanaged(item.Annotations),
 					},
 					Data: item.Data,
 				}
 				// update tfstate label to true for the original secret
-				originalSecret.Labels["tfstate"] = "true"
+				rescuedLabels[TfStateLabelKey] = TfStateLabelValue
+				rescuedLabels["tfstate"] = "true"
+				r.recordAction(stateRescue, terraformv1.ActionRescue, origSecretNameStr)
 				// create secret
 				log.Info("creating an original secret from backup secret", "Secret", item.Name)
-				if err := r.Create(ctx, originalSecret); err != nil {
+				if err := r.Create(ctx, originalSecret, client.FieldOwner(FieldManager)); err != nil {
 					log.Error(err, "unable to create the original secret")
 					return ctrl.Result{}, err
 				}
+				r.recordRescued(stateRescue, key, "its backup")
 				// update rescue time
 				stateRescue.Status.LastRescueTime = metav1.Now()
-				if err := r.Status().Update(ctx, &stateRescue); err != nil {
+				if err := r.Status().Update(ctx, stateRescue); err != nil {
 					log.Error(err, "unable to update state rescue resource")
 					return ctrl.Result{}, err
 				}
@@ -214,27 +545,48 @@ func (r *StateRescueReconciler) backupAndRescue(ctx context.Context, stateRescue
 		}
 	}
 
+	// never overwrite the backups of states that terraform re-initialised with a new lineage
+	if original, err = r.guardReinitialized(ctx, stateRescue, signer, original, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+	// keep the previous states of states that lost more resources than allowed
+	if stateRescue.Spec.Protection != nil {
+		if original, err = r.guardResourceDrop(ctx, stateRescue, signer, original, backup); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// check if backup secrets exist against the original ones
 	// create or update backup secrets if not found
 	for _, item := range original.Items {
+		// write an immutable snapshot of every generation of the state if enabled
+		if stateRescue.Spec.Generations > 0 {
+			if err := r.snapshotGeneration(ctx, stateRescue, signer, &item); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 		backupSecret := &corev1.Secret{}
-		if err := r.Get(ctx, types.NamespacedName{Name: "backup-" + item.Name, Namespace: item.Namespace}, backupSecret); err != nil {
+		if err := r.secretReader().Get(ctx, types.NamespacedName{Name: "backup-" + item.Name, Namespace: item.Namespace}, backupSecret); err != nil {
 			if errors.IsNotFound(err) {
 				// create backup secret for the original one
-				if backupSecret, err = r.backupsecretForStaterescue(ctx, &stateRescue, &item); err != nil {
+				if backupSecret, err = r.backupsecretForStaterescue(ctx, stateRescue, signer, &item); err != nil {
 					log.Error(err, "unable to fetch backup secret object")
 					return ctrl.Result{}, err
 				}
 				// update tfstate label to false for the backup secret
 				backupSecret.Labels["tfstate"] = "false"
+				r.recordAction(stateRescue, terraformv1.ActionCreateBackup, item.Name)
 				log.Info("Creating the backup state secret for the original secret", "Secret", item.Name)
 				if err := r.Create(ctx, backupSecret); err != nil {
 					log.Error(err, "unable to create the backup secret")
 					return ctrl.Result{}, err
 				}
+				if r.planned == nil {
+					r.runHistory.recordBackup(client.ObjectKeyFromObject(&item), time.Now())
+				}
 				// update backup time
 				stateRescue.Status.LastBackupTime = metav1.Now()
-				if err := r.Status().Update(ctx, &stateRescue); err != nil {
+				if err := r.Status().Update(ctx, stateRescue); err != nil {
 					log.Error(err, "unable to update state rescue resource")
 					return ctrl.Result{}, err
 				}
@@ -245,23 +597,75 @@ func (r *StateRescueReconciler) backupAndRescue(ctx context.Context, stateRescue
 				return ctrl.Result{}, err
 			}
 		}
-		// if backup secret already exists, then only update its data
+		// if backup secret already exists, then only update its data and the metadata that is restored with it
+		// if they have changed or if its checksums or signature have not been recorded yet,
+		// e.g. after the signing key was rotated
+		_, reinitialized := backupSecret.Annotations[ReinitializedAnnotationKey]
+		if equality.Semantic.DeepEqual(backupSecret.Data, item.Data) && hasChecksums(backupSecret) && !reinitialized &&
+			equality.Semantic.DeepEqual(unmanaged(backupSecret.Labels), unmanaged(item.Labels)) &&
+			equality.Semantic.DeepEqual(unmanaged(backupSecret.Annotations), unmanaged(item.Annotations)) &&
+			!signer.needsSigning(backupSecret.Name, backupSecret) {
+			continue
+		}
+		r.recordAction(stateRescue, terraformv1.ActionUpdateBackup, item.Name)
 		log.Info("Updating the backup secret of the original secret", "Secret", item.Name)
-		// copy data of original state file secret to backup secret
+		if stateRescue.Spec.DiffSummary {
+			setDiffSummary(ctx, backupSecret, &item)
+		}
+		// a run recorded on the backup did not produce the changed state
+		changed := !equality.Semantic.DeepEqual(backupSecret.Data, item.Data)
+		if changed {
+			delete(backupSecret.Annotations, RunAnnotationKey)
+		}
+		// copy data and metadata of original state file secret to backup secret
 		backupSecret.Data = item.Data
+		backupSecret.Labels = withMetadataOf(backupSecret.Labels, item.Labels)
+		backupSecret.Annotations = withMetadataOf(backupSecret.Annotations, item.Annotations)
+		// the backup no longer holds the previous lineage of a re-initialised state once it is updated
+		delete(backupSecret.Annotations, ReinitializedAnnotationKey)
+		setChecksums(backupSecret.Annotations, backupSecret.Data)
+		signer.sign(backupSecret, backupSecret.Name)
 		if err := r.Update(ctx, backupSecret); err != nil {
 			log.Error(err, "unable to update backup secret")
 			return ctrl.Result{}, err
 		}
+		if changed && r.planned == nil {
+			r.runHistory.recordBackup(client.ObjectKeyFromObject(&item), time.Now())
+		}
 		// update backup time
 		stateRescue.Status.LastBackupTime = metav1.Now()
-		if err := r.Update(ctx, &stateRescue); err != nil {
+		if err := r.Status().Update(ctx, stateRescue); err != nil {
 			log.Error(err, "unable to update state rescue resource")
 			return ctrl.Result{}, err
 		}
 	}
 
-	// successfully return after updating backup and rescuing
-	return ctrl.Result{}, nil
+	// attribute the snapshots of changed states to the terraform runs that released their locks
+	if r.planned == nil {
+		if err := r.attributeRuns(ctx, stateRescue, original); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// replicate backups to the remote cluster and rescue from there if local backups are gone
+	if stateRescue.Spec.Destination != nil && stateRescue.Spec.Destination.RemoteCluster != nil {
+		if err := r.syncRemoteCluster(ctx, stateRescue, signer, original, backup, breakers); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// the circuit breaker is only reported if the rescues are limited
+	if r.RescueFlappingThreshold > 0 && setFlapping(stateRescue, breakers.messages) {
+		if r.planned == nil && len(breakers.messages) > 0 {
+			r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "RescueFlapping", "Stopped rescuing: %s", strings.Join(breakers.messages, "; "))
+		}
+		if err := r.Status().Update(ctx, stateRescue); err != nil {
+			log.Error(err, "unable to update state rescue resource")
+			return ctrl.Result{}, err
+		}
+	}
 
+	// successfully return after updating backup and rescuing,
+	// and reconcile again once the circuit breaker of a flapping secret closes
+	return ctrl.Result{RequeueAfter: breakers.retryAfter}, nil
 }
@@ -18,16 +18,28 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
 )
 
 var _ = Describe("StateRescue Controller", func() {
@@ -140,4 +152,1787 @@ var _ = Describe("StateRescue Controller", func() {
 			// Expect(createdBackupSecret).To(BeNil())
 		})
 	})
+	Context("When deleting a StateRescue resource with deletionPolicy Retain", func() {
+		It("Should keep the backup Secret as orphaned and adopt it in a new StateRescue resource", func() {
+			const (
+				retainStateRescueName = "test-staterescue-retain"
+				retainSecretName      = "test-secret-retain"
+			)
+			ctx := context.Background()
+
+			By("Creating a StateRescue resource with deletionPolicy Retain")
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      retainStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: retainSecretName,
+					DeletionPolicy:  terraformv1.DeletionPolicyRetain,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      retainSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + retainSecretName, Namespace: StateRescueNamespace}
+			backupSecret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(metav1.IsControlledBy(backupSecret, stateRescue)).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+
+			By("Deleting the StateRescue resource")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			stateRescueLookupKey := types.NamespacedName{Name: retainStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(errors.IsNotFound(k8sClient.Get(ctx, stateRescueLookupKey, &terraformv1.StateRescue{}))).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the backup Secret was retained and labelled as orphaned")
+			Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+			Expect(backupSecret.OwnerReferences).To(BeEmpty())
+			Expect(backupSecret.Labels).To(HaveKeyWithValue(OrphanedLabelKey, "true"))
+
+			By("Creating a new StateRescue resource for the same Secret")
+			newStateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      retainStateRescueName + "-new",
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: retainSecretName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, newStateRescue)).To(Succeed())
+
+			By("Checking that the orphaned backup Secret was adopted")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(metav1.IsControlledBy(backupSecret, newStateRescue)).To(BeTrue())
+				g.Expect(backupSecret.Labels).NotTo(HaveKey(OrphanedLabelKey))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, newStateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When a StateRescue resource is in dry-run mode", func() {
+		It("Should only record planned actions without creating a backup Secret", func() {
+			const (
+				dryRunStateRescueName = "test-staterescue-dryrun"
+				dryRunSecretName      = "test-secret-dryrun"
+			)
+			ctx := context.Background()
+
+			By("Creating a StateRescue resource with dryRun enabled")
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      dryRunStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: dryRunSecretName,
+					DryRun:          true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      dryRunSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the backup action was planned")
+			stateRescueLookupKey := types.NamespacedName{Name: dryRunStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Status.PlannedActions).To(ConsistOf(terraformv1.PlannedAction{
+					Action: terraformv1.ActionCreateBackup,
+					Secret: dryRunSecretName,
+				}))
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that no backup Secret was created")
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + dryRunSecretName, Namespace: StateRescueNamespace}
+			Consistently(func(g Gomega) {
+				g.Expect(errors.IsNotFound(k8sClient.Get(ctx, backupSecretLookupKey, &corev1.Secret{}))).To(BeTrue())
+			}, time.Second*2, interval).Should(Succeed())
+			Expect(stateRescue.Status.LastBackupTime.IsZero()).To(BeTrue())
+
+			By("Checking that no finalizer was added to the StateRescue resource")
+			Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+			Expect(stateRescue.Finalizers).To(BeEmpty())
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When a restore is requested on a StateRescue resource", func() {
+		It("Should overwrite the TF state secret with the data of its backup and remove the request", func() {
+			const (
+				restoreStateRescueName = "test-staterescue-restore"
+				restoreSecretName      = "test-secret-restore"
+			)
+			ctx := context.Background()
+			labels := map[string]string{
+				"tfstate":                      "true",
+				"app.kubernetes.io/managed-by": "terraform",
+			}
+
+			By("Creating a TF state secret that differs from its backup")
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      restoreSecretName,
+					Namespace: StateRescueNamespace,
+					Labels:    labels,
+				},
+				Data: map[string][]byte{"tfstate": []byte("broken")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+			backupSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backup-" + restoreSecretName,
+					Namespace: StateRescueNamespace,
+					Labels:    labels,
+				},
+				Data: map[string][]byte{"tfstate": []byte("good")},
+			}
+			Expect(k8sClient.Create(ctx, backupSecret)).To(Succeed())
+
+			By("Creating a StateRescue resource requesting the restore of the secret")
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        restoreStateRescueName,
+					Namespace:   StateRescueNamespace,
+					Annotations: map[string]string{RestoreAnnotationKey: restoreSecretName},
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: restoreSecretName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			By("Checking that the secret was restored from its backup")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(testSecret), testSecret)).To(Succeed())
+				g.Expect(testSecret.Data).To(HaveKeyWithValue("tfstate", []byte("good")))
+			}, timeout, interval).Should(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(backupSecret), backupSecret)).To(Succeed())
+			Expect(backupSecret.Data).To(HaveKeyWithValue("tfstate", []byte("good")))
+
+			By("Checking that the restore request was removed")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(stateRescue), stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Annotations).NotTo(HaveKey(RestoreAnnotationKey))
+				g.Expect(stateRescue.Status.LastRescueTime.IsZero()).To(BeFalse())
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secrets")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+		It("Should overwrite the TF state secret with the data of the requested snapshot", func() {
+			const (
+				snapshotRestoreStateRescueName = "test-staterescue-restore-snapshot"
+				snapshotRestoreSecretName      = "test-secret-restore-snapshot"
+			)
+			ctx := context.Background()
+
+			By("Creating a StateRescue resource keeping snapshots and a TF state secret")
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      snapshotRestoreStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: snapshotRestoreSecretName,
+					Generations:     2,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      snapshotRestoreSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state-1")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+			first := snapshotName(snapshotRestoreSecretName, testSecret.Data)
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: first, Namespace: StateRescueNamespace}, &corev1.Secret{})).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Updating the TF state")
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-2")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			backupLookupKey := types.NamespacedName{Name: "backup-" + snapshotRestoreSecretName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				backupSecret := &corev1.Secret{}
+				g.Expect(k8sClient.Get(ctx, backupLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(HaveKeyWithValue("tfstate", []byte("state-2")))
+			}, timeout, interval).Should(Succeed())
+
+			By("Requesting the restore of a secret that is not a snapshot of the StateRescue resource")
+			forged := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "snapshot-" + snapshotRestoreSecretName + "-forged",
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by": "terraform",
+						SnapshotLabelKey:               "true",
+					},
+					Annotations: map[string]string{SnapshotOfAnnotationKey: snapshotRestoreSecretName},
+				},
+				Data: map[string][]byte{"tfstate": []byte("forged")},
+			}
+			Expect(k8sClient.Create(ctx, forged)).To(Succeed())
+			stateRescueLookupKey := client.ObjectKeyFromObject(stateRescue)
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				metav1.SetMetaDataAnnotation(&stateRescue.ObjectMeta, RestoreAnnotationKey, forged.Name)
+				g.Expect(k8sClient.Update(ctx, stateRescue)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Annotations).NotTo(HaveKey(RestoreAnnotationKey))
+			}, timeout, interval).Should(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(testSecret), testSecret)).To(Succeed())
+			Expect(testSecret.Data).To(HaveKeyWithValue("tfstate", []byte("state-2")))
+
+			By("Requesting the restore of the snapshot of the first state")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				metav1.SetMetaDataAnnotation(&stateRescue.ObjectMeta, RestoreAnnotationKey, first)
+				g.Expect(k8sClient.Update(ctx, stateRescue)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the secret was restored from the snapshot and backed up")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(testSecret), testSecret)).To(Succeed())
+				g.Expect(testSecret.Data).To(HaveKeyWithValue("tfstate", []byte("state-1")))
+				backupSecret := &corev1.Secret{}
+				g.Expect(k8sClient.Get(ctx, backupLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(HaveKeyWithValue("tfstate", []byte("state-1")))
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Annotations).NotTo(HaveKey(RestoreAnnotationKey))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secrets")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, forged)).To(Succeed())
+		})
+	})
+	Context("When a StateRescue resource records diff summaries", func() {
+		It("Should annotate the backup Secret with a summary of the changes against the previous backup", func() {
+			const (
+				diffStateRescueName = "test-staterescue-diff"
+				diffSecretName      = "test-secret-diff"
+			)
+			ctx := context.Background()
+			encodeState := func(resources string) []byte {
+				data, err := state.Encode([]byte(`{"version": 4, "serial": 1, "lineage": "l", "resources": [` + resources + `]}`))
+				Expect(err).NotTo(HaveOccurred())
+				return data
+			}
+			instance := `{"mode": "managed", "type": "aws_instance", "name": "%s", "provider": "aws", "instances": [{"attributes": {}}]}`
+
+			By("Creating a StateRescue resource with diffSummary enabled")
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      diffStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: diffSecretName,
+					DiffSummary:     true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      diffSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": encodeState(fmt.Sprintf(instance, "web"))},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			backupSecret := &corev1.Secret{}
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + diffSecretName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Adding a resource to the TF state")
+			testSecret.Data["tfstate"] = encodeState(fmt.Sprintf(instance, "web") + "," + fmt.Sprintf(instance, "db"))
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+
+			By("Checking the diff summary of the updated backup Secret")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(Equal(testSecret.Data))
+				g.Expect(backupSecret.Annotations).To(HaveKeyWithValue(DiffSummaryAnnotationKey,
+					"1 added, 0 removed, 0 changed, 0 outputs changed"))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When the backups of a StateRescue resource are verified", func() {
+		It("Should record checksums of the backup Secret and report a mismatch in the BackupVerified condition", func() {
+			const (
+				verifyStateRescueName = "test-staterescue-verify"
+				verifySecretName      = "test-secret-verify"
+			)
+			ctx := context.Background()
+			data, err := state.Encode([]byte(`{"version": 4, "serial": 1, "lineage": "l", "resources": []}`))
+			Expect(err).NotTo(HaveOccurred())
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      verifyStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: verifySecretName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      verifySecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": data},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			By("Checking the checksums of the backup Secret")
+			backupSecret := &corev1.Secret{}
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + verifySecretName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Annotations).To(HaveKey(PayloadChecksumAnnotationKey))
+				g.Expect(backupSecret.Annotations).To(HaveKey(StateChecksumAnnotationKey))
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the backup Secret was verified")
+			stateRescueLookupKey := types.NamespacedName{Name: verifyStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionTrue(stateRescue.Status.Conditions, terraformv1.ConditionBackupVerified)).To(BeTrue())
+				g.Expect(stateRescue.Status.LastVerificationTime.IsZero()).To(BeFalse())
+			}, timeout, interval).Should(Succeed())
+
+			By("Corrupting the recorded checksum of the backup Secret")
+			backupSecret.Annotations[PayloadChecksumAnnotationKey] = "corrupted"
+			Expect(k8sClient.Update(ctx, backupSecret)).To(Succeed())
+
+			By("Checking that the mismatch is reported")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				condition := meta.FindStatusCondition(stateRescue.Status.Conditions, terraformv1.ConditionBackupVerified)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+				g.Expect(condition.Reason).To(Equal("VerificationFailed"))
+				g.Expect(condition.Message).To(ContainSubstring("backup-" + verifySecretName))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+		It("Should verify the backups even if the backup and rescue logic keeps failing", func() {
+			const (
+				failingStateRescueName = "test-staterescue-verify-failing"
+				failingSecretName      = "test-secret-verify-failing"
+			)
+			ctx := context.Background()
+
+			By("Creating a StateRescue resource replicating to a remote cluster without a kubeconfig secret")
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      failingStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: failingSecretName,
+					Destination: &terraformv1.BackupDestination{
+						RemoteCluster: &terraformv1.RemoteClusterDestination{
+							KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: "missing-kubeconfig"},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      failingSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the replication fails and the backups are verified nevertheless")
+			stateRescueLookupKey := types.NamespacedName{Name: failingStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionFalse(stateRescue.Status.Conditions, terraformv1.ConditionReplicated)).To(BeTrue())
+				condition := meta.FindStatusCondition(stateRescue.Status.Conditions, terraformv1.ConditionBackupVerified)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Message).To(ContainSubstring("replicas cannot be read"))
+				g.Expect(stateRescue.Status.LastVerificationTime.IsZero()).To(BeFalse())
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When the backups of a StateRescue resource are signed", func() {
+		It("Should sign the backup Secret and refuse to rescue from a backup that does not match its signature", func() {
+			const (
+				signedStateRescueName = "test-staterescue-signed"
+				signedSecretName      = "test-secret-signed"
+				signingKeySecretName  = "signing-key"
+			)
+			ctx := context.Background()
+
+			By("Creating a Secret with the signing key")
+			keySecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      signingKeySecretName,
+					Namespace: StateRescueNamespace,
+				},
+				Data: map[string][]byte{"key": []byte("signing-key")},
+			}
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      signedStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: signedSecretName,
+					Signing:         &terraformv1.SigningOptions{KeySecretName: signingKeySecretName},
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			labels := map[string]string{
+				"tfstate":                      "true",
+				"app.kubernetes.io/managed-by": "terraform",
+			}
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      signedSecretName,
+					Namespace: StateRescueNamespace,
+					Labels:    labels,
+				},
+				Data: map[string][]byte{"tfstate": []byte("state")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			By("Checking the signature of the backup Secret")
+			backupSecret := &corev1.Secret{}
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + signedSecretName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Annotations[SignatureAnnotationKey]).To(HavePrefix("HMAC-SHA256:"))
+			}, timeout, interval).Should(Succeed())
+
+			By("Creating a forged backup Secret for a missing TF state secret")
+			forgedSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "backup-" + signedSecretName + "-forged",
+					Namespace:   StateRescueNamespace,
+					Labels:      labels,
+					Annotations: backupSecret.Annotations,
+				},
+				Data: map[string][]byte{"tfstate": []byte("forged")},
+			}
+			Expect(k8sClient.Create(ctx, forgedSecret)).To(Succeed())
+
+			By("Checking that the forged backup is reported and not used to rescue the TF state secret")
+			stateRescueLookupKey := types.NamespacedName{Name: signedStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				condition := meta.FindStatusCondition(stateRescue.Status.Conditions, terraformv1.ConditionBackupTampered)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				g.Expect(condition.Message).To(ContainSubstring(forgedSecret.Name))
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: signedSecretName + "-forged", Namespace: StateRescueNamespace}, &corev1.Secret{})
+				return errors.IsNotFound(err)
+			}, time.Second*2, interval).Should(BeTrue())
+
+			By("Deleting the signing key")
+			Expect(k8sClient.Delete(ctx, keySecret)).To(Succeed())
+			testSecret.Data = map[string][]byte{"tfstate": []byte("unsigned")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the backup is still written and the missing signing key is reported")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionFalse(stateRescue.Status.Conditions, terraformv1.ConditionSigningKeyAvailable)).To(BeTrue())
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(Equal(testSecret.Data))
+				g.Expect(backupSecret.Annotations).NotTo(HaveKey(SignatureAnnotationKey))
+			}, timeout, interval).Should(Succeed())
+
+			By("Restoring the signing key")
+			keySecret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      signingKeySecretName,
+					Namespace: StateRescueNamespace,
+				},
+				Data: map[string][]byte{"key": []byte("signing-key")},
+			}
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			testSecret.Data = map[string][]byte{"tfstate": []byte("signed")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionTrue(stateRescue.Status.Conditions, terraformv1.ConditionSigningKeyAvailable)).To(BeTrue())
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Annotations[SignatureAnnotationKey]).To(HavePrefix("HMAC-SHA256:"))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the secrets")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, forgedSecret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, keySecret)).To(Succeed())
+		})
+		It("Should sign the metadata that is restored with a backup", func() {
+			s := &signer{algorithm: terraformv1.SigningHMACSHA256, hmacKey: []byte("signing-key")}
+			backupSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "backup-test-secret-metadata",
+					Labels: map[string]string{
+						"tfstate":          "false",
+						"tfstateWorkspace": "default",
+					},
+					Annotations: map[string]string{"team": "platform"},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state")},
+			}
+			s.sign(backupSecret, backupSecret.Name)
+			Expect(s.verify(backupSecret.Name, backupSecret)).To(Succeed())
+
+			By("Accepting changes of the labels and annotations that the controller manages")
+			backupSecret.Labels[SnapshotPinnedLabelKey] = "true"
+			backupSecret.Annotations[DiffSummaryAnnotationKey] = "~1"
+			Expect(s.verify(backupSecret.Name, backupSecret)).To(Succeed())
+
+			By("Refusing changes of the labels and annotations that are restored")
+			backupSecret.Labels["tfstateWorkspace"] = "production"
+			Expect(s.verify(backupSecret.Name, backupSecret)).To(MatchError(ContainSubstring("does not match its signature")))
+			backupSecret.Labels["tfstateWorkspace"] = "default"
+			backupSecret.Annotations["team"] = "other"
+			Expect(s.verify(backupSecret.Name, backupSecret)).To(MatchError(ContainSubstring("does not match its signature")))
+		})
+	})
+	Context("When a StateRescue resource keeps generations of snapshots", func() {
+		It("Should write an immutable snapshot of every state and delete the oldest snapshots", func() {
+			const (
+				generationsStateRescueName = "test-staterescue-generations"
+				generationsSecretName      = "test-secret-generations"
+			)
+			ctx := context.Background()
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      generationsStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: generationsSecretName,
+					Generations:     2,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      generationsSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state-1")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			listSnapshots := func(g Gomega) []corev1.Secret {
+				secrets := &corev1.SecretList{}
+				g.Expect(k8sClient.List(ctx, secrets, client.InNamespace(StateRescueNamespace), client.MatchingLabels{SnapshotLabelKey: "true"})).To(Succeed())
+				snapshots := []corev1.Secret{}
+				for _, item := range secrets.Items {
+					if item.Annotations[SnapshotOfAnnotationKey] == generationsSecretName {
+						snapshots = append(snapshots, item)
+					}
+				}
+				return snapshots
+			}
+			snapshotNames := func(g Gomega) []string {
+				names := []string{}
+				for _, item := range listSnapshots(g) {
+					names = append(names, item.Name)
+				}
+				return names
+			}
+
+			By("Checking the immutable snapshot of the first state")
+			first := snapshotName(generationsSecretName, testSecret.Data)
+			Eventually(func(g Gomega) {
+				snapshots := listSnapshots(g)
+				g.Expect(snapshots).To(HaveLen(1))
+				g.Expect(snapshots[0].Name).To(Equal(first))
+				g.Expect(snapshots[0].Immutable).To(HaveValue(BeTrue()))
+				g.Expect(snapshots[0].Data).To(Equal(testSecret.Data))
+			}, timeout, interval).Should(Succeed())
+
+			By("Updating the TF state twice")
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-2")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			second := snapshotName(generationsSecretName, testSecret.Data)
+			Eventually(snapshotNames, timeout, interval).Should(ContainElement(second))
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-3")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			third := snapshotName(generationsSecretName, testSecret.Data)
+
+			By("Checking that only the two newest snapshots are kept")
+			Eventually(snapshotNames, timeout, interval).Should(ConsistOf(second, third))
+
+			By("Reverting the TF state to the second state")
+			secondSnapshot := &corev1.Secret{}
+			secondSnapshotKey := types.NamespacedName{Name: second, Namespace: StateRescueNamespace}
+			Expect(k8sClient.Get(ctx, secondSnapshotKey, secondSnapshot)).To(Succeed())
+			secondTime := secondSnapshot.Annotations[SnapshotTimeAnnotationKey]
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-2")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, secondSnapshotKey, secondSnapshot)).To(Succeed())
+				g.Expect(secondSnapshot.Annotations[SnapshotTimeAnnotationKey]).NotTo(Equal(secondTime))
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the reverted state is kept as the newest generation before the third state")
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-4")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			fourth := snapshotName(generationsSecretName, testSecret.Data)
+			Eventually(snapshotNames, timeout, interval).Should(ConsistOf(second, fourth))
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+		It("Should derive valid snapshot names from the longest secret names", func() {
+			data := map[string][]byte{"tfstate": []byte("state-1")}
+			// the name is truncated right after the dot, which must not end the truncated name
+			longName := "tfstate-default-" + strings.Repeat("a", 207) + "." + strings.Repeat("b", 29)
+			name := snapshotName(longName, data)
+			Expect(validation.IsDNS1123Subdomain(name)).To(BeEmpty())
+			Expect(name).To(HavePrefix("snapshot-tfstate-default-aaa"))
+			Expect(snapshotName(longName, data)).To(Equal(name))
+
+			By("Keeping the names of secrets that differ only in their truncated part apart")
+			Expect(snapshotName(longName+"c", data)).NotTo(Equal(name))
+
+			By("Keeping short secret names as they are")
+			Expect(snapshotName("tfstate-default-state", data)).To(HavePrefix("snapshot-tfstate-default-state-"))
+			Expect(snapshotName("tfstate-default-state", data)).To(HaveLen(len("snapshot-tfstate-default-state-") + 10))
+		})
+	})
+	Context("When a terraform run releases the lock of a TF state", func() {
+		It("Should attribute the snapshot of the changed state to the run", func() {
+			const (
+				lockStateRescueName = "test-staterescue-lock"
+				lockSecretName      = "test-secret-lock"
+			)
+			ctx := context.Background()
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      lockStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: lockSecretName,
+					Generations:     3,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			tfLabels := map[string]string{
+				"tfstate":                      "true",
+				"app.kubernetes.io/managed-by": "terraform",
+			}
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      lockSecretName,
+					Namespace: StateRescueNamespace,
+					Labels:    tfLabels,
+				},
+				Data: map[string][]byte{"tfstate": []byte("state-1")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			getSnapshot := func(g Gomega, name string) *corev1.Secret {
+				snapshot := &corev1.Secret{}
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: StateRescueNamespace}, snapshot)).To(Succeed())
+				return snapshot
+			}
+			first := snapshotName(lockSecretName, testSecret.Data)
+			Eventually(func(g Gomega) { getSnapshot(g, first) }, timeout, interval).Should(Succeed())
+
+			By("Acquiring the lock of the TF state like terraform does")
+			lock := LockInfo{
+				ID:        "d4f1c1de-8f3a-4a7b-9d8e-0c6e1b2a3f45",
+				Operation: "OperationTypeApply",
+				Who:       "ci@runner-1",
+				Version:   "1.9.5",
+				Created:   time.Now().UTC(),
+			}
+			lockJSON, err := json.Marshal(lock)
+			Expect(err).NotTo(HaveOccurred())
+			lease := &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "lock-" + lockSecretName,
+					Namespace:   StateRescueNamespace,
+					Labels:      tfLabels,
+					Annotations: map[string]string{LockInfoAnnotationKey: string(lockJSON)},
+				},
+				Spec: coordinationv1.LeaseSpec{HolderIdentity: &lock.ID},
+			}
+			Expect(k8sClient.Create(ctx, lease)).To(Succeed())
+
+			By("Changing the TF state while the lock is held")
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-2")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			second := snapshotName(lockSecretName, testSecret.Data)
+			Eventually(func(g Gomega) { getSnapshot(g, second) }, timeout, interval).Should(Succeed())
+
+			By("Releasing the lock")
+			delete(lease.Annotations, LockInfoAnnotationKey)
+			lease.Spec.HolderIdentity = nil
+			Expect(k8sClient.Update(ctx, lease)).To(Succeed())
+
+			By("Checking that only the snapshot of the changed state is attributed to the run")
+			Eventually(func(g Gomega) {
+				snapshot := getSnapshot(g, second)
+				g.Expect(snapshot.Annotations).To(HaveKey(RunAnnotationKey))
+				attributed := LockInfo{}
+				g.Expect(json.Unmarshal([]byte(snapshot.Annotations[RunAnnotationKey]), &attributed)).To(Succeed())
+				g.Expect(attributed.ID).To(Equal(lock.ID))
+				g.Expect(attributed.Operation).To(Equal("OperationTypeApply"))
+				g.Expect(attributed.Who).To(Equal("ci@runner-1"))
+			}, timeout, interval).Should(Succeed())
+			Expect(getSnapshot(Default, first).Annotations).NotTo(HaveKey(RunAnnotationKey))
+
+			By("Cleanup the StateRescue resource, the lock lease and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, lease)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+		It("Should attribute the backup of the changed state to the run without generations", func() {
+			const (
+				backupLockStateRescueName = "test-staterescue-lock-backup"
+				backupLockSecretName      = "test-secret-lock-backup"
+			)
+			ctx := context.Background()
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupLockStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: backupLockSecretName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			tfLabels := map[string]string{
+				"tfstate":                      "true",
+				"app.kubernetes.io/managed-by": "terraform",
+			}
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupLockSecretName,
+					Namespace: StateRescueNamespace,
+					Labels:    tfLabels,
+				},
+				Data: map[string][]byte{"tfstate": []byte("state-1")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			backupLookupKey := types.NamespacedName{Name: "backup-" + backupLockSecretName, Namespace: StateRescueNamespace}
+			getBackup := func(g Gomega, data string) *corev1.Secret {
+				backupSecret := &corev1.Secret{}
+				g.Expect(k8sClient.Get(ctx, backupLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(HaveKeyWithValue("tfstate", []byte(data)))
+				return backupSecret
+			}
+			Eventually(func(g Gomega) { getBackup(g, "state-1") }, timeout, interval).Should(Succeed())
+
+			By("Acquiring the lock of the TF state and changing the state")
+			lock := LockInfo{
+				ID:        "5b0e7c1a-2f4d-4e8b-a1c3-9d7f6e5b4a32",
+				Operation: "OperationTypeApply",
+				Who:       "ci@runner-2",
+				Version:   "1.9.5",
+				Created:   time.Now().UTC(),
+			}
+			lockJSON, err := json.Marshal(lock)
+			Expect(err).NotTo(HaveOccurred())
+			lease := &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "lock-" + backupLockSecretName,
+					Namespace:   StateRescueNamespace,
+					Labels:      tfLabels,
+					Annotations: map[string]string{LockInfoAnnotationKey: string(lockJSON)},
+				},
+				Spec: coordinationv1.LeaseSpec{HolderIdentity: &lock.ID},
+			}
+			Expect(k8sClient.Create(ctx, lease)).To(Succeed())
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-2")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			Eventually(func(g Gomega) { getBackup(g, "state-2") }, timeout, interval).Should(Succeed())
+
+			By("Releasing the lock")
+			delete(lease.Annotations, LockInfoAnnotationKey)
+			lease.Spec.HolderIdentity = nil
+			Expect(k8sClient.Update(ctx, lease)).To(Succeed())
+
+			By("Checking that the backup is attributed to the run")
+			Eventually(func(g Gomega) {
+				backupSecret := getBackup(g, "state-2")
+				g.Expect(backupSecret.Annotations).To(HaveKey(RunAnnotationKey))
+				attributed := LockInfo{}
+				g.Expect(json.Unmarshal([]byte(backupSecret.Annotations[RunAnnotationKey]), &attributed)).To(Succeed())
+				g.Expect(attributed.ID).To(Equal(lock.ID))
+				g.Expect(attributed.Who).To(Equal("ci@runner-2"))
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the attribution is removed once the state changes without a run")
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-3")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(getBackup(g, "state-3").Annotations).NotTo(HaveKey(RunAnnotationKey))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource, the lock lease and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, lease)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When a tagged snapshot is requested on a StateRescue resource", func() {
+		It("Should take a tagged snapshot once and keep it beyond the kept generations", func() {
+			const (
+				taggedStateRescueName = "test-staterescue-tagged"
+				taggedSecretName      = "test-secret-tagged"
+			)
+			ctx := context.Background()
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      taggedStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: taggedSecretName,
+					Generations:     1,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      taggedSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state-1")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+			tagged := snapshotName(taggedSecretName, testSecret.Data)
+			snapshotLookupKey := types.NamespacedName{Name: tagged, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, snapshotLookupKey, &corev1.Secret{})).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Requesting a tagged snapshot")
+			stateRescueLookupKey := types.NamespacedName{Name: taggedStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				metav1.SetMetaDataAnnotation(&stateRescue.ObjectMeta, SnapshotNowAnnotationKey, "pre-upgrade")
+				g.Expect(k8sClient.Update(ctx, stateRescue)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the snapshot is tagged and the request is recorded")
+			var handled metav1.Time
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				request := stateRescue.Status.LastSnapshotRequest
+				g.Expect(request).NotTo(BeNil())
+				g.Expect(request.Tag).To(Equal("pre-upgrade"))
+				g.Expect(request.Snapshots).To(ConsistOf(tagged))
+				g.Expect(stateRescue.Annotations).NotTo(HaveKey(SnapshotNowAnnotationKey))
+				handled = request.Time
+				snapshot := &corev1.Secret{}
+				g.Expect(k8sClient.Get(ctx, snapshotLookupKey, snapshot)).To(Succeed())
+				g.Expect(snapshot.Labels).To(HaveKeyWithValue(terraformv1.SnapshotTagLabelPrefix+"pre-upgrade", "true"))
+			}, timeout, interval).Should(Succeed())
+
+			By("Updating the TF state twice")
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-2")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			second := types.NamespacedName{Name: snapshotName(taggedSecretName, testSecret.Data), Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, second, &corev1.Secret{})).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			testSecret.Data = map[string][]byte{"tfstate": []byte("state-3")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the tagged snapshot is kept and the request is not handled again")
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, second, &corev1.Secret{}))
+			}, timeout, interval).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, snapshotLookupKey, &corev1.Secret{})).To(Succeed())
+			Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+			Expect(stateRescue.Status.LastSnapshotRequest.Time).To(Equal(handled))
+			Expect(stateRescue.Status.LastSnapshotRequest.Snapshots).To(ConsistOf(tagged))
+
+			By("Requesting a snapshot with the same tag again")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				metav1.SetMetaDataAnnotation(&stateRescue.ObjectMeta, SnapshotNowAnnotationKey, "pre-upgrade")
+				g.Expect(k8sClient.Update(ctx, stateRescue)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			retagged := snapshotName(taggedSecretName, testSecret.Data)
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Status.LastSnapshotRequest.Snapshots).To(ConsistOf(retagged))
+				g.Expect(stateRescue.Annotations).NotTo(HaveKey(SnapshotNowAnnotationKey))
+				snapshot := &corev1.Secret{}
+				g.Expect(k8sClient.Get(ctx, types.NamespacedName{Name: retagged, Namespace: StateRescueNamespace}, snapshot)).To(Succeed())
+				g.Expect(snapshot.Labels).To(HaveKeyWithValue(terraformv1.SnapshotTagLabelPrefix+"pre-upgrade", "true"))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When snapshots of a StateRescue resource are held", func() {
+		It("Should keep the held snapshots in every destination and retain them when the StateRescue resource is deleted", func() {
+			const (
+				holdStateRescueName  = "test-staterescue-hold"
+				holdSecretName       = "test-secret-hold"
+				kubeconfigSecretName = "hold-remote-kubeconfig"
+			)
+			ctx := context.Background()
+
+			kubeconfigSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      kubeconfigSecretName,
+					Namespace: StateRescueNamespace,
+				},
+				Data: map[string][]byte{"kubeconfig": remoteKubeconfig},
+			}
+			Expect(k8sClient.Create(ctx, kubeconfigSecret)).To(Succeed())
+
+			heldSerial := int64(2)
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      holdStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: holdSecretName,
+					Generations:     1,
+					Holds:           []terraformv1.SnapshotHold{{Name: "audit-2025", Serial: &heldSerial}},
+					Destination: &terraformv1.BackupDestination{
+						RemoteCluster: &terraformv1.RemoteClusterDestination{
+							KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: kubeconfigSecretName},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			encodeState := func(serial int) map[string][]byte {
+				data, err := state.Encode([]byte(fmt.Sprintf(`{"version": 4, "serial": %d, "lineage": "l"}`, serial)))
+				Expect(err).NotTo(HaveOccurred())
+				return map[string][]byte{"tfstate": data}
+			}
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      holdSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: encodeState(1),
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			snapshotKey := func(serial int) types.NamespacedName {
+				return types.NamespacedName{Name: snapshotName(holdSecretName, encodeState(serial)), Namespace: StateRescueNamespace}
+			}
+			getSnapshot := func(g Gomega, serial int) *corev1.Secret {
+				snapshot := &corev1.Secret{}
+				g.Expect(k8sClient.Get(ctx, snapshotKey(serial), snapshot)).To(Succeed())
+				return snapshot
+			}
+
+			By("Holding the snapshot of the first state with the hold annotation")
+			Eventually(func(g Gomega) {
+				snapshot := getSnapshot(g, 1)
+				metav1.SetMetaDataAnnotation(&snapshot.ObjectMeta, HoldAnnotationKey, "case 4711")
+				g.Expect(k8sClient.Update(ctx, snapshot)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Updating the TF state three times")
+			for serial := 2; serial <= 4; serial++ {
+				testSecret.Data = encodeState(serial)
+				Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+				Eventually(func(g Gomega) { getSnapshot(g, serial) }, timeout, interval).Should(Succeed())
+			}
+
+			By("Checking that only the held snapshots are kept beyond the kept generations")
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, snapshotKey(3), &corev1.Secret{}))
+			}, timeout, interval).Should(BeTrue())
+			Expect(getSnapshot(Default, 1).Labels).To(HaveKeyWithValue(SnapshotHeldLabelKey, "true"))
+			Expect(getSnapshot(Default, 2).Labels).To(HaveKeyWithValue(SnapshotHeldLabelKey, "true"))
+			Expect(getSnapshot(Default, 4).Labels).NotTo(HaveKey(SnapshotHeldLabelKey))
+
+			By("Checking the held snapshots in the status")
+			stateRescueLookupKey := types.NamespacedName{Name: holdStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Status.HeldSnapshots).To(ConsistOf(
+					terraformv1.HeldSnapshot{Snapshot: snapshotKey(1).Name, Secret: holdSecretName, Holds: []string{"annotation"}},
+					terraformv1.HeldSnapshot{Snapshot: snapshotKey(2).Name, Secret: holdSecretName, Holds: []string{"audit-2025"}},
+				))
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the held snapshots are copied to the remote cluster")
+			for _, serial := range []int{1, 2} {
+				Eventually(func(g Gomega) {
+					copied := &corev1.Secret{}
+					g.Expect(remoteClient.Get(ctx, snapshotKey(serial), copied)).To(Succeed())
+					g.Expect(copied.Data).To(Equal(encodeState(serial)))
+					g.Expect(copied.Labels).To(HaveKeyWithValue(SnapshotHeldLabelKey, "true"))
+					g.Expect(copied.Labels).NotTo(HaveKey(ReplicaLabelKey))
+				}, timeout, interval).Should(Succeed())
+			}
+
+			By("Checking that the copies of the held snapshots are verified")
+			Eventually(func(g Gomega) {
+				copied := &corev1.Secret{}
+				g.Expect(remoteClient.Get(ctx, snapshotKey(1), copied)).To(Succeed())
+				copied.Annotations[PayloadChecksumAnnotationKey] = "tampered"
+				g.Expect(remoteClient.Update(ctx, copied)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				condition := meta.FindStatusCondition(stateRescue.Status.Conditions, terraformv1.ConditionBackupVerified)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+				g.Expect(condition.Message).To(ContainSubstring("held copy " + snapshotKey(1).Name))
+			}, timeout, interval).Should(Succeed())
+
+			By("Deleting the StateRescue resource with the deletion policy Delete")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			// the StateRescue resource is only removed once the deletion policy has been enforced
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, stateRescueLookupKey, &terraformv1.StateRescue{}))
+			}, timeout, interval).Should(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, snapshotKey(4), &corev1.Secret{}))).To(BeTrue())
+			for _, serial := range []int{1, 2} {
+				snapshot := getSnapshot(Default, serial)
+				Expect(snapshot.OwnerReferences).To(BeEmpty())
+				Expect(snapshot.Labels).To(HaveKeyWithValue(OrphanedLabelKey, "true"))
+			}
+
+			By("Cleanup the held snapshots, the test secret and the kubeconfig secret")
+			for _, serial := range []int{1, 2} {
+				Expect(k8sClient.Delete(ctx, getSnapshot(Default, serial))).To(Succeed())
+				copied := &corev1.Secret{}
+				Expect(remoteClient.Get(ctx, snapshotKey(serial), copied)).To(Succeed())
+				Expect(remoteClient.Delete(ctx, copied)).To(Succeed())
+			}
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, kubeconfigSecret)).To(Succeed())
+		})
+	})
+	Context("When terraform re-initialises a TF state with a new lineage", func() {
+		It("Should hold the backup of the previous lineage and restore it if configured", func() {
+			const (
+				reinitStateRescueName = "test-staterescue-reinit"
+				reinitSecretName      = "test-secret-reinit"
+			)
+			ctx := context.Background()
+			encodeState := func(lineage string, serial int) []byte {
+				data, err := state.Encode([]byte(fmt.Sprintf(`{"version": 4, "serial": %d, "lineage": %q}`, serial, lineage)))
+				Expect(err).NotTo(HaveOccurred())
+				return data
+			}
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      reinitStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: reinitSecretName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      reinitSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": encodeState("previous", 7)},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+			previousData := testSecret.Data
+
+			backupSecret := &corev1.Secret{}
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + reinitSecretName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Replacing the TF state with a fresh state of a new lineage")
+			testSecret.Data = map[string][]byte{"tfstate": encodeState("fresh", 1)}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the backup is held and the StateReinitialized condition is set")
+			stateRescueLookupKey := types.NamespacedName{Name: reinitStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionTrue(stateRescue.Status.Conditions, terraformv1.ConditionStateReinitialized)).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+			Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+			Expect(backupSecret.Data).To(Equal(previousData))
+
+			By("Checking that the fresh state is kept in a pinned snapshot")
+			freshSnapshot := &corev1.Secret{}
+			freshSnapshotKey := types.NamespacedName{Name: snapshotName(reinitSecretName, testSecret.Data), Namespace: StateRescueNamespace}
+			Expect(k8sClient.Get(ctx, freshSnapshotKey, freshSnapshot)).To(Succeed())
+			Expect(freshSnapshot.Labels).To(HaveKeyWithValue(SnapshotPinnedLabelKey, "true"))
+			By("Checking that the previous lineage is kept in a pinned snapshot")
+			previousSnapshot := &corev1.Secret{}
+			previousSnapshotKey := types.NamespacedName{Name: snapshotName(reinitSecretName, previousData), Namespace: StateRescueNamespace}
+			Expect(k8sClient.Get(ctx, previousSnapshotKey, previousSnapshot)).To(Succeed())
+			Expect(previousSnapshot.Labels).To(HaveKeyWithValue(SnapshotPinnedLabelKey, "true"))
+
+			By("Advancing the new lineage past the serial of the previous lineage")
+			grownState, err := state.Encode([]byte(`{"version": 4, "serial": 9, "lineage": "fresh", "resources": [` +
+				`{"mode": "managed", "type": "aws_instance", "name": "web", "provider": "aws", "instances": [{"attributes": {}}]}]}`))
+			Expect(err).NotTo(HaveOccurred())
+			grown := map[string][]byte{"tfstate": grownState}
+			testSecret.Data = grown
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			Consistently(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(Equal(previousData))
+				g.Expect(backupSecret.Annotations).To(HaveKey(ReinitializedAnnotationKey))
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionTrue(stateRescue.Status.Conditions, terraformv1.ConditionStateReinitialized)).To(BeTrue())
+			}, time.Second*2, interval).Should(Succeed())
+
+			By("Acknowledging the re-initialisation")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				stateRescue.Annotations = map[string]string{AcknowledgeReinitializationAnnotationKey: reinitSecretName}
+				g.Expect(k8sClient.Update(ctx, stateRescue)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(Equal(grown))
+				g.Expect(backupSecret.Annotations).NotTo(HaveKey(ReinitializedAnnotationKey))
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Annotations).NotTo(HaveKey(AcknowledgeReinitializationAnnotationKey))
+				g.Expect(meta.IsStatusConditionFalse(stateRescue.Status.Conditions, terraformv1.ConditionStateReinitialized)).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+
+			By("Restoring the acknowledged lineage over another fresh state")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				stateRescue.Spec.ReinitializationPolicy = terraformv1.ReinitializationPolicyRestore
+				g.Expect(k8sClient.Update(ctx, stateRescue)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			testSecret.Data = map[string][]byte{"tfstate": encodeState("another", 1)}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(testSecret), testSecret)).To(Succeed())
+				g.Expect(testSecret.Data).To(Equal(grown))
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionFalse(stateRescue.Status.Conditions, terraformv1.ConditionStateReinitialized)).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that a migrated state of a new lineage is backed up")
+			migratedState, err := state.Encode([]byte(`{"version": 4, "serial": 10, "lineage": "migrated", "resources": [` +
+				`{"mode": "managed", "type": "aws_instance", "name": "web", "provider": "aws", "instances": [{"attributes": {}}]}]}`))
+			Expect(err).NotTo(HaveOccurred())
+			migrated := map[string][]byte{"tfstate": migratedState}
+			testSecret.Data = migrated
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(Equal(migrated))
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource, the pinned snapshot and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			// the snapshot may have been deleted with the StateRescue resource already
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, freshSnapshot))).To(Succeed())
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, previousSnapshot))).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When a TF state loses more resources than its StateRescue resource allows", func() {
+		It("Should pin the snapshot of the previous state and keep the backup until the drop is acknowledged", func() {
+			const (
+				protectedStateRescueName = "test-staterescue-protected"
+				protectedSecretName      = "test-secret-protected"
+			)
+			ctx := context.Background()
+			encodeState := func(names ...string) []byte {
+				resources := []string{}
+				for _, name := range names {
+					resources = append(resources, fmt.Sprintf(`{"mode": "managed", "type": "aws_instance", "name": %q, "provider": "aws", "instances": [{"attributes": {}}]}`, name))
+				}
+				data, err := state.Encode([]byte(`{"version": 4, "serial": 3, "lineage": "l", "resources": [` + strings.Join(resources, ",") + `]}`))
+				Expect(err).NotTo(HaveOccurred())
+				return data
+			}
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      protectedStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: protectedSecretName,
+					Protection: &terraformv1.ProtectionOptions{
+						MaxResourceDropPercent: 50,
+						RequireAcknowledgement: true,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      protectedSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": encodeState("web", "db", "cache", "queue")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+			previousData := testSecret.Data
+
+			backupSecret := &corev1.Secret{}
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + protectedSecretName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Dropping three of the four resources from the TF state")
+			testSecret.Data = map[string][]byte{"tfstate": encodeState("web")}
+			Expect(k8sClient.Update(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the state is quarantined and the snapshot of the previous state is pinned")
+			stateRescueLookupKey := types.NamespacedName{Name: protectedStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionTrue(stateRescue.Status.Conditions, terraformv1.ConditionStateQuarantined)).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+			snapshot := &corev1.Secret{}
+			snapshotLookupKey := types.NamespacedName{Name: snapshotName(protectedSecretName, previousData), Namespace: StateRescueNamespace}
+			Expect(k8sClient.Get(ctx, snapshotLookupKey, snapshot)).To(Succeed())
+			Expect(snapshot.Labels).To(HaveKeyWithValue(SnapshotPinnedLabelKey, "true"))
+			Expect(snapshot.Data).To(Equal(previousData))
+			Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+			Expect(backupSecret.Data).To(Equal(previousData))
+
+			By("Acknowledging the resource drop")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				stateRescue.Annotations = map[string]string{AcknowledgeResourceDropAnnotationKey: protectedSecretName}
+				g.Expect(k8sClient.Update(ctx, stateRescue)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, backupSecret)).To(Succeed())
+				g.Expect(backupSecret.Data).To(Equal(testSecret.Data))
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Annotations).NotTo(HaveKey(AcknowledgeResourceDropAnnotationKey))
+				g.Expect(meta.IsStatusConditionFalse(stateRescue.Status.Conditions, terraformv1.ConditionStateQuarantined)).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the test secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+		})
+	})
+	Context("When another system keeps deleting a TF state secret", func() {
+		It("Should stop rescuing the TF state secret and report the field managers of the deleted secret", func() {
+			const (
+				flappingStateRescueName = "test-staterescue-flapping"
+				flappingSecretName      = "test-secret-flapping"
+			)
+			ctx := context.Background()
+
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      flappingStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: flappingSecretName,
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      flappingSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			backupSecretLookupKey := types.NamespacedName{Name: "backup-" + flappingSecretName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, backupSecretLookupKey, &corev1.Secret{})).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+
+			By("Deleting the TF state secret as often as it may be rescued")
+			secretLookupKey := client.ObjectKeyFromObject(testSecret)
+			for range 3 {
+				secret := &corev1.Secret{}
+				Eventually(func(g Gomega) {
+					g.Expect(k8sClient.Get(ctx, secretLookupKey, secret)).To(Succeed())
+					g.Expect(secret.DeletionTimestamp).To(BeNil())
+				}, timeout, interval).Should(Succeed())
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+				Eventually(func(g Gomega) {
+					rescued := &corev1.Secret{}
+					g.Expect(k8sClient.Get(ctx, secretLookupKey, rescued)).To(Succeed())
+					g.Expect(rescued.UID).NotTo(Equal(secret.UID))
+				}, timeout, interval).Should(Succeed())
+			}
+
+			By("Labelling the rescued TF state secret by another system and deleting it once more")
+			rescued := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretLookupKey, rescued)).To(Succeed())
+			rescued.Labels["cleanup"] = "true"
+			Expect(k8sClient.Update(ctx, rescued, client.FieldOwner("cleanup-job"))).To(Succeed())
+			stateRescueLookupKey := types.NamespacedName{Name: flappingStateRescueName, Namespace: StateRescueNamespace}
+			// the secret audit webhook is not running, so the deletion is recorded like it would
+			Expect(retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				if err := k8sClient.Get(ctx, stateRescueLookupKey, stateRescue); err != nil {
+					return err
+				}
+				stateRescue.Status.SecretHistory = append(stateRescue.Status.SecretHistory, terraformv1.SecretChange{
+					Secret:    flappingSecretName,
+					Operation: operationDelete,
+					User:      "system:serviceaccount:ops:cleanup-job",
+					Time:      metav1.Now(),
+				})
+				return k8sClient.Status().Update(ctx, stateRescue)
+			})).To(Succeed())
+			Expect(k8sClient.Delete(ctx, rescued)).To(Succeed())
+
+			By("Checking that the circuit breaker is open and the secret is no longer rescued")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				condition := meta.FindStatusCondition(stateRescue.Status.Conditions, terraformv1.ConditionRescueFlapping)
+				g.Expect(condition).NotTo(BeNil())
+				g.Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+				g.Expect(condition.Message).To(ContainSubstring("rescued 3 times"))
+				g.Expect(condition.Message).To(ContainSubstring("last deleted by system:serviceaccount:ops:cleanup-job"))
+				g.Expect(condition.Message).To(ContainSubstring("last writers before it was deleted were cleanup-job"))
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, secretLookupKey, &corev1.Secret{}))
+			}, 2*time.Second, interval).Should(BeTrue())
+
+			By("Cleanup the StateRescue resource")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+		})
+		It("Should apply the circuit breaker to rescues from the remote cluster", func() {
+			ctx := context.Background()
+			const remoteFlappingSecretName = "test-secret-flapping-remote"
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-staterescue-flapping-remote", Namespace: StateRescueNamespace},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: remoteFlappingSecretName,
+					Destination: &terraformv1.BackupDestination{
+						RemoteCluster: &terraformv1.RemoteClusterDestination{
+							KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: "unused"},
+						},
+					},
+				},
+			}
+			original := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      remoteFlappingSecretName,
+					Namespace: StateRescueNamespace,
+					Labels:    map[string]string{"app.kubernetes.io/managed-by": "terraform", "tfstate": "true"},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state")},
+			}
+			replica := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backup-" + remoteFlappingSecretName,
+					Namespace: StateRescueNamespace,
+					Labels:    map[string]string{ReplicaLabelKey: "true", "tfstate": "false"},
+				},
+				Data: original.Data,
+			}
+			local := fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).Build()
+			remote := fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).WithObjects(replica).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &StateRescueReconciler{
+				Client:                  local,
+				Scheme:                  k8sClient.Scheme(),
+				Recorder:                recorder,
+				RescueFlappingThreshold: 2,
+				RescueFlappingWindow:    time.Minute,
+				rescueHistory:           newRescueHistory(),
+			}
+			key := types.NamespacedName{Name: remoteFlappingSecretName, Namespace: StateRescueNamespace}
+
+			By("Recording rescues from the remote cluster")
+			for range 2 {
+				breakers := &openBreakers{}
+				rescued, err := r.rescueFromRemote(ctx, stateRescue, remote, nil, &corev1.SecretList{}, &corev1.SecretList{}, breakers)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rescued).To(BeTrue())
+				Expect(breakers.messages).To(BeEmpty())
+				Expect(recorder.Events).To(Receive(ContainSubstring("from its replica in the remote cluster")))
+				Expect(local.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}})).To(Succeed())
+			}
+
+			By("Refusing to rescue from the remote cluster once the circuit breaker is open")
+			breakers := &openBreakers{}
+			rescued, err := r.rescueFromRemote(ctx, stateRescue, remote, nil, &corev1.SecretList{}, &corev1.SecretList{}, breakers)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rescued).To(BeFalse())
+			Expect(breakers.messages).To(ConsistOf(ContainSubstring("rescued 2 times")))
+			Expect(breakers.retryAfter).To(BeNumerically(">", 0))
+			Expect(errors.IsNotFound(local.Get(ctx, key, &corev1.Secret{}))).To(BeTrue())
+		})
+	})
+	Context("When a StateRescue resource replicates backups to a remote cluster", func() {
+		It("Should replicate the TF state secret and rescue it from the remote cluster when local backups are gone", func() {
+			const (
+				remoteStateRescueName = "test-staterescue-remote"
+				remoteSecretName      = "test-secret-remote"
+				kubeconfigSecretName  = "remote-kubeconfig"
+			)
+			ctx := context.Background()
+
+			By("Creating a Secret with the kubeconfig of the remote cluster")
+			kubeconfigSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      kubeconfigSecretName,
+					Namespace: StateRescueNamespace,
+				},
+				Data: map[string][]byte{"kubeconfig": remoteKubeconfig},
+			}
+			Expect(k8sClient.Create(ctx, kubeconfigSecret)).To(Succeed())
+
+			newStateRescue := func(name string) *terraformv1.StateRescue {
+				return &terraformv1.StateRescue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: StateRescueNamespace,
+					},
+					Spec: terraformv1.StateRescueSpec{
+						StateSecretName: remoteSecretName,
+						Destination: &terraformv1.BackupDestination{
+							RemoteCluster: &terraformv1.RemoteClusterDestination{
+								KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: kubeconfigSecretName},
+							},
+						},
+					},
+				}
+			}
+			stateRescue := newStateRescue(remoteStateRescueName)
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      remoteSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": []byte("state")},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the TF state secret was replicated to the remote cluster")
+			replicaLookupKey := types.NamespacedName{Name: "backup-" + remoteSecretName, Namespace: StateRescueNamespace}
+			replica := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(remoteClient.Get(ctx, replicaLookupKey, replica)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			Expect(replica.Data).To(Equal(testSecret.Data))
+			Expect(replica.Labels).To(HaveKeyWithValue(ReplicaLabelKey, "true"))
+			Expect(replica.Labels).NotTo(HaveKey("app.kubernetes.io/managed-by"))
+			Expect(replica.Annotations).To(HaveKeyWithValue(SourceNamespaceAnnotationKey, StateRescueNamespace))
+			Expect(replica.Annotations).To(HaveKey(StateRescueAnnotationKey))
+
+			By("Checking the replication status of the StateRescue resource")
+			stateRescueLookupKey := types.NamespacedName{Name: remoteStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, stateRescueLookupKey, stateRescue)).To(Succeed())
+				g.Expect(meta.IsStatusConditionTrue(stateRescue.Status.Conditions, terraformv1.ConditionReplicated)).To(BeTrue())
+				g.Expect(stateRescue.Status.RemoteReplication).NotTo(BeNil())
+				g.Expect(stateRescue.Status.RemoteReplication.LastReplicationTime.IsZero()).To(BeFalse())
+			}, timeout, interval).Should(Succeed())
+
+			By("Deleting the StateRescue resource along with its local backups and the TF state secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Eventually(func(g Gomega) {
+				g.Expect(errors.IsNotFound(k8sClient.Get(ctx, stateRescueLookupKey, &terraformv1.StateRescue{}))).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+
+			By("Creating a new StateRescue resource replicating to the same remote cluster")
+			stateRescue = newStateRescue(remoteStateRescueName + "-new")
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			By("Checking that the TF state secret was rescued from the remote cluster")
+			secretLookupKey := types.NamespacedName{Name: remoteSecretName, Namespace: StateRescueNamespace}
+			rescuedSecret := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, secretLookupKey, rescuedSecret)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			Expect(rescuedSecret.Data).To(Equal(replica.Data))
+			Expect(rescuedSecret.Labels).To(HaveKeyWithValue("app.kubernetes.io/managed-by", "terraform"))
+			Expect(rescuedSecret.Labels).To(HaveKeyWithValue("tfstate", "true"))
+			Expect(rescuedSecret.Labels).NotTo(HaveKey(ReplicaLabelKey))
+			Expect(rescuedSecret.Annotations).NotTo(HaveKey(StateRescueAnnotationKey))
+
+			By("Cleanup the StateRescue resource and the test secrets")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, rescuedSecret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, kubeconfigSecret)).To(Succeed())
+		})
+		It("Should replicate redacted TF states and never rescue from redacted replicas", func() {
+			const (
+				redactStateRescueName = "test-staterescue-redact"
+				redactSecretName      = "test-secret-redact"
+				kubeconfigSecretName  = "remote-kubeconfig-redact"
+			)
+			ctx := context.Background()
+
+			By("Creating a StateRescue resource replicating redacted states to the remote cluster")
+			kubeconfigSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      kubeconfigSecretName,
+					Namespace: StateRescueNamespace,
+				},
+				Data: map[string][]byte{"kubeconfig": remoteKubeconfig},
+			}
+			Expect(k8sClient.Create(ctx, kubeconfigSecret)).To(Succeed())
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      redactStateRescueName,
+					Namespace: StateRescueNamespace,
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: redactSecretName,
+					Destination: &terraformv1.BackupDestination{
+						RemoteCluster: &terraformv1.RemoteClusterDestination{
+							KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: kubeconfigSecretName},
+							Redact:              &terraformv1.RedactOptions{AttributePatterns: []string{"*password*"}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+
+			data, err := state.Encode([]byte(`{"version": 4, "serial": 1, "lineage": "l", "resources": [{"mode": "managed",
+				"type": "aws_db_instance", "name": "db", "provider": "aws",
+				"instances": [{"attributes": {"id": "db-1", "master_password": "hunter2"}}]}]}`))
+			Expect(err).NotTo(HaveOccurred())
+			testSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      redactSecretName,
+					Namespace: StateRescueNamespace,
+					Labels: map[string]string{
+						"tfstate":                      "true",
+						"app.kubernetes.io/managed-by": "terraform",
+					},
+				},
+				Data: map[string][]byte{"tfstate": data},
+			}
+			Expect(k8sClient.Create(ctx, testSecret)).To(Succeed())
+
+			By("Checking that the replica holds the redacted TF state")
+			replicaLookupKey := types.NamespacedName{Name: "backup-" + redactSecretName, Namespace: StateRescueNamespace}
+			replica := &corev1.Secret{}
+			Eventually(func(g Gomega) {
+				g.Expect(remoteClient.Get(ctx, replicaLookupKey, replica)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			Expect(replica.Annotations).To(HaveKeyWithValue(RedactedAnnotationKey, "true"))
+			replicatedState, err := state.FromSecret(replica)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicatedState.Redacted).To(BeTrue())
+			Expect(string(replicatedState.Raw)).To(ContainSubstring(`"db-1"`))
+			Expect(string(replicatedState.Raw)).NotTo(ContainSubstring("hunter2"))
+
+			By("Deleting the TF state secret and its local backup")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			stateRescueLookupKey := types.NamespacedName{Name: redactStateRescueName, Namespace: StateRescueNamespace}
+			Eventually(func(g Gomega) {
+				g.Expect(errors.IsNotFound(k8sClient.Get(ctx, stateRescueLookupKey, &terraformv1.StateRescue{}))).To(BeTrue())
+			}, timeout, interval).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, testSecret)).To(Succeed())
+
+			By("Removing the unsigned redacted annotation from the replica")
+			Expect(remoteClient.Get(ctx, replicaLookupKey, replica)).To(Succeed())
+			delete(replica.Annotations, RedactedAnnotationKey)
+			Expect(remoteClient.Update(ctx, replica)).To(Succeed())
+
+			By("Checking that the TF state secret is not rescued from the redacted replica")
+			stateRescue.ObjectMeta = metav1.ObjectMeta{Name: redactStateRescueName + "-new", Namespace: StateRescueNamespace}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+			secretLookupKey := types.NamespacedName{Name: redactSecretName, Namespace: StateRescueNamespace}
+			Consistently(func(g Gomega) {
+				g.Expect(errors.IsNotFound(k8sClient.Get(ctx, secretLookupKey, &corev1.Secret{}))).To(BeTrue())
+			}, time.Second*2, interval).Should(Succeed())
+
+			By("Cleanup the StateRescue resource and the kubeconfig secret")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, kubeconfigSecret)).To(Succeed())
+		})
+		It("Should reuse the client for the remote cluster until the kubeconfig secret changes", func() {
+			const kubeconfigSecretName = "remote-kubeconfig-cached"
+			ctx := context.Background()
+
+			kubeconfigSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      kubeconfigSecretName,
+					Namespace: StateRescueNamespace,
+				},
+				Data: map[string][]byte{"kubeconfig": remoteKubeconfig},
+			}
+			Expect(k8sClient.Create(ctx, kubeconfigSecret)).To(Succeed())
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-staterescue-cached", Namespace: StateRescueNamespace},
+				Spec: terraformv1.StateRescueSpec{
+					Destination: &terraformv1.BackupDestination{
+						RemoteCluster: &terraformv1.RemoteClusterDestination{
+							KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: kubeconfigSecretName},
+						},
+					},
+				},
+			}
+			r := &StateRescueReconciler{
+				Scheme:        k8sClient.Scheme(),
+				APIReader:     k8sClient,
+				remoteClients: newRemoteClientCache(),
+			}
+
+			By("Reusing the client while the kubeconfig secret is unchanged")
+			first, err := r.remoteClient(ctx, stateRescue)
+			Expect(err).NotTo(HaveOccurred())
+			second, err := r.remoteClient(ctx, stateRescue)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(BeIdenticalTo(first))
+
+			By("Creating a new client once the kubeconfig secret has changed")
+			kubeconfigSecret.Data["other"] = []byte("value")
+			Expect(k8sClient.Update(ctx, kubeconfigSecret)).To(Succeed())
+			third, err := r.remoteClient(ctx, stateRescue)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(third).NotTo(BeIdenticalTo(first))
+
+			By("Cleanup the kubeconfig secret")
+			Expect(k8sClient.Delete(ctx, kubeconfigSecret)).To(Succeed())
+		})
+	})
 })
@@ -21,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -28,6 +29,7 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -46,6 +48,11 @@ var (
 	testEnv   *envtest.Environment
 	cfg       *rest.Config
 	k8sClient client.Client
+
+	// remote cluster that backups are replicated to
+	remoteTestEnv    *envtest.Environment
+	remoteClient     client.Client
+	remoteKubeconfig []byte
 )
 
 func TestControllers(t *testing.T) {
@@ -85,15 +92,39 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	By("bootstrapping remote test environment")
+	remoteTestEnv = &envtest.Environment{}
+	if getFirstFoundEnvTestBinaryDir() != "" {
+		remoteTestEnv.BinaryAssetsDirectory = getFirstFoundEnvTestBinaryDir()
+	}
+	remoteCfg, err := remoteTestEnv.Start()
+	Expect(err).NotTo(HaveOccurred())
+	remoteClient, err = client.New(remoteCfg, client.Options{Scheme: scheme.Scheme})
+	Expect(err).NotTo(HaveOccurred())
+	remoteUser, err := remoteTestEnv.AddUser(envtest.User{Name: "tf-state-rescuer", Groups: []string{"system:masters"}}, nil)
+	Expect(err).NotTo(HaveOccurred())
+	remoteKubeconfig, err = remoteUser.KubeConfig()
+	Expect(err).NotTo(HaveOccurred())
+
 	// Start the controller
 	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme: scheme.Scheme,
+		Cache: cache.Options{
+			ByObject: SecretCacheOptions(),
+		},
 	})
 	Expect(err).NotTo(HaveOccurred())
 
 	err = (&StateRescueReconciler{
-		Client: k8sManager.GetClient(),
-		Scheme: k8sManager.GetScheme(),
+		Client:    k8sManager.GetClient(),
+		Scheme:    k8sManager.GetScheme(),
+		Recorder:  k8sManager.GetEventRecorderFor("staterescue-controller"),
+		APIReader: k8sManager.GetAPIReader(),
+		// verify backups frequently so that verification failures are detected in tests
+		VerifyInterval: 2 * time.Second,
+		// stop rescuing state secrets that are deleted three times within a minute
+		RescueFlappingThreshold: 3,
+		RescueFlappingWindow:    time.Minute,
 	}).SetupWithManager(k8sManager)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -109,6 +140,8 @@ var _ = AfterSuite(func() {
 	cancel()
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
+	err = remoteTestEnv.Stop()
+	Expect(err).NotTo(HaveOccurred())
 })
 
 // getFirstFoundEnvTestBinaryDir locates the first binary in the specified path.
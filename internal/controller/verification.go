@@ -0,0 +1,245 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+const (
+	// PayloadChecksumAnnotationKey holds the SHA-256 of the data of a backup secret or replica
+	PayloadChecksumAnnotationKey = "terraform.hammadzf.github.io/payload-sha256"
+	// StateChecksumAnnotationKey holds the SHA-256 of the decoded terraform state of a backup secret or replica
+	StateChecksumAnnotationKey = "terraform.hammadzf.github.io/state-sha256"
+)
+
+// withoutSnapshotAnnotations returns a copy of the annotations of a backup secret or replica without the
+// annotations that only describe the snapshot, for the original secret that is rescued from it
+func withoutSnapshotAnnotations(annotations map[string]string) map[string]string {
+	annotations = maps.Clone(annotations)
+	delete(annotations, PayloadChecksumAnnotationKey)
+	delete(annotations, StateChecksumAnnotationKey)
+	delete(annotations, DiffSummaryAnnotationKey)
+	delete(annotations, SignatureAnnotationKey)
+	return annotations
+}
+
+// payloadChecksum returns the SHA-256 of the data of a secret over its sorted keys and values
+func payloadChecksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%d:%s%d:", len(key), key, len(data[key]))
+		hash.Write(data[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// stateChecksum returns the SHA-256 of the decoded terraform state in the data of a secret
+func stateChecksum(data map[string][]byte) (string, error) {
+	s, err := state.FromSecret(&corev1.Secret{Data: data})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(s.Raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// setChecksums records the checksums of the given data in the annotations, the state checksum
+// is only recorded if the data contains a terraform state that can be decoded
+func setChecksums(annotations map[string]string, data map[string][]byte) {
+	annotations[PayloadChecksumAnnotationKey] = payloadChecksum(data)
+	if checksum, err := stateChecksum(data); err == nil {
+		annotations[StateChecksumAnnotationKey] = checksum
+	} else {
+		delete(annotations, StateChecksumAnnotationKey)
+	}
+}
+
+// hasChecksums reports whether the checksums of a snapshot are recorded. Recorded checksums are never
+// overwritten for unchanged data, so that a mismatch is reported by the verification instead of hidden.
+func hasChecksums(secret *corev1.Secret) bool {
+	_, ok := secret.Annotations[PayloadChecksumAnnotationKey]
+	return ok
+}
+
+// verifySnapshot decodes a snapshot and checks it against its recorded checksums,
+// the snapshot is referred to by the given label in errors
+func verifySnapshot(label string, secret *corev1.Secret) error {
+	payload, ok := secret.Annotations[PayloadChecksumAnnotationKey]
+	if !ok {
+		return fmt.Errorf("%s has no checksum", label)
+	}
+	if payload != payloadChecksum(secret.Data) {
+		return fmt.Errorf("%s does not match its payload checksum", label)
+	}
+	if expected, ok := secret.Annotations[StateChecksumAnnotationKey]; ok {
+		checksum, err := stateChecksum(secret.Data)
+		if err != nil {
+			return fmt.Errorf("%s cannot be decoded: %w", label, err)
+		}
+		if checksum != expected {
+			return fmt.Errorf("%s does not match its state checksum", label)
+		}
+	}
+	return nil
+}
+
+// withVerification verifies the backups of the StateRescue resource if due after the backup and rescue logic
+// returned the given result and error, so that verification keeps its own schedule even while backing up or
+// rescuing fails. The error of the backup and rescue logic takes precedence, otherwise the StateRescue resource
+// is reconciled again when requested by either of them, whichever is earlier.
+func (r *StateRescueReconciler) withVerification(ctx context.Context, stateRescue *terraformv1.StateRescue, result ctrl.Result, err error) (ctrl.Result, error) {
+	verifyResult, verifyErr := r.verifyBackups(ctx, stateRescue)
+	if err != nil {
+		return result, err
+	}
+	if verifyErr != nil {
+		return verifyResult, verifyErr
+	}
+	if verifyResult.RequeueAfter > 0 && (result.RequeueAfter == 0 || verifyResult.RequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = verifyResult.RequeueAfter
+	}
+	return result, nil
+}
+
+// verifyBackups periodically re-reads the backup secrets, snapshots and replicas of the StateRescue resource from the API
+// server and the remote cluster, verifies them against their checksums and reports the result in the
+// BackupVerified condition and metric. If signing is enabled, the signatures are verified as well and reported
+// in the BackupTampered condition. It returns when the next verification is due.
+func (r *StateRescueReconciler) verifyBackups(ctx context.Context, stateRescue *terraformv1.StateRescue) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if r.VerifyInterval <= 0 {
+		return ctrl.Result{}, nil
+	}
+	if last := stateRescue.Status.LastVerificationTime; !last.IsZero() {
+		if wait := time.Until(last.Add(r.VerifyInterval)); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	var failures, tampered []string
+	verified := 0
+	signer, err := r.signerFor(ctx, stateRescue)
+	if err != nil {
+		failures = append(failures, fmt.Sprintf("signatures cannot be verified: %v", err))
+	}
+	// re-read the local backups from the API server instead of the cache
+	secrets := &corev1.SecretList{}
+	if err := r.APIReader.List(ctx, secrets, client.InNamespace(stateRescue.Namespace), client.MatchingLabels{TfStateLabelKey: TfStateLabelValue}); err != nil {
+		log.Error(err, "unable to fetch backup secrets for verification")
+		return ctrl.Result{}, err
+	}
+	// snapshots by the label they are referred to in the condition
+	snapshots := map[string]*corev1.Secret{}
+	for i, item := range secrets.Items {
+		if strings.HasPrefix(item.Name, "backup-"+stateRescue.Spec.StateSecretName) ||
+			strings.HasPrefix(item.Name, "snapshot-"+stateRescue.Spec.StateSecretName) {
+			snapshots[item.Name] = &secrets.Items[i]
+		}
+	}
+	// as well as the replicas and the copies of held snapshots from the remote cluster, the copies do not carry
+	// the replica label and are listed separately
+	if stateRescue.Spec.Destination != nil && stateRescue.Spec.Destination.RemoteCluster != nil {
+		replicas, held := &corev1.SecretList{}, &corev1.SecretList{}
+		remoteClient, err := r.remoteClient(ctx, stateRescue)
+		if err == nil {
+			err = remoteClient.List(ctx, replicas, client.InNamespace(remoteNamespace(stateRescue)), client.MatchingLabels{ReplicaLabelKey: "true"})
+		}
+		if err == nil {
+			err = remoteClient.List(ctx, held, client.InNamespace(remoteNamespace(stateRescue)), client.MatchingLabels{SnapshotHeldLabelKey: "true"})
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("replicas cannot be read: %v", err))
+		}
+		for i, item := range replicas.Items {
+			if strings.HasPrefix(item.Name, "backup-"+stateRescue.Spec.StateSecretName) {
+				snapshots["replica "+item.Name] = &replicas.Items[i]
+			}
+		}
+		for i, item := range held.Items {
+			if strings.HasPrefix(item.Name, "snapshot-"+stateRescue.Spec.StateSecretName) {
+				snapshots["held copy "+item.Name] = &held.Items[i]
+			}
+		}
+	}
+	for _, label := range slices.Sorted(maps.Keys(snapshots)) {
+		snapshot := snapshots[label]
+		if err := verifySnapshot(label, snapshot); err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if err := signer.verify(snapshot.Name, snapshot); err != nil {
+			failures = append(failures, err.Error())
+			tampered = append(tampered, err.Error())
+			continue
+		}
+		verified++
+	}
+
+	condition := metav1.Condition{
+		Type:               terraformv1.ConditionBackupVerified,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Verified",
+		Message:            fmt.Sprintf("%d backups verified", verified),
+		ObservedGeneration: stateRescue.Generation,
+	}
+	backupVerified.WithLabelValues(stateRescue.Namespace, stateRescue.Name).Set(1)
+	if len(failures) > 0 {
+		log.Info("backup verification failed", "failures", failures)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "VerificationFailed"
+		condition.Message = strings.Join(failures, "; ")
+		backupVerified.WithLabelValues(stateRescue.Namespace, stateRescue.Name).Set(0)
+	}
+	meta.SetStatusCondition(&stateRescue.Status.Conditions, condition)
+	if signer != nil {
+		if len(tampered) > 0 && r.planned == nil {
+			r.Recorder.Eventf(stateRescue, corev1.EventTypeWarning, "BackupTampered", "Backups do not match their signatures: %s", strings.Join(tampered, "; "))
+		}
+		setTampered(stateRescue, tampered)
+	}
+	stateRescue.Status.LastVerificationTime = metav1.Now()
+	if err := r.Status().Update(ctx, stateRescue); err != nil {
+		log.Error(err, "unable to update state rescue resource")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.VerifyInterval}, nil
+}
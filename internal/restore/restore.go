@@ -0,0 +1,252 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package restore seeds terraform state secrets back into an empty cluster from the replicas
+// that the controller wrote to a remote cluster, e.g. after the cluster was rebuilt from scratch.
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/controller"
+)
+
+// Result describes the outcome of restoring a single object
+type Result string
+
+const (
+	// ResultRestored means that the object was recreated
+	ResultRestored Result = "Restored"
+	// ResultSkipped means that the object already exists and was left untouched
+	ResultSkipped Result = "Skipped"
+	// ResultFailed means that the object could not be restored
+	ResultFailed Result = "Failed"
+)
+
+// Options configures a restore run
+type Options struct {
+	// namespace in the remote cluster that replicas are read from, all namespaces if empty
+	SourceNamespace string
+	// recreates the StateRescue resources recorded on the replicas in addition to the state secrets
+	RestoreStateRescues bool
+	// only reports what would be restored without creating any objects
+	DryRun bool
+	// verifies the signatures of the replicas, replicas written with signing are only restored if it is set
+	Signer *controller.ReplicaSigner
+}
+
+// Item is the outcome of restoring a single object
+type Item struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Result    Result
+	Message   string
+}
+
+// Summary summarizes a restore run
+type Summary struct {
+	Items []Item
+}
+
+// Failed returns the number of objects that could not be restored
+func (s *Summary) Failed() int {
+	failed := 0
+	for _, item := range s.Items {
+		if item.Result == ResultFailed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Write prints the report as a table followed by a summary line
+func (s *Summary) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tNAMESPACE\tNAME\tRESULT\tMESSAGE")
+	counts := map[Result]int{}
+	for _, item := range s.Items {
+		counts[item.Result]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", item.Kind, item.Namespace, item.Name, item.Result, item.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d restored, %d skipped, %d failed\n",
+		counts[ResultRestored], counts[ResultSkipped], counts[ResultFailed])
+	return err
+}
+
+// RestoreAll recreates the terraform state secrets, and optionally the StateRescue resources,
+// in the local cluster from the replicas in the remote cluster. Objects that already exist are skipped,
+// so that a restore can safely be repeated. Replicas that do not match their signature are never restored.
+func RestoreAll(ctx context.Context, local, remote client.Client, opts Options) (*Summary, error) {
+	replicas := &corev1.SecretList{}
+	listOpts := []client.ListOption{client.MatchingLabels{controller.ReplicaLabelKey: "true"}}
+	if opts.SourceNamespace != "" {
+		listOpts = append(listOpts, client.InNamespace(opts.SourceNamespace))
+	}
+	if err := remote.List(ctx, replicas, listOpts...); err != nil {
+		return nil, fmt.Errorf("failed to list replicas in remote cluster: %w", err)
+	}
+	// restore in a stable order so that the report is easy to read
+	sort.Slice(replicas.Items, func(i, j int) bool {
+		return targetNamespace(&replicas.Items[i])+"/"+replicas.Items[i].Name <
+			targetNamespace(&replicas.Items[j])+"/"+replicas.Items[j].Name
+	})
+
+	summary := &Summary{}
+	namespaces := map[string]error{}
+	stateRescues := map[types.NamespacedName]bool{}
+	for i := range replicas.Items {
+		replica := &replicas.Items[i]
+		namespace := targetNamespace(replica)
+		if _, ok := namespaces[namespace]; !ok {
+			namespaces[namespace] = ensureNamespace(ctx, local, namespace, opts.DryRun)
+		}
+		if err := namespaces[namespace]; err != nil {
+			summary.Items = append(summary.Items, Item{Kind: "Secret", Namespace: namespace,
+				Name: controller.SecretFromReplica(replica, namespace).Name, Result: ResultFailed, Message: err.Error()})
+			continue
+		}
+
+		var stateRescue *terraformv1.StateRescue
+		var invalid error
+		if recorded := replica.Annotations[controller.StateRescueAnnotationKey]; recorded != "" {
+			stateRescue = &terraformv1.StateRescue{}
+			invalid = json.Unmarshal([]byte(recorded), stateRescue)
+		}
+
+		secret := controller.SecretFromReplica(replica, namespace)
+		// the recorded StateRescue resource is not signed, so every replica is verified if a key is given
+		signed := invalid == nil && stateRescue != nil && stateRescue.Spec.Signing != nil
+		switch {
+		case opts.Signer != nil:
+			if err := opts.Signer.Verify(replica); err != nil {
+				summary.Items = append(summary.Items, Item{Kind: "Secret", Namespace: namespace, Name: secret.Name,
+					Result: ResultFailed, Message: fmt.Sprintf("refusing to restore: %v", err)})
+				continue
+			}
+		case signed:
+			summary.Items = append(summary.Items, Item{Kind: "Secret", Namespace: namespace, Name: secret.Name,
+				Result: ResultFailed, Message: "replica is signed, but no signing key was given to verify it"})
+			continue
+		}
+		if controller.Redacted(replica) {
+			summary.Items = append(summary.Items, Item{Kind: "Secret", Namespace: namespace, Name: secret.Name,
+				Result: ResultSkipped, Message: "replica is redacted and cannot be restored"})
+		} else {
+			summary.Items = append(summary.Items, restoreSecret(ctx, local, secret, opts.DryRun))
+		}
+
+		if !opts.RestoreStateRescues || stateRescue == nil {
+			continue
+		}
+		if invalid != nil {
+			summary.Items = append(summary.Items, Item{Kind: "StateRescue", Namespace: namespace,
+				Name: replica.Name, Result: ResultFailed, Message: fmt.Sprintf("invalid metadata: %v", invalid)})
+			continue
+		}
+		key := types.NamespacedName{Name: stateRescue.Name, Namespace: namespace}
+		// several replicas are written by the same StateRescue resource
+		if stateRescues[key] {
+			continue
+		}
+		stateRescues[key] = true
+		summary.Items = append(summary.Items, restoreStateRescue(ctx, local, key, stateRescue, opts.DryRun))
+	}
+	return summary, nil
+}
+
+// targetNamespace returns the namespace of the original secret of a replica
+func targetNamespace(replica *corev1.Secret) string {
+	if namespace := replica.Annotations[controller.SourceNamespaceAnnotationKey]; namespace != "" {
+		return namespace
+	}
+	return replica.Namespace
+}
+
+// ensureNamespace creates the namespace if it does not exist yet
+func ensureNamespace(ctx context.Context, c client.Client, name string, dryRun bool) error {
+	err := c.Get(ctx, types.NamespacedName{Name: name}, &corev1.Namespace{})
+	if !errors.IsNotFound(err) || dryRun {
+		return client.IgnoreNotFound(err)
+	}
+	return c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+}
+
+// restoreSecret creates a terraform state secret unless it already exists
+func restoreSecret(ctx context.Context, c client.Client, secret *corev1.Secret, dryRun bool) Item {
+	item := Item{Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name}
+	err := c.Get(ctx, client.ObjectKeyFromObject(secret), &corev1.Secret{})
+	switch {
+	case err == nil:
+		item.Result, item.Message = ResultSkipped, "already exists"
+	case !errors.IsNotFound(err):
+		item.Result, item.Message = ResultFailed, err.Error()
+	case dryRun:
+		item.Result, item.Message = ResultRestored, "dry run"
+	default:
+		if err := c.Create(ctx, secret); err != nil {
+			item.Result, item.Message = ResultFailed, err.Error()
+		} else {
+			item.Result = ResultRestored
+		}
+	}
+	return item
+}
+
+// restoreStateRescue creates a StateRescue resource from the metadata recorded on a replica unless it already exists
+func restoreStateRescue(ctx context.Context, c client.Client, key types.NamespacedName,
+	recorded *terraformv1.StateRescue, dryRun bool) Item {
+	item := Item{Kind: "StateRescue", Namespace: key.Namespace, Name: key.Name}
+	err := c.Get(ctx, key, &terraformv1.StateRescue{})
+	switch {
+	case err == nil:
+		item.Result, item.Message = ResultSkipped, "already exists"
+	case !errors.IsNotFound(err):
+		item.Result, item.Message = ResultFailed, err.Error()
+	case dryRun:
+		item.Result, item.Message = ResultRestored, "dry run"
+	default:
+		stateRescue := &terraformv1.StateRescue{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    recorded.Labels,
+			},
+			Spec: recorded.Spec,
+		}
+		if err := c.Create(ctx, stateRescue); err != nil {
+			item.Result, item.Message = ResultFailed, err.Error()
+		} else {
+			item.Result = ResultRestored
+		}
+	}
+	return item
+}
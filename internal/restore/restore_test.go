@@ -0,0 +1,228 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/controller"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
+)
+
+var _ = Describe("RestoreAll", func() {
+	var (
+		ctx    context.Context
+		scheme *runtime.Scheme
+		local  client.Client
+		remote client.Client
+	)
+
+	replica := func(name, sourceNamespace, stateRescueName string) *corev1.Secret {
+		metadata, err := json.Marshal(&terraformv1.StateRescue{
+			ObjectMeta: metav1.ObjectMeta{Name: stateRescueName, Namespace: sourceNamespace},
+			Spec:       terraformv1.StateRescueSpec{StateSecretName: "tfstate-default-state"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backup-" + name,
+				Namespace: "terraform-dr",
+				Labels: map[string]string{
+					controller.ReplicaLabelKey: "true",
+					"tfstate":                  "false",
+				},
+				Annotations: map[string]string{
+					controller.SourceNamespaceAnnotationKey: sourceNamespace,
+					controller.StateRescueAnnotationKey:     string(metadata),
+				},
+			},
+			Data: map[string][]byte{"tfstate": []byte(name)},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(terraformv1.AddToScheme(scheme)).To(Succeed())
+		local = fake.NewClientBuilder().WithScheme(scheme).Build()
+		remote = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			replica("tfstate-default-state", "team-a", "staterescue-a"),
+			replica("tfstate-dev-state", "team-a", "staterescue-a"),
+			replica("tfstate-default-network", "team-b", "staterescue-b"),
+		).Build()
+	})
+
+	It("should recreate namespaces and state secrets with the Terraform labels", func() {
+		summary, err := RestoreAll(ctx, local, remote, Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Failed()).To(Equal(0))
+		Expect(summary.Items).To(HaveLen(3))
+
+		for _, namespace := range []string{"team-a", "team-b"} {
+			Expect(local.Get(ctx, types.NamespacedName{Name: namespace}, &corev1.Namespace{})).To(Succeed())
+		}
+		secret := &corev1.Secret{}
+		Expect(local.Get(ctx, types.NamespacedName{Name: "tfstate-dev-state", Namespace: "team-a"}, secret)).To(Succeed())
+		Expect(secret.Labels).To(HaveKeyWithValue(controller.TfStateLabelKey, controller.TfStateLabelValue))
+		Expect(secret.Labels).To(HaveKeyWithValue("tfstate", "true"))
+		Expect(secret.Labels).NotTo(HaveKey(controller.ReplicaLabelKey))
+		Expect(secret.Annotations).NotTo(HaveKey(controller.StateRescueAnnotationKey))
+		Expect(secret.Data).To(HaveKeyWithValue("tfstate", []byte("tfstate-dev-state")))
+
+		By("not recreating StateRescue resources unless requested")
+		stateRescues := &terraformv1.StateRescueList{}
+		Expect(local.List(ctx, stateRescues)).To(Succeed())
+		Expect(stateRescues.Items).To(BeEmpty())
+	})
+
+	It("should recreate each StateRescue resource once when requested", func() {
+		summary, err := RestoreAll(ctx, local, remote, Options{RestoreStateRescues: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Failed()).To(Equal(0))
+		Expect(summary.Items).To(HaveLen(5))
+
+		stateRescue := &terraformv1.StateRescue{}
+		Expect(local.Get(ctx, types.NamespacedName{Name: "staterescue-a", Namespace: "team-a"}, stateRescue)).To(Succeed())
+		Expect(stateRescue.Spec.StateSecretName).To(Equal("tfstate-default-state"))
+		Expect(local.Get(ctx, types.NamespacedName{Name: "staterescue-b", Namespace: "team-b"}, stateRescue)).To(Succeed())
+	})
+
+	It("should skip existing state secrets", func() {
+		existing := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "tfstate-default-state", Namespace: "team-a"},
+			Data:       map[string][]byte{"tfstate": []byte("newer")},
+		}
+		Expect(local.Create(ctx, existing)).To(Succeed())
+
+		summary, err := RestoreAll(ctx, local, remote, Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Items).To(ContainElement(Item{Kind: "Secret", Namespace: "team-a",
+			Name: "tfstate-default-state", Result: ResultSkipped, Message: "already exists"}))
+
+		Expect(local.Get(ctx, client.ObjectKeyFromObject(existing), existing)).To(Succeed())
+		Expect(existing.Data).To(HaveKeyWithValue("tfstate", []byte("newer")))
+
+		var out bytes.Buffer
+		Expect(summary.Write(&out)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("2 restored, 1 skipped, 0 failed"))
+	})
+
+	It("should not restore state secrets from redacted replicas", func() {
+		redacted := replica("tfstate-default-redacted", "team-c", "staterescue-c")
+		redacted.Annotations[controller.RedactedAnnotationKey] = "true"
+		Expect(remote.Create(ctx, redacted)).To(Succeed())
+
+		summary, err := RestoreAll(ctx, local, remote, Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Items).To(ContainElement(Item{Kind: "Secret", Namespace: "team-c", Name: "tfstate-default-redacted",
+			Result: ResultSkipped, Message: "replica is redacted and cannot be restored"}))
+		Expect(local.Get(ctx, types.NamespacedName{Name: "tfstate-default-redacted", Namespace: "team-c"},
+			&corev1.Secret{})).NotTo(Succeed())
+	})
+
+	It("should not restore state secrets from redacted states without the redacted annotation", func() {
+		raw, err := state.Redact([]byte(`{"version": 4, "serial": 1, "lineage": "l"}`), nil)
+		Expect(err).NotTo(HaveOccurred())
+		data, err := state.Encode(raw)
+		Expect(err).NotTo(HaveOccurred())
+		redacted := replica("tfstate-default-stripped", "team-c", "staterescue-c")
+		redacted.Data = map[string][]byte{"tfstate": data}
+		Expect(remote.Create(ctx, redacted)).To(Succeed())
+
+		summary, err := RestoreAll(ctx, local, remote, Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Items).To(ContainElement(Item{Kind: "Secret", Namespace: "team-c", Name: "tfstate-default-stripped",
+			Result: ResultSkipped, Message: "replica is redacted and cannot be restored"}))
+		Expect(local.Get(ctx, types.NamespacedName{Name: "tfstate-default-stripped", Namespace: "team-c"},
+			&corev1.Secret{})).NotTo(Succeed())
+	})
+
+	It("should only restore state secrets from replicas that match their signature", func() {
+		signer, err := controller.NewReplicaSigner(terraformv1.SigningHMACSHA256, []byte("signing-key"), "test key")
+		Expect(err).NotTo(HaveOccurred())
+		signed := replica("tfstate-default-signed", "team-c", "staterescue-c")
+		signer.Sign(signed)
+		Expect(remote.Create(ctx, signed)).To(Succeed())
+		tampered := replica("tfstate-default-tampered", "team-c", "staterescue-c")
+		signer.Sign(tampered)
+		tampered.Data = map[string][]byte{"tfstate": []byte("forged")}
+		Expect(remote.Create(ctx, tampered)).To(Succeed())
+
+		summary, err := RestoreAll(ctx, local, remote, Options{Signer: signer, RestoreStateRescues: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Items).To(ContainElement(Item{Kind: "Secret", Namespace: "team-c", Name: "tfstate-default-signed",
+			Result: ResultRestored}))
+		Expect(summary.Items).To(ContainElement(Item{Kind: "Secret", Namespace: "team-c", Name: "tfstate-default-tampered",
+			Result: ResultFailed, Message: "refusing to restore: backup-tfstate-default-tampered does not match its signature"}))
+		Expect(summary.Items).To(ContainElement(Item{Kind: "Secret", Namespace: "team-a", Name: "tfstate-default-state",
+			Result: ResultFailed, Message: "refusing to restore: backup-tfstate-default-state is not signed"}))
+		Expect(local.Get(ctx, types.NamespacedName{Name: "tfstate-default-signed", Namespace: "team-c"},
+			&corev1.Secret{})).To(Succeed())
+		Expect(local.Get(ctx, types.NamespacedName{Name: "tfstate-default-tampered", Namespace: "team-c"},
+			&corev1.Secret{})).NotTo(Succeed())
+		Expect(local.Get(ctx, types.NamespacedName{Name: "tfstate-default-state", Namespace: "team-a"},
+			&corev1.Secret{})).NotTo(Succeed())
+	})
+
+	It("should not restore state secrets from replicas written with signing without a signing key", func() {
+		metadata, err := json.Marshal(&terraformv1.StateRescue{
+			ObjectMeta: metav1.ObjectMeta{Name: "staterescue-c", Namespace: "team-c"},
+			Spec: terraformv1.StateRescueSpec{
+				StateSecretName: "tfstate-default-signed",
+				Signing:         &terraformv1.SigningOptions{KeySecretName: "backup-signing-key"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		signed := replica("tfstate-default-signed", "team-c", "staterescue-c")
+		signed.Annotations[controller.StateRescueAnnotationKey] = string(metadata)
+		Expect(remote.Create(ctx, signed)).To(Succeed())
+
+		summary, err := RestoreAll(ctx, local, remote, Options{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Items).To(ContainElement(Item{Kind: "Secret", Namespace: "team-c", Name: "tfstate-default-signed",
+			Result: ResultFailed, Message: "replica is signed, but no signing key was given to verify it"}))
+		Expect(local.Get(ctx, types.NamespacedName{Name: "tfstate-default-signed", Namespace: "team-c"},
+			&corev1.Secret{})).NotTo(Succeed())
+	})
+
+	It("should not create any objects in dry-run mode", func() {
+		summary, err := RestoreAll(ctx, local, remote, Options{DryRun: true, RestoreStateRescues: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Items).To(HaveLen(5))
+
+		secrets := &corev1.SecretList{}
+		Expect(local.List(ctx, secrets)).To(Succeed())
+		Expect(secrets.Items).To(BeEmpty())
+		namespaces := &corev1.NamespaceList{}
+		Expect(local.List(ctx, namespaces)).To(Succeed())
+		Expect(namespaces.Items).To(BeEmpty())
+	})
+})
@@ -0,0 +1,346 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SensitiveValue replaces sensitive values in diffs
+const SensitiveValue = "(sensitive)"
+
+// ChangeType describes how a resource instance, attribute or output changed between two states
+type ChangeType string
+
+const (
+	// ChangeAdded means that the object only exists in the new state
+	ChangeAdded ChangeType = "Added"
+	// ChangeRemoved means that the object only exists in the old state
+	ChangeRemoved ChangeType = "Removed"
+	// ChangeChanged means that the object exists in both states with different values
+	ChangeChanged ChangeType = "Changed"
+)
+
+// Diff is the difference between two states by resource instance address and output name
+type Diff struct {
+	Resources []ResourceChange
+	Outputs   []ValueChange
+}
+
+// ResourceChange is the change of a resource instance between two states
+type ResourceChange struct {
+	Address string
+	Change  ChangeType
+	// provider of the resource in the old and the new state, only set if the provider changed
+	OldProvider string
+	NewProvider string
+	// changed attributes of a resource instance that exists in both states
+	Attributes []ValueChange
+}
+
+// ValueChange is the change of an attribute or output between two states, sensitive values are masked
+type ValueChange struct {
+	Name     string
+	Change   ChangeType
+	OldValue string
+	NewValue string
+}
+
+// Compare compares two states by resource instance address, attribute and output
+func Compare(oldState, newState *State) *Diff {
+	diff := &Diff{Resources: []ResourceChange{}, Outputs: []ValueChange{}}
+
+	oldInstances, newInstances := oldState.instances(), newState.instances()
+	for _, address := range sortedKeys(oldInstances, newInstances) {
+		oldInstance, inOld := oldInstances[address]
+		newInstance, inNew := newInstances[address]
+		switch {
+		case !inNew:
+			diff.Resources = append(diff.Resources, ResourceChange{Address: address, Change: ChangeRemoved})
+		case !inOld:
+			diff.Resources = append(diff.Resources, ResourceChange{Address: address, Change: ChangeAdded})
+		default:
+			change := ResourceChange{Address: address, Change: ChangeChanged}
+			if oldInstance.provider != newInstance.provider {
+				change.OldProvider, change.NewProvider = oldInstance.provider, newInstance.provider
+			}
+			change.Attributes = compareValues(oldInstance.attributes(), newInstance.attributes(),
+				append(oldInstance.sensitivePaths(), newInstance.sensitivePaths()...))
+			if change.OldProvider != "" || len(change.Attributes) > 0 {
+				diff.Resources = append(diff.Resources, change)
+			}
+		}
+	}
+
+	oldOutputs, newOutputs := oldState.outputs(), newState.outputs()
+	for _, name := range sortedKeys(oldOutputs, newOutputs) {
+		oldOutput, inOld := oldOutputs[name]
+		newOutput, inNew := newOutputs[name]
+		change := ValueChange{Name: name, Change: ChangeChanged}
+		switch {
+		case !inNew:
+			change.Change = ChangeRemoved
+		case !inOld:
+			change.Change = ChangeAdded
+		case bytes.Equal(oldOutput.value, newOutput.value):
+			continue
+		}
+		if inOld {
+			change.OldValue = oldOutput.String()
+		}
+		if inNew {
+			change.NewValue = newOutput.String()
+		}
+		diff.Outputs = append(diff.Outputs, change)
+	}
+	return diff
+}
+
+// Empty reports whether the states do not differ in resources and outputs
+func (d *Diff) Empty() bool {
+	return len(d.Resources) == 0 && len(d.Outputs) == 0
+}
+
+// Summary returns a one line summary of the diff, e.g. "2 added, 1 removed, 3 changed, 1 outputs changed"
+func (d *Diff) Summary() string {
+	counts := map[ChangeType]int{}
+	for _, change := range d.Resources {
+		counts[change.Change]++
+	}
+	return fmt.Sprintf("%d added, %d removed, %d changed, %d outputs changed",
+		counts[ChangeAdded], counts[ChangeRemoved], counts[ChangeChanged], len(d.Outputs))
+}
+
+// Write prints the diff in a format similar to terraform plans
+func (d *Diff) Write(w io.Writer) error {
+	var buf bytes.Buffer
+	symbols := map[ChangeType]string{ChangeAdded: "+", ChangeRemoved: "-", ChangeChanged: "~"}
+	for _, change := range d.Resources {
+		fmt.Fprintf(&buf, "%s %s\n", symbols[change.Change], change.Address)
+		if change.OldProvider != "" {
+			fmt.Fprintf(&buf, "    provider: %s -> %s\n", change.OldProvider, change.NewProvider)
+		}
+		for _, attribute := range change.Attributes {
+			writeValueChange(&buf, symbols, attribute)
+		}
+	}
+	if len(d.Outputs) > 0 {
+		fmt.Fprintln(&buf, "Outputs:")
+		for _, output := range d.Outputs {
+			writeValueChange(&buf, symbols, output)
+		}
+	}
+	fmt.Fprintln(&buf, d.Summary())
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeValueChange prints an attribute or output change below its resource or the outputs heading
+func writeValueChange(buf *bytes.Buffer, symbols map[ChangeType]string, change ValueChange) {
+	switch change.Change {
+	case ChangeAdded:
+		fmt.Fprintf(buf, "  %s %s: %s\n", symbols[change.Change], change.Name, change.NewValue)
+	case ChangeRemoved:
+		fmt.Fprintf(buf, "  %s %s: %s\n", symbols[change.Change], change.Name, change.OldValue)
+	default:
+		fmt.Fprintf(buf, "  %s %s: %s -> %s\n", symbols[change.Change], change.Name, change.OldValue, change.NewValue)
+	}
+}
+
+// instance is a resource instance with the provider of its resource
+type instance struct {
+	*Instance
+	provider string
+}
+
+// instances returns the resource instances of the state by address
+func (s *State) instances() map[string]instance {
+	instances := map[string]instance{}
+	for i := range s.Resources {
+		for j := range s.Resources[i].Instances {
+			instances[s.Resources[i].Instances[j].Address(&s.Resources[i])] = instance{
+				Instance: &s.Resources[i].Instances[j],
+				provider: s.Resources[i].Provider,
+			}
+		}
+	}
+	return instances
+}
+
+// attributes returns the flattened attributes of the instance by path
+func (i *Instance) attributes() map[string]string {
+	values := map[string]string{}
+	var attributes any
+	if err := json.Unmarshal(i.Attributes, &attributes); err == nil {
+		flatten("", attributes, values)
+	}
+	return values
+}
+
+// sensitivePaths returns the paths of the attributes that terraform marked as sensitive, in the format of
+// the flattened attributes. Each path is a list of steps like {"type":"get_attr","value":"password"}.
+func (i *Instance) sensitivePaths() []string {
+	var steps [][]struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(i.SensitiveAttributes, &steps); err != nil {
+		return nil
+	}
+	paths := []string{}
+	for _, path := range steps {
+		var b strings.Builder
+		for _, step := range path {
+			var name string
+			var index struct {
+				Value any `json:"value"`
+			}
+			switch {
+			case step.Type == "get_attr" && json.Unmarshal(step.Value, &name) == nil:
+				b.WriteString("." + name)
+			case step.Type == "index" && json.Unmarshal(step.Value, &index) == nil:
+				if key, ok := index.Value.(string); ok {
+					b.WriteString("." + key)
+				} else {
+					fmt.Fprintf(&b, "[%v]", index.Value)
+				}
+			}
+		}
+		paths = append(paths, strings.TrimPrefix(b.String(), "."))
+	}
+	return paths
+}
+
+// output is an output of the state
+type output struct {
+	value     json.RawMessage
+	sensitive bool
+}
+
+// String returns the output value, masked if the output is sensitive
+func (o output) String() string {
+	if o.sensitive {
+		return SensitiveValue
+	}
+	return string(o.value)
+}
+
+// outputs returns the decoded outputs of the state by name
+func (s *State) outputs() map[string]output {
+	outputs := map[string]output{}
+	for name, raw := range s.Outputs {
+		var o struct {
+			Value     json.RawMessage `json:"value"`
+			Sensitive bool            `json:"sensitive"`
+		}
+		if err := json.Unmarshal(raw, &o); err != nil {
+			continue
+		}
+		// compact the value so that formatting differences are not reported as changes
+		var value bytes.Buffer
+		if err := json.Compact(&value, o.Value); err != nil {
+			continue
+		}
+		outputs[name] = output{value: value.Bytes(), sensitive: o.Sensitive}
+	}
+	return outputs
+}
+
+// compareValues compares flattened attributes, masking the values at or below the sensitive paths
+func compareValues(oldValues, newValues map[string]string, sensitivePaths []string) []ValueChange {
+	changes := []ValueChange{}
+	for _, path := range sortedKeys(oldValues, newValues) {
+		oldValue, inOld := oldValues[path]
+		newValue, inNew := newValues[path]
+		change := ValueChange{Name: path, Change: ChangeChanged, OldValue: oldValue, NewValue: newValue}
+		switch {
+		case !inNew:
+			change.Change = ChangeRemoved
+		case !inOld:
+			change.Change = ChangeAdded
+		case oldValue == newValue:
+			continue
+		}
+		if isSensitive(path, sensitivePaths) {
+			if inOld {
+				change.OldValue = SensitiveValue
+			}
+			if inNew {
+				change.NewValue = SensitiveValue
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// isSensitive reports whether the path is one of the sensitive paths or nested below one of them
+func isSensitive(path string, sensitivePaths []string) bool {
+	for _, sensitive := range sensitivePaths {
+		if path == sensitive || strings.HasPrefix(path, sensitive+".") || strings.HasPrefix(path, sensitive+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten flattens a decoded JSON value into values by path, e.g. tags.Name or ingress[0].cidr_blocks[1]
+func flatten(path string, value any, values map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 && path != "" {
+			values[path] = "{}"
+		}
+		for key, nested := range v {
+			if path == "" {
+				flatten(key, nested, values)
+			} else {
+				flatten(path+"."+key, nested, values)
+			}
+		}
+	case []any:
+		if len(v) == 0 {
+			values[path] = "[]"
+		}
+		for i, nested := range v {
+			flatten(fmt.Sprintf("%s[%d]", path, i), nested, values)
+		}
+	default:
+		encoded, _ := json.Marshal(v)
+		values[path] = string(encoded)
+	}
+}
+
+// sortedKeys returns the union of the keys of two maps in sorted order
+func sortedKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const oldDiffState = `{
+  "version": 4, "serial": 1, "lineage": "l",
+  "outputs": {
+    "vpc_id": {"value": "vpc-1", "type": "string"},
+    "password": {"value": "hunter2", "type": "string", "sensitive": true},
+    "removed": {"value": 1, "type": "number"}
+  },
+  "resources": [
+    {"mode": "managed", "type": "aws_db_instance", "name": "db", "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+     "instances": [{"attributes": {"id": "db-1", "password": "old-secret", "tags": {"env": "dev"}},
+                    "sensitive_attributes": [[{"type": "get_attr", "value": "password"}]]}]},
+    {"mode": "managed", "type": "aws_instance", "name": "web", "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+     "instances": [{"index_key": 0, "attributes": {"id": "i-1"}}]},
+    {"mode": "managed", "type": "random_id", "name": "suffix", "provider": "provider[\"registry.terraform.io/hashicorp/random\"]",
+     "instances": [{"attributes": {"id": "abc"}}]}
+  ]
+}`
+
+const newDiffState = `{
+  "version": 4, "serial": 2, "lineage": "l",
+  "outputs": {
+    "vpc_id": {"value": "vpc-2", "type": "string"},
+    "password": {"value": "hunter3", "type": "string", "sensitive": true}
+  },
+  "resources": [
+    {"mode": "managed", "type": "aws_db_instance", "name": "db", "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+     "instances": [{"attributes": {"id": "db-1", "password": "new-secret", "tags": {"env": "prod", "team": "infra"}},
+                    "sensitive_attributes": [[{"type": "get_attr", "value": "password"}]]}]},
+    {"mode": "managed", "type": "aws_instance", "name": "web", "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+     "instances": [{"index_key": 0, "attributes": {"id": "i-1"}}, {"index_key": 1, "attributes": {"id": "i-2"}}]},
+    {"mode": "managed", "type": "random_id", "name": "suffix", "provider": "provider[\"example.com/acme/random\"]",
+     "instances": [{"attributes": {"id": "abc"}}]}
+  ]
+}`
+
+var _ = Describe("Diff", func() {
+	var diff *Diff
+
+	BeforeEach(func() {
+		oldState, err := Decode([]byte(oldDiffState))
+		Expect(err).NotTo(HaveOccurred())
+		newState, err := Decode([]byte(newDiffState))
+		Expect(err).NotTo(HaveOccurred())
+		diff = Compare(oldState, newState)
+	})
+
+	It("should report added, removed and changed resource instances by address", func() {
+		Expect(diff.Resources).To(HaveLen(3))
+		Expect(diff.Resources[0].Address).To(Equal("aws_db_instance.db"))
+		Expect(diff.Resources[0].Change).To(Equal(ChangeChanged))
+		Expect(diff.Resources[1]).To(Equal(ResourceChange{Address: "aws_instance.web[1]", Change: ChangeAdded}))
+		Expect(diff.Resources[2].Address).To(Equal("random_id.suffix"))
+		Expect(diff.Resources[2].OldProvider).To(Equal(`provider["registry.terraform.io/hashicorp/random"]`))
+		Expect(diff.Resources[2].NewProvider).To(Equal(`provider["example.com/acme/random"]`))
+		Expect(diff.Resources[2].Attributes).To(BeEmpty())
+	})
+
+	It("should report changed attributes with sensitive values masked", func() {
+		Expect(diff.Resources[0].Attributes).To(Equal([]ValueChange{
+			{Name: "password", Change: ChangeChanged, OldValue: SensitiveValue, NewValue: SensitiveValue},
+			{Name: "tags.env", Change: ChangeChanged, OldValue: `"dev"`, NewValue: `"prod"`},
+			{Name: "tags.team", Change: ChangeAdded, NewValue: `"infra"`},
+		}))
+	})
+
+	It("should report changed outputs with sensitive values masked", func() {
+		Expect(diff.Outputs).To(Equal([]ValueChange{
+			{Name: "password", Change: ChangeChanged, OldValue: SensitiveValue, NewValue: SensitiveValue},
+			{Name: "removed", Change: ChangeRemoved, OldValue: "1"},
+			{Name: "vpc_id", Change: ChangeChanged, OldValue: `"vpc-1"`, NewValue: `"vpc-2"`},
+		}))
+	})
+
+	It("should summarize and print the diff without sensitive values", func() {
+		Expect(diff.Empty()).To(BeFalse())
+		Expect(diff.Summary()).To(Equal("1 added, 0 removed, 2 changed, 3 outputs changed"))
+
+		var out bytes.Buffer
+		Expect(diff.Write(&out)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("+ aws_instance.web[1]"))
+		Expect(out.String()).To(ContainSubstring(`  ~ tags.env: "dev" -> "prod"`))
+		Expect(out.String()).NotTo(ContainSubstring("secret"))
+		Expect(out.String()).NotTo(ContainSubstring("hunter"))
+	})
+
+	It("should report no changes between equal states", func() {
+		s, err := Decode([]byte(oldDiffState))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Compare(s, s).Empty()).To(BeTrue())
+	})
+})
@@ -0,0 +1,106 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Encode gzips an uncompressed terraform state as stored by the Kubernetes backend
+func Encode(raw []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewSecret builds the state secret that the Kubernetes backend of terraform would write
+// for the given state, workspace and secret suffix
+func NewSecret(raw []byte, namespace, workspace, suffix string) (*corev1.Secret, error) {
+	// make sure that only valid states are packed
+	s, err := Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	if s.Redacted {
+		return nil, fmt.Errorf("state is redacted and cannot be restored")
+	}
+	data, err := Encode(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("tfstate-%s-%s", workspace, suffix),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "terraform",
+				"tfstate":                      "true",
+				"tfstate_secret_suffix":        suffix,
+				"tfstate_workspace":            workspace,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{SecretKey: data},
+	}, nil
+}
+
+// manifest holds the fields of a secret or list manifest that are needed to find the state in it
+type manifest struct {
+	Kind       string            `json:"kind"`
+	Metadata   metav1.ObjectMeta `json:"metadata"`
+	Data       map[string][]byte `json:"data"`
+	StringData map[string]string `json:"stringData"`
+	Items      []json.RawMessage `json:"items"`
+}
+
+// FromManifest decodes the terraform state in a secret manifest as written by kubectl get -o yaml or -o json.
+// Lists are accepted if they contain a single secret, and data that is no manifest is decoded as a state file,
+// so that plain or gzipped snapshots can be decoded as well.
+func FromManifest(data []byte) (*State, error) {
+	m := &manifest{}
+	if err := yaml.Unmarshal(data, m); err != nil || m.Kind == "" {
+		return Decode(data)
+	}
+	switch m.Kind {
+	case "List", "SecretList":
+		if len(m.Items) != 1 {
+			return nil, fmt.Errorf("expected a single secret in %s but found %d items", m.Kind, len(m.Items))
+		}
+		return FromManifest(m.Items[0])
+	case "Secret":
+		if value, ok := m.StringData[SecretKey]; ok {
+			return Decode([]byte(value))
+		}
+		return FromSecret(&corev1.Secret{ObjectMeta: m.Metadata, Data: m.Data})
+	default:
+		return nil, fmt.Errorf("expected a Secret manifest but found %s", m.Kind)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Manifest", func() {
+	It("should pack a state into a Kubernetes backend secret that decodes to the same state", func() {
+		secret, err := NewSecret([]byte(testState), "terraform", "dev", "state")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.Name).To(Equal("tfstate-dev-state"))
+		Expect(secret.Namespace).To(Equal("terraform"))
+		Expect(secret.Labels).To(HaveKeyWithValue("app.kubernetes.io/managed-by", "terraform"))
+		Expect(secret.Labels).To(HaveKeyWithValue("tfstate_workspace", "dev"))
+		Expect(Workspace(secret)).To(Equal("dev"))
+
+		By("decoding the YAML and JSON manifests of the secret")
+		yamlManifest, err := yaml.Marshal(secret)
+		Expect(err).NotTo(HaveOccurred())
+		jsonManifest, err := json.Marshal(secret)
+		Expect(err).NotTo(HaveOccurred())
+		for _, manifest := range [][]byte{yamlManifest, jsonManifest} {
+			s, err := FromManifest(manifest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(s.Raw)).To(Equal(testState))
+		}
+
+		By("decoding a list with a single secret")
+		list, err := yaml.Marshal(map[string]any{"apiVersion": "v1", "kind": "List", "items": []any{secret}})
+		Expect(err).NotTo(HaveOccurred())
+		s, err := FromManifest(list)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Serial).To(BeEquivalentTo(7))
+	})
+
+	It("should decode plain and gzipped snapshots", func() {
+		for _, data := range [][]byte{gzipped(testState), []byte(testState)} {
+			s, err := FromManifest(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(s.Raw)).To(Equal(testState))
+		}
+	})
+
+	It("should reject invalid states and other kinds of manifests", func() {
+		_, err := NewSecret([]byte("not a state"), "", "default", "state")
+		Expect(err).To(HaveOccurred())
+		_, err = FromManifest([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: state\n"))
+		Expect(err).To(MatchError(ContainSubstring("ConfigMap")))
+		_, err = FromManifest([]byte("apiVersion: v1\nkind: List\nitems: []\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+)
+
+const (
+	// RedactedValue replaces redacted values in states
+	RedactedValue = "REDACTED"
+	// RedactedKey marks redacted states at the top level of the state file, redacted states must not be restored
+	RedactedKey = "tf_state_rescuer_redacted"
+)
+
+// ValidatePatterns checks that the attribute path patterns used for redaction are valid glob patterns
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Redact returns a copy of an uncompressed state in which the values of sensitive outputs, of attributes that
+// terraform marked as sensitive, of attributes whose path matches one of the glob patterns, e.g. *password*
+// or tags.*, and the private provider data of all resource instances are replaced. The copy is marked as
+// redacted so that it is never mistaken for a restorable state.
+func Redact(raw []byte, patterns []string) ([]byte, error) {
+	if err := ValidatePatterns(patterns); err != nil {
+		return nil, err
+	}
+	typed, err := Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	// decode numbers as json.Number so that they are written back unchanged
+	decoder := json.NewDecoder(bytes.NewReader(typed.Raw))
+	decoder.UseNumber()
+	var generic map[string]any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	if outputs, ok := generic["outputs"].(map[string]any); ok {
+		for name, value := range outputs {
+			output, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+			if sensitive, _ := output["sensitive"].(bool); sensitive || matchesAny(name, patterns) {
+				output["value"] = RedactedValue
+			}
+		}
+	}
+	resources, _ := generic["resources"].([]any)
+	for i := range resources {
+		resource, _ := resources[i].(map[string]any)
+		instances, _ := resource["instances"].([]any)
+		for j := range instances {
+			instance, ok := instances[j].(map[string]any)
+			if !ok || i >= len(typed.Resources) || j >= len(typed.Resources[i].Instances) {
+				continue
+			}
+			sensitivePaths := typed.Resources[i].Instances[j].sensitivePaths()
+			if attributes, ok := instance["attributes"]; ok {
+				instance["attributes"] = redactValue("", attributes, sensitivePaths, patterns)
+			}
+			if _, ok := instance["private"]; ok {
+				instance["private"] = RedactedValue
+			}
+		}
+	}
+	generic[RedactedKey] = true
+	return json.Marshal(generic)
+}
+
+// redactValue replaces the value at the given attribute path, or the values nested below it,
+// if the path is sensitive or matches one of the patterns
+func redactValue(attributePath string, value any, sensitivePaths []string, patterns []string) any {
+	if attributePath != "" && (isSensitive(attributePath, sensitivePaths) || matchesAny(attributePath, patterns)) {
+		return RedactedValue
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		for key, nested := range v {
+			nestedPath := key
+			if attributePath != "" {
+				nestedPath = attributePath + "." + key
+			}
+			v[key] = redactValue(nestedPath, nested, sensitivePaths, patterns)
+		}
+	case []any:
+		for i, nested := range v {
+			v[i] = redactValue(fmt.Sprintf("%s[%d]", attributePath, i), nested, sensitivePaths, patterns)
+		}
+	}
+	return value
+}
+
+// matchesAny reports whether the name matches one of the glob patterns
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
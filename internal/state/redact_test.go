@@ -0,0 +1,75 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const sensitiveState = `{
+  "version": 4, "serial": 3, "lineage": "l",
+  "outputs": {
+    "endpoint": {"value": "db.example.com", "type": "string"},
+    "password": {"value": "hunter2", "type": "string", "sensitive": true},
+    "api_token": {"value": "tok-123", "type": "string"}
+  },
+  "resources": [
+    {"mode": "managed", "type": "aws_db_instance", "name": "db", "provider": "aws",
+     "instances": [{"attributes": {"id": "db-1", "password": "old-secret", "port": 5432, "weight": 1.50,
+                                   "tags": {"env": "dev"}, "access": [{"key": "AKIA123"}]},
+                    "sensitive_attributes": [[{"type": "get_attr", "value": "password"}]],
+                    "private": "c2VjcmV0"}]}
+  ]
+}`
+
+var _ = Describe("Redact", func() {
+	It("should replace sensitive values and values matching the patterns", func() {
+		redacted, err := Redact([]byte(sensitiveState), []string{"*token*", "access*.key"})
+		Expect(err).NotTo(HaveOccurred())
+		s, err := Decode(redacted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Redacted).To(BeTrue())
+
+		Expect(string(redacted)).NotTo(ContainSubstring("hunter2"))
+		Expect(string(redacted)).NotTo(ContainSubstring("old-secret"))
+		Expect(string(redacted)).NotTo(ContainSubstring("tok-123"))
+		Expect(string(redacted)).NotTo(ContainSubstring("AKIA123"))
+		Expect(string(redacted)).NotTo(ContainSubstring("c2VjcmV0"))
+
+		By("keeping all other values unchanged")
+		Expect(string(redacted)).To(ContainSubstring(`"weight":1.50`))
+		Expect(string(redacted)).To(ContainSubstring(`"port":5432`))
+		Expect(string(redacted)).To(ContainSubstring(`"db.example.com"`))
+		Expect(string(redacted)).To(ContainSubstring(`"env":"dev"`))
+		Expect(s.Addresses()).To(Equal([]string{"aws_db_instance.db"}))
+	})
+
+	It("should reject invalid patterns", func() {
+		Expect(ValidatePatterns([]string{"*password*", "tags.*"})).To(Succeed())
+		Expect(ValidatePatterns([]string{"[password"})).NotTo(Succeed())
+		_, err := Redact([]byte(sensitiveState), []string{"[password"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should never pack redacted states into state secrets", func() {
+		redacted, err := Redact([]byte(sensitiveState), nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = NewSecret(redacted, "terraform", "default", "state")
+		Expect(err).To(MatchError(ContainSubstring("redacted")))
+	})
+})
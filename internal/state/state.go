@@ -0,0 +1,146 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package state decodes the terraform state stored in secrets by the Kubernetes backend of Terraform.
+package state
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SecretKey is the key of the secret data that the Kubernetes backend stores the gzipped state in
+const SecretKey = "tfstate"
+
+// State is the decoded terraform state, only the fields needed to describe a state are decoded
+type State struct {
+	Version          int                        `json:"version"`
+	TerraformVersion string                     `json:"terraform_version"`
+	Serial           int64                      `json:"serial"`
+	Lineage          string                     `json:"lineage"`
+	Outputs          map[string]json.RawMessage `json:"outputs,omitempty"`
+	Resources        []Resource                 `json:"resources,omitempty"`
+	// Redacted is set on states with redacted values, which must not be restored
+	Redacted bool `json:"tf_state_rescuer_redacted,omitempty"`
+
+	// Raw is the uncompressed state file
+	Raw []byte `json:"-"`
+}
+
+// Resource is a resource of the terraform state
+type Resource struct {
+	Module    string     `json:"module,omitempty"`
+	Mode      string     `json:"mode"`
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Provider  string     `json:"provider"`
+	Instances []Instance `json:"instances"`
+}
+
+// Instance is an instance of a resource of the terraform state
+type Instance struct {
+	IndexKey            any             `json:"index_key,omitempty"`
+	Attributes          json.RawMessage `json:"attributes,omitempty"`
+	SensitiveAttributes json.RawMessage `json:"sensitive_attributes,omitempty"`
+}
+
+// Address returns the address of the resource as used by terraform, e.g. module.network.aws_vpc.main
+func (r *Resource) Address() string {
+	address := r.Type + "." + r.Name
+	if r.Mode == "data" {
+		address = "data." + address
+	}
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	return address
+}
+
+// Address returns the address of the resource instance as used by terraform, e.g. aws_instance.web[0]
+func (i *Instance) Address(resource *Resource) string {
+	switch key := i.IndexKey.(type) {
+	case nil:
+		return resource.Address()
+	case string:
+		return fmt.Sprintf("%s[%q]", resource.Address(), key)
+	default:
+		return fmt.Sprintf("%s[%v]", resource.Address(), key)
+	}
+}
+
+// Addresses returns the addresses of all resource instances in the state
+func (s *State) Addresses() []string {
+	addresses := []string{}
+	for i := range s.Resources {
+		for j := range s.Resources[i].Instances {
+			addresses = append(addresses, s.Resources[i].Instances[j].Address(&s.Resources[i]))
+		}
+	}
+	return addresses
+}
+
+// Decode decodes a terraform state that is either gzipped, as stored by the Kubernetes backend, or plain JSON
+func Decode(data []byte) (*State, error) {
+	raw := data
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress state: %w", err)
+		}
+		raw, err = io.ReadAll(reader)
+		if err == nil {
+			err = reader.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress state: %w", err)
+		}
+	}
+	state := &State{}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+	state.Raw = raw
+	return state, nil
+}
+
+// FromSecret decodes the terraform state stored in a state or backup secret
+func FromSecret(secret *corev1.Secret) (*State, error) {
+	data, ok := secret.Data[SecretKey]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s has no %s key", secret.Namespace, secret.Name, SecretKey)
+	}
+	return Decode(data)
+}
+
+// Workspace returns the terraform workspace of a state secret named tfstate-{workspace}-{secret_suffix},
+// preferring the workspace label set by the Kubernetes backend
+func Workspace(secret *corev1.Secret) string {
+	if workspace := secret.Labels["tfstate_workspace"]; workspace != "" {
+		return workspace
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(secret.Name, "backup-"), "tfstate-")
+	if suffix := secret.Labels["tfstate_secret_suffix"]; suffix != "" {
+		return strings.TrimSuffix(name, "-"+suffix)
+	}
+	workspace, _, _ := strings.Cut(name, "-")
+	return workspace
+}
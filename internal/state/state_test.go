@@ -0,0 +1,96 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testState = `{
+  "version": 4,
+  "terraform_version": "1.9.5",
+  "serial": 7,
+  "lineage": "3f1c2a9e-0c4d-4b8a-9d2e-6a7b8c9d0e1f",
+  "outputs": {"vpc_id": {"value": "vpc-123", "type": "string"}},
+  "resources": [
+    {"mode": "managed", "type": "aws_instance", "name": "web", "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+     "instances": [{"index_key": 0, "attributes": {"id": "i-1"}}, {"index_key": 1, "attributes": {"id": "i-2"}}]},
+    {"module": "module.network", "mode": "data", "type": "aws_vpc", "name": "main", "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+     "instances": [{"attributes": {"id": "vpc-123"}}]},
+    {"mode": "managed", "type": "aws_s3_bucket", "name": "logs", "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+     "instances": [{"index_key": "eu", "attributes": {"id": "logs-eu"}}]}
+  ]
+}`
+
+func gzipped(data string) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(data))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(writer.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+var _ = Describe("State", func() {
+	It("should decode gzipped and plain states", func() {
+		for _, data := range [][]byte{gzipped(testState), []byte(testState)} {
+			s, err := Decode(data)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(s.Serial).To(BeEquivalentTo(7))
+			Expect(s.Lineage).To(Equal("3f1c2a9e-0c4d-4b8a-9d2e-6a7b8c9d0e1f"))
+			Expect(s.TerraformVersion).To(Equal("1.9.5"))
+			Expect(s.Outputs).To(HaveKey("vpc_id"))
+			Expect(string(s.Raw)).To(Equal(testState))
+		}
+	})
+
+	It("should return the addresses of all resource instances", func() {
+		s, err := Decode([]byte(testState))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.Addresses()).To(Equal([]string{
+			"aws_instance.web[0]",
+			"aws_instance.web[1]",
+			"module.network.data.aws_vpc.main",
+			`aws_s3_bucket.logs["eu"]`,
+		}))
+	})
+
+	It("should fail on secrets without state", func() {
+		_, err := FromSecret(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tfstate-default-state"}})
+		Expect(err).To(HaveOccurred())
+		_, err = Decode([]byte("not a state"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should determine the workspace of state and backup secrets", func() {
+		Expect(Workspace(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "backup-tfstate-dev-state"}})).To(Equal("dev"))
+		Expect(Workspace(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:   "tfstate-my-workspace-state",
+			Labels: map[string]string{"tfstate_secret_suffix": "state"},
+		}})).To(Equal("my-workspace"))
+		Expect(Workspace(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:   "tfstate-default-state",
+			Labels: map[string]string{"tfstate_workspace": "staging"},
+		}})).To(Equal("staging"))
+	})
+})
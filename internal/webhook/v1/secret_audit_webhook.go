@@ -0,0 +1,198 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+const (
+	// SecretAuditPath is the path that the secret audit webhook is served at
+	SecretAuditPath = "/audit-v1-secret"
+	// MaxSecretHistory is the number of the most recent secret changes kept in the status of a StateRescue resource
+	MaxSecretHistory = 20
+	// secretAuditQueueSize is the number of secret changes that wait to be recorded before further changes are dropped
+	secretAuditQueueSize = 100
+	// secretAuditTimeout is how long recording a secret change in the status of the StateRescue resources may take
+	secretAuditTimeout = 10 * time.Second
+)
+
+var secretauditlog = logf.Log.WithName("secret-audit")
+
+// SetupSecretAuditWebhookWithManager registers the secret audit webhook in the manager.
+// watchNamespaces are the namespaces watched by the controller manager, all namespaces are watched if empty.
+func SetupSecretAuditWebhookWithManager(mgr ctrl.Manager, watchNamespaces []string) error {
+	auditor := &SecretAuditor{
+		Client:          mgr.GetClient(),
+		APIReader:       mgr.GetAPIReader(),
+		WatchNamespaces: watchNamespaces,
+		changes:         make(chan recordedChange, secretAuditQueueSize),
+	}
+	mgr.GetWebhookServer().Register(SecretAuditPath, &webhook.Admission{Handler: auditor})
+	// the changes are recorded in the background, so that the admission of secret changes is never delayed
+	return mgr.Add(auditor)
+}
+
+// +kubebuilder:webhook:path=/audit-v1-secret,mutating=false,failurePolicy=ignore,sideEffects=NoneOnDryRun,groups="",resources=secrets,verbs=update;delete,versions=v1,name=vsecret-audit.kb.io,admissionReviewVersions=v1
+
+// SecretAuditor records who updates or deletes Terraform state secrets and their backups in the status of the
+// StateRescue resources covering them. It never denies or delays a request, so that auditing cannot block Terraform.
+// The changes are queued and recorded in order once the manager runs the auditor, changes are dropped and logged
+// while the queue is full.
+type SecretAuditor struct {
+	// Client lists the StateRescue resources and updates their status
+	Client client.Client
+	// APIReader reads the StateRescue resources directly from the API server before their status is updated,
+	// so that updates do not conflict because of a stale cache
+	APIReader client.Reader
+	// WatchNamespaces are the namespaces watched by the controller manager, all namespaces are watched if empty
+	WatchNamespaces []string
+
+	// changes are the secret changes that wait to be recorded
+	changes chan recordedChange
+}
+
+// recordedChange is a secret change that waits to be recorded in the StateRescue resources of a namespace
+type recordedChange struct {
+	namespace string
+	change    terraformv1.SecretChange
+}
+
+var _ admission.Handler = &SecretAuditor{}
+var _ manager.Runnable = &SecretAuditor{}
+var _ manager.LeaderElectionRunnable = &SecretAuditor{}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every replica records the requests it admits
+func (a *SecretAuditor) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable and records the queued secret changes until the context is done
+func (a *SecretAuditor) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case queued := <-a.changes:
+			recordCtx, cancel := context.WithTimeout(ctx, secretAuditTimeout)
+			if err := a.record(recordCtx, queued.namespace, queued.change); err != nil {
+				secretauditlog.Error(err, "unable to record the secret change", "namespace", queued.namespace, "name", queued.change.Secret)
+			}
+			cancel()
+		}
+	}
+}
+
+// Handle implements admission.Handler
+func (a *SecretAuditor) Handle(ctx context.Context, req admission.Request) admission.Response {
+	allowed := admission.Allowed("")
+	// dry-run requests change nothing, and the webhook promises to have no side effects for them
+	if req.DryRun != nil && *req.DryRun {
+		return allowed
+	}
+	if len(a.WatchNamespaces) > 0 && !slices.Contains(a.WatchNamespaces, req.Namespace) {
+		return allowed
+	}
+
+	oldSecret := &corev1.Secret{}
+	if err := json.Unmarshal(req.OldObject.Raw, oldSecret); err != nil {
+		secretauditlog.Error(err, "unable to decode the secret", "namespace", req.Namespace, "name", req.Name)
+		return allowed
+	}
+	if !labels.SelectorFromSet(labels.Set(tfStateLabels)).Matches(labels.Set(oldSecret.Labels)) {
+		return allowed
+	}
+	// only changes of the data of state secrets are recorded, e.g. not labels or the updates of backups by the controller
+	if req.Operation == admissionv1.Update {
+		if strings.HasPrefix(req.Name, "backup-") {
+			return allowed
+		}
+		newSecret := &corev1.Secret{}
+		if err := json.Unmarshal(req.Object.Raw, newSecret); err != nil {
+			secretauditlog.Error(err, "unable to decode the secret", "namespace", req.Namespace, "name", req.Name)
+			return allowed
+		}
+		if equality.Semantic.DeepEqual(oldSecret.Data, newSecret.Data) {
+			return allowed
+		}
+	}
+
+	change := terraformv1.SecretChange{
+		Secret:    req.Name,
+		Operation: string(req.Operation),
+		User:      req.UserInfo.Username,
+		Groups:    req.UserInfo.Groups,
+		Time:      metav1.Now(),
+	}
+	select {
+	case a.changes <- recordedChange{namespace: req.Namespace, change: change}:
+	default:
+		secretauditlog.Info("dropping the secret change since too many changes wait to be recorded",
+			"namespace", req.Namespace, "name", req.Name, "operation", req.Operation, "user", change.User)
+	}
+	return allowed
+}
+
+// record appends the change to the secret history of the StateRescue resources covering the secret
+func (a *SecretAuditor) record(ctx context.Context, namespace string, change terraformv1.SecretChange) error {
+	stateRescues := &terraformv1.StateRescueList{}
+	if err := a.Client.List(ctx, stateRescues, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	// backups are covered by the StateRescue resources of their original secrets
+	original := strings.TrimPrefix(change.Secret, "backup-")
+	for _, item := range stateRescues.Items {
+		if !item.DeletionTimestamp.IsZero() || !strings.HasPrefix(original, item.Spec.StateSecretName) {
+			continue
+		}
+		key := client.ObjectKeyFromObject(&item)
+		if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			stateRescue := &terraformv1.StateRescue{}
+			if err := a.APIReader.Get(ctx, key, stateRescue); err != nil {
+				return err
+			}
+			history := append(stateRescue.Status.SecretHistory, change)
+			if len(history) > MaxSecretHistory {
+				history = history[len(history)-MaxSecretHistory:]
+			}
+			stateRescue.Status.SecretHistory = history
+			return a.Client.Status().Update(ctx, stateRescue)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
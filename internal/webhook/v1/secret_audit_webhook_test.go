@@ -0,0 +1,114 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Secret Audit Webhook", func() {
+	Context("When a Terraform state secret is changed or deleted", func() {
+		It("Should record who changed and deleted it in the status of the StateRescue object", func() {
+			By("creating a StateRescue object and its state secret")
+			stateRescue := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "audited",
+					Namespace: "default",
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: "tfstate-audited-state",
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateRescue)).To(Succeed())
+			stateSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "tfstate-audited-state",
+					Namespace: "default",
+					Labels:    map[string]string{"app.kubernetes.io/managed-by": "terraform", "tfstate": "true"},
+				},
+				Data: map[string][]byte{"tfstate": []byte("serial 1")},
+			}
+			Expect(k8sClient.Create(ctx, stateSecret)).To(Succeed())
+
+			By("updating the labels and then the data of the state secret")
+			stateSecret.Labels["team"] = "web"
+			Expect(k8sClient.Update(ctx, stateSecret)).To(Succeed())
+			stateSecret.Data["tfstate"] = []byte("serial 2")
+			Expect(k8sClient.Update(ctx, stateSecret)).To(Succeed())
+
+			By("deleting the state secret")
+			Expect(k8sClient.Delete(ctx, stateSecret)).To(Succeed())
+
+			By("checking the secret history in the status of the StateRescue object")
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(stateRescue), stateRescue)).To(Succeed())
+				g.Expect(stateRescue.Status.SecretHistory).To(HaveLen(2))
+				g.Expect(stateRescue.Status.SecretHistory[0].Operation).To(Equal("UPDATE"))
+				g.Expect(stateRescue.Status.SecretHistory[1].Operation).To(Equal("DELETE"))
+				for _, change := range stateRescue.Status.SecretHistory {
+					g.Expect(change.Secret).To(Equal("tfstate-audited-state"))
+					g.Expect(change.User).NotTo(BeEmpty())
+				}
+			}).Should(Succeed())
+
+			By("cleaning up the objects")
+			Expect(k8sClient.Delete(ctx, stateRescue)).To(Succeed())
+		})
+	})
+
+	Context("When secret changes cannot be recorded as fast as they are admitted", func() {
+		It("Should admit the changes without waiting for them to be recorded", func() {
+			auditor := &SecretAuditor{Client: k8sClient, APIReader: k8sClient, changes: make(chan recordedChange, 1)}
+			raw, err := json.Marshal(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "tfstate-queued-state",
+					Namespace: "default",
+					Labels:    map[string]string{"app.kubernetes.io/managed-by": "terraform", "tfstate": "true"},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Name:      "tfstate-queued-state",
+				Namespace: "default",
+				Operation: admissionv1.Delete,
+				UserInfo:  authenticationv1.UserInfo{Username: "ci"},
+				OldObject: runtime.RawExtension{Raw: raw},
+			}}
+
+			By("queueing the first change and dropping the next one while the auditor is not running")
+			Expect(auditor.Handle(context.Background(), req).Allowed).To(BeTrue())
+			Expect(auditor.Handle(context.Background(), req).Allowed).To(BeTrue())
+			Expect(auditor.changes).To(HaveLen(1))
+			queued := <-auditor.changes
+			Expect(queued.namespace).To(Equal("default"))
+			Expect(queued.change.User).To(Equal("ci"))
+		})
+	})
+})
@@ -0,0 +1,132 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+// DefaultedFieldsAnnotationKey records the spec fields of a StateRescue resource that were set from the
+// manager-level defaults as a comma-separated list
+const DefaultedFieldsAnnotationKey = "terraform.hammadzf.github.io/defaulted-fields"
+
+// StateRescueDefaults are the manager-level defaults of the StateRescue spec, they are only applied to fields
+// that are not set in a StateRescue resource. Since unset and false cannot be told apart, boolean fields can
+// only be defaulted to true.
+type StateRescueDefaults struct {
+	DeletionPolicy         terraformv1.DeletionPolicy         `json:"deletionPolicy,omitempty"`
+	DryRun                 bool                               `json:"dryRun,omitempty"`
+	DiffSummary            bool                               `json:"diffSummary,omitempty"`
+	Generations            int32                              `json:"generations,omitempty"`
+	Destination            *terraformv1.BackupDestination     `json:"destination,omitempty"`
+	Signing                *terraformv1.SigningOptions        `json:"signing,omitempty"`
+	ReinitializationPolicy terraformv1.ReinitializationPolicy `json:"reinitializationPolicy,omitempty"`
+	Protection             *terraformv1.ProtectionOptions     `json:"protection,omitempty"`
+}
+
+// StateRescueDefaultsSource provides the manager-level defaults of the StateRescue spec, e.g. from a configuration
+// file that is reloaded on change
+type StateRescueDefaultsSource interface {
+	// StateRescueDefaults returns the current defaults, no defaults are applied if nil
+	StateRescueDefaults() *StateRescueDefaults
+}
+
+// LoadStateRescueDefaults reads the manager-level defaults of the StateRescue spec from a YAML file,
+// e.g. mounted from a ConfigMap
+func LoadStateRescueDefaults(path string) (*StateRescueDefaults, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defaults := &StateRescueDefaults{}
+	if err := yaml.UnmarshalStrict(data, defaults); err != nil {
+		return nil, fmt.Errorf("invalid StateRescue defaults in %s: %w", path, err)
+	}
+	if err := defaults.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid StateRescue defaults in %s: %w", path, err)
+	}
+	return defaults, nil
+}
+
+// Validate checks the defaults, e.g. after they were read from a file
+func (d *StateRescueDefaults) Validate() error {
+	switch d.DeletionPolicy {
+	case "", terraformv1.DeletionPolicyRetain, terraformv1.DeletionPolicyDelete, terraformv1.DeletionPolicyOrphan:
+	default:
+		return fmt.Errorf("unknown deletion policy %q", d.DeletionPolicy)
+	}
+	if d.Generations < 0 {
+		return fmt.Errorf("generations must not be negative")
+	}
+	switch d.ReinitializationPolicy {
+	case "", terraformv1.ReinitializationPolicyHold, terraformv1.ReinitializationPolicyRestore:
+	default:
+		return fmt.Errorf("unknown reinitialization policy %q", d.ReinitializationPolicy)
+	}
+	if d.Protection != nil && (d.Protection.MaxResourceDropPercent < 0 || d.Protection.MaxResourceDropPercent > 100) {
+		return fmt.Errorf("maxResourceDropPercent must be between 0 and 100")
+	}
+	return nil
+}
+
+// StateRescueDefaults implements StateRescueDefaultsSource with fixed defaults
+func (d *StateRescueDefaults) StateRescueDefaults() *StateRescueDefaults {
+	return d
+}
+
+// apply sets the defaults on the fields of the spec that are not set and returns the names of these fields
+func (d *StateRescueDefaults) apply(spec *terraformv1.StateRescueSpec) []string {
+	applied := []string{}
+	if spec.DeletionPolicy == "" && d.DeletionPolicy != "" {
+		spec.DeletionPolicy = d.DeletionPolicy
+		applied = append(applied, "deletionPolicy")
+	}
+	if !spec.DryRun && d.DryRun {
+		spec.DryRun = true
+		applied = append(applied, "dryRun")
+	}
+	if !spec.DiffSummary && d.DiffSummary {
+		spec.DiffSummary = true
+		applied = append(applied, "diffSummary")
+	}
+	if spec.Generations == 0 && d.Generations > 0 {
+		spec.Generations = d.Generations
+		applied = append(applied, "generations")
+	}
+	if spec.Destination == nil && d.Destination != nil {
+		spec.Destination = d.Destination.DeepCopy()
+		applied = append(applied, "destination")
+	}
+	if spec.Signing == nil && d.Signing != nil {
+		spec.Signing = d.Signing.DeepCopy()
+		applied = append(applied, "signing")
+	}
+	if spec.ReinitializationPolicy == "" && d.ReinitializationPolicy != "" {
+		spec.ReinitializationPolicy = d.ReinitializationPolicy
+		applied = append(applied, "reinitializationPolicy")
+	}
+	if spec.Protection == nil && d.Protection != nil {
+		spec.Protection = d.Protection.DeepCopy()
+		applied = append(applied, "protection")
+	}
+	return applied
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+)
+
+// tfStateLabels select the secrets that the Kubernetes backend of terraform writes
+var tfStateLabels = client.MatchingLabels{"app.kubernetes.io/managed-by": "terraform"}
+
+// validateCrossObject validates the StateRescue resource against the other StateRescue resources in its namespace
+// and the secrets it refers to. Errors are returned for overlapping StateRescue resources and missing secrets that
+// are referenced in the spec, and a warning if no state secret matches the StateRescue resource yet.
+func (v *StateRescueCustomValidator) validateCrossObject(ctx context.Context, sr *terraformv1.StateRescue) (admission.Warnings, field.ErrorList, error) {
+	var warnings admission.Warnings
+	var allErrors field.ErrorList
+
+	// state secrets are matched by prefix, so two StateRescue resources overlap if
+	// the state secret name of one of them is a prefix of the other one
+	stateRescues := &terraformv1.StateRescueList{}
+	if err := v.Client.List(ctx, stateRescues, client.InNamespace(sr.Namespace)); err != nil {
+		return nil, nil, err
+	}
+	for _, item := range stateRescues.Items {
+		if item.Name == sr.Name || !item.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if strings.HasPrefix(sr.Spec.StateSecretName, item.Spec.StateSecretName) ||
+			strings.HasPrefix(item.Spec.StateSecretName, sr.Spec.StateSecretName) {
+			allErrors = append(allErrors, field.Invalid(field.NewPath("spec").Child("stateSecretName"), sr.Spec.StateSecretName,
+				fmt.Sprintf("overlaps with StateRescue %s covering the secrets %s*", item.Name, item.Spec.StateSecretName)))
+		}
+	}
+
+	secrets := &corev1.SecretList{}
+	if err := v.Client.List(ctx, secrets, client.InNamespace(sr.Namespace), tfStateLabels); err != nil {
+		return nil, nil, err
+	}
+	found := false
+	for _, item := range secrets.Items {
+		if strings.HasPrefix(item.Name, sr.Spec.StateSecretName) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		warnings = append(warnings, fmt.Sprintf("no Terraform state secret matching %q exists yet in namespace %q", sr.Spec.StateSecretName, sr.Namespace))
+	}
+
+	if sr.Spec.Destination != nil && sr.Spec.Destination.RemoteCluster != nil {
+		ref := sr.Spec.Destination.RemoteCluster.KubeconfigSecretRef
+		key := ref.Key
+		if key == "" {
+			key = terraformv1.DefaultKubeconfigKey
+		}
+		path := field.NewPath("spec", "destination", "remoteCluster", "kubeconfigSecretRef")
+		fieldErr, err := v.validateSecretKey(ctx, sr.Namespace, ref.Name, key, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fieldErr != nil {
+			allErrors = append(allErrors, fieldErr)
+		}
+	}
+	if sr.Spec.Signing != nil {
+		key := sr.Spec.Signing.Key
+		if key == "" {
+			key = terraformv1.DefaultSigningKey
+		}
+		path := field.NewPath("spec", "signing", "keySecretName")
+		fieldErr, err := v.validateSecretKey(ctx, sr.Namespace, sr.Spec.Signing.KeySecretName, key, path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fieldErr != nil {
+			allErrors = append(allErrors, fieldErr)
+		}
+	}
+	return warnings, allErrors, nil
+}
+
+// validateSecretKey checks that the referenced secret exists and contains the key, it returns a field error
+// if not and an error if the secret cannot be read
+func (v *StateRescueCustomValidator) validateSecretKey(ctx context.Context, namespace, name, key string, path *field.Path) (*field.Error, error) {
+	secret := &corev1.Secret{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return field.NotFound(path, name), nil
+		}
+		return nil, err
+	}
+	if _, ok := secret.Data[key]; !ok {
+		return field.Invalid(path, name, fmt.Sprintf("secret does not contain the key %q", key)), nil
+	}
+	return nil, nil
+}
@@ -19,19 +19,25 @@ package v1
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"slices"
 	"strings"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	validationutils "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	"github.com/hammadzf/tf-state-rescuer/internal/state"
 )
 
 // nolint:unused
@@ -39,12 +45,62 @@ import (
 var staterescuelog = logf.Log.WithName("staterescue-resource")
 
 // SetupStateRescueWebhookWithManager registers the webhook for StateRescue in the manager.
-func SetupStateRescueWebhookWithManager(mgr ctrl.Manager) error {
+// watchNamespaces are the namespaces watched by the controller manager, all namespaces are watched if empty.
+// defaults provides the manager-level defaults of the StateRescue spec, no defaults are applied if nil.
+func SetupStateRescueWebhookWithManager(mgr ctrl.Manager, watchNamespaces []string, defaults StateRescueDefaultsSource) error {
 	return ctrl.NewWebhookManagedBy(mgr).For(&terraformv1.StateRescue{}).
-		WithValidator(&StateRescueCustomValidator{}).
+		WithValidator(&StateRescueCustomValidator{WatchNamespaces: watchNamespaces, Client: mgr.GetAPIReader()}).
+		WithDefaulter(&StateRescueCustomDefaulter{Defaults: defaults}).
 		Complete()
 }
 
+// +kubebuilder:webhook:path=/mutate-terraform-hammadzf-github-io-v1-staterescue,mutating=true,failurePolicy=fail,sideEffects=None,groups=terraform.hammadzf.github.io,resources=staterescues,verbs=create;update,versions=v1,name=mstaterescue-v1.kb.io,admissionReviewVersions=v1
+
+// StateRescueCustomDefaulter struct is responsible for setting default values on the custom resource of the
+// Kind StateRescue when those are created.
+//
+// NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
+// as it is used only for temporary operations and does not need to be deeply copied.
+type StateRescueCustomDefaulter struct {
+	// Defaults provides the manager-level defaults of the StateRescue spec, no defaults are applied if nil
+	Defaults StateRescueDefaultsSource
+}
+
+var _ webhook.CustomDefaulter = &StateRescueCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind StateRescue.
+func (d *StateRescueCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	staterescue, ok := obj.(*terraformv1.StateRescue)
+	if !ok {
+		return fmt.Errorf("expected a StateRescue object but got %T", obj)
+	}
+	staterescuelog.Info("Defaulting for StateRescue", "name", staterescue.GetName())
+
+	if d.Defaults == nil {
+		return nil
+	}
+	// defaults are only applied on creation, so that fields cleared or set to their zero value later are kept,
+	// e.g. dryRun: false or a removed destination
+	if req, err := admission.RequestFromContext(ctx); err != nil || req.Operation != admissionv1.Create {
+		return nil
+	}
+	// the defaults are read on every request, since they may be reloaded while the manager is running
+	defaults := d.Defaults.StateRescueDefaults()
+	if defaults == nil {
+		return nil
+	}
+	applied := defaults.apply(&staterescue.Spec)
+	if len(applied) == 0 {
+		return nil
+	}
+	slices.Sort(applied)
+	if staterescue.Annotations == nil {
+		staterescue.Annotations = map[string]string{}
+	}
+	staterescue.Annotations[DefaultedFieldsAnnotationKey] = strings.Join(applied, ",")
+	return nil
+}
+
 // +kubebuilder:webhook:path=/validate-terraform-hammadzf-github-io-v1-staterescue,mutating=false,failurePolicy=fail,sideEffects=None,groups=terraform.hammadzf.github.io,resources=staterescues,verbs=create;update,versions=v1,name=vstaterescue-v1.kb.io,admissionReviewVersions=v1
 
 // StateRescueCustomValidator struct is responsible for validating the StateRescue resource
@@ -53,30 +109,44 @@ func SetupStateRescueWebhookWithManager(mgr ctrl.Manager) error {
 // NOTE: The +kubebuilder:object:generate=false marker prevents controller-gen from generating DeepCopy methods,
 // as this struct is used only for temporary operations and does not need to be deeply copied.
 type StateRescueCustomValidator struct {
+	// WatchNamespaces are the namespaces watched by the controller manager, all namespaces are watched if empty
+	WatchNamespaces []string
+	// Client reads the StateRescue resources and secrets that a StateRescue resource is validated against,
+	// only the StateRescue resource itself is validated if nil
+	Client client.Reader
 }
 
 var _ webhook.CustomValidator = &StateRescueCustomValidator{}
 
 // ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type StateRescue.
-func (v *StateRescueCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+func (v *StateRescueCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	staterescue, ok := obj.(*terraformv1.StateRescue)
 	if !ok {
 		return nil, fmt.Errorf("expected a StateRescue object but got %T", obj)
 	}
 	staterescuelog.Info("Validation for StateRescue upon creation", "name", staterescue.GetName())
 
-	return nil, validateStateRescue(staterescue)
+	return v.validate(ctx, staterescue)
 }
 
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type StateRescue.
-func (v *StateRescueCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+func (v *StateRescueCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
 	staterescue, ok := newObj.(*terraformv1.StateRescue)
 	if !ok {
 		return nil, fmt.Errorf("expected a StateRescue object for the newObj but got %T", newObj)
 	}
+	oldStaterescue, ok := oldObj.(*terraformv1.StateRescue)
+	if !ok {
+		return nil, fmt.Errorf("expected a StateRescue object for the oldObj but got %T", oldObj)
+	}
 	staterescuelog.Info("Validation for StateRescue upon update", "name", staterescue.GetName())
 
-	return nil, validateStateRescue(staterescue)
+	// the controller updates the metadata of StateRescue objects, e.g. to remove its finalizer, which must
+	// not be rejected because of other objects, so these are only validated against if the spec changed
+	if equality.Semantic.DeepEqual(oldStaterescue.Spec, staterescue.Spec) {
+		return v.warningsForStateRescue(staterescue), invalidStateRescue(staterescue, validateStateRescue(staterescue))
+	}
+	return v.validate(ctx, staterescue)
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type StateRescue.
@@ -92,14 +162,41 @@ func (v *StateRescueCustomValidator) ValidateDelete(ctx context.Context, obj run
 	return nil, nil
 }
 
-func validateStateRescue(sr *terraformv1.StateRescue) error {
-	var allErrors field.ErrorList
-	if err := validateStateRescueName(sr); err != nil {
-		allErrors = append(allErrors, err)
+// warningsForStateRescue warns about StateRescue objects that are created in namespaces that are
+// not watched by the controller manager, since such objects are never reconciled
+func (v *StateRescueCustomValidator) warningsForStateRescue(sr *terraformv1.StateRescue) admission.Warnings {
+	if v.watches(sr.Namespace) {
+		return nil
 	}
-	if err := validateStateRescueSpec(sr); err != nil {
-		allErrors = append(allErrors, err)
+	return admission.Warnings{
+		fmt.Sprintf("namespace %q is not watched by the controller manager, the StateRescue resource will not be managed", sr.Namespace),
 	}
+}
+
+// watches reports whether the namespace is watched by the controller manager
+func (v *StateRescueCustomValidator) watches(namespace string) bool {
+	return len(v.WatchNamespaces) == 0 || slices.Contains(v.WatchNamespaces, namespace)
+}
+
+// validate validates the StateRescue resource itself and, if the validator has a client and its namespace
+// is watched, against the other StateRescue resources and the secrets it refers to
+func (v *StateRescueCustomValidator) validate(ctx context.Context, sr *terraformv1.StateRescue) (admission.Warnings, error) {
+	warnings := v.warningsForStateRescue(sr)
+	allErrors := validateStateRescue(sr)
+	// other objects can only be read in the namespaces watched by the controller manager
+	if v.Client != nil && v.watches(sr.Namespace) {
+		crossWarnings, crossErrors, err := v.validateCrossObject(ctx, sr)
+		if err != nil {
+			return warnings, apierrors.NewInternalError(err)
+		}
+		warnings = append(warnings, crossWarnings...)
+		allErrors = append(allErrors, crossErrors...)
+	}
+	return warnings, invalidStateRescue(sr, allErrors)
+}
+
+// invalidStateRescue returns the Invalid error for the field errors of a StateRescue object, or nil if there are none
+func invalidStateRescue(sr *terraformv1.StateRescue, allErrors field.ErrorList) error {
 	if len(allErrors) == 0 {
 		return nil
 	}
@@ -109,6 +206,21 @@ func validateStateRescue(sr *terraformv1.StateRescue) error {
 	)
 }
 
+func validateStateRescue(sr *terraformv1.StateRescue) field.ErrorList {
+	var allErrors field.ErrorList
+	if err := validateStateRescueName(sr); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	if err := validateStateRescueSpec(sr); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	if err := validateRedactOptions(sr); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	allErrors = append(allErrors, validateHolds(sr)...)
+	return allErrors
+}
+
 func validateStateRescueName(sr *terraformv1.StateRescue) *field.Error {
 	// Name of an object whose kind/resource is defined by a CRD must also be a valid DNS subdomain name
 	// (https://kubernetes.io/docs/concepts/extend-kubernetes/api-extension/custom-resources/#customresourcedefinitions)
@@ -126,12 +238,63 @@ func validateStateRescueSpec(sr *terraformv1.StateRescue) *field.Error {
 	// The secret name in the StateRescue spec must follow the format `tfstate-{workspace}-{secret_suffix}`
 	// to conform with the nomenclature that Terraform uses for naming secrets containing state file data
 	// (https://developer.hashicorp.com/terraform/language/backend/kubernetes#configuration-variables)
-	sp := strings.Split(sr.Spec.StateSecretName, "-")
-	if len(sp) < 3 {
-		return field.Invalid(field.NewPath("spec").Child("stateSecretName"), sr.Spec.StateSecretName, "does not match the format 'tfstate-{workspace}-{secret_suffix}'")
+	path := field.NewPath("spec").Child("stateSecretName")
+	name := sr.Spec.StateSecretName
+	if errs := validationutils.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return field.Invalid(path, name, strings.Join(errs, "; "))
+	}
+	rest, found := strings.CutPrefix(name, "tfstate-")
+	if !found {
+		return field.Invalid(path, name, "does not match the format 'tfstate-{workspace}-{secret_suffix}'")
 	}
-	if sp[0] != "tfstate" {
-		return field.Invalid(field.NewPath("spec").Child("stateSecretName"), sr.Spec.StateSecretName, "does not match the format 'tfstate-{workspace}-{secret_suffix}'")
+	// both the workspace and the secret suffix may contain dashes, so the name is valid
+	// if it can be split into a valid workspace name and secret suffix at any of them
+	for i := range rest {
+		if rest[i] == '-' && validWorkspaceName(rest[:i]) && validSecretSuffix(rest[i+1:]) {
+			return nil
+		}
+	}
+	return field.Invalid(path, name, "does not match the format 'tfstate-{workspace}-{secret_suffix}' with a valid Terraform workspace name and secret suffix")
+}
+
+// validWorkspaceName reports whether the workspace name is valid for terraform, which requires it
+// to be unchanged by URL path escaping, and for the label that the Kubernetes backend records it in
+func validWorkspaceName(workspace string) bool {
+	return workspace != "" && url.PathEscape(workspace) == workspace && len(validationutils.IsValidLabelValue(workspace)) == 0
+}
+
+// validSecretSuffix reports whether the secret suffix is valid for the label that the Kubernetes backend records it in
+func validSecretSuffix(suffix string) bool {
+	return suffix != "" && len(validationutils.IsValidLabelValue(suffix)) == 0
+}
+
+func validateRedactOptions(sr *terraformv1.StateRescue) *field.Error {
+	// The attribute patterns of redacted destinations must be valid glob patterns
+	if sr.Spec.Destination == nil || sr.Spec.Destination.RemoteCluster == nil || sr.Spec.Destination.RemoteCluster.Redact == nil {
+		return nil
+	}
+	patterns := sr.Spec.Destination.RemoteCluster.Redact.AttributePatterns
+	if err := state.ValidatePatterns(patterns); err != nil {
+		return field.Invalid(field.NewPath("spec", "destination", "remoteCluster", "redact", "attributePatterns"), patterns, err.Error())
 	}
 	return nil
 }
+
+func validateHolds(sr *terraformv1.StateRescue) field.ErrorList {
+	// Every hold selects snapshots either by the serial of their states or by a tag, which is the name of a label
+	var allErrors field.ErrorList
+	for i, hold := range sr.Spec.Holds {
+		path := field.NewPath("spec", "holds").Index(i)
+		if (hold.Serial != nil) == (hold.Tag != "") {
+			allErrors = append(allErrors, field.Invalid(path, hold.Name, "exactly one of serial and tag must be set"))
+			continue
+		}
+		if hold.Tag == "" {
+			continue
+		}
+		if errs := validationutils.IsQualifiedName(terraformv1.SnapshotTagLabelPrefix + hold.Tag); len(errs) != 0 {
+			allErrors = append(allErrors, field.Invalid(path.Child("tag"), hold.Tag, strings.Join(errs, "; ")))
+		}
+	}
+	return allErrors
+}
@@ -17,11 +17,21 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	terraformv1alpha2 "github.com/hammadzf/tf-state-rescuer/api/v1alpha2"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 var _ = Describe("StateRescue Webhook", func() {
@@ -98,6 +108,393 @@ var _ = Describe("StateRescue Webhook", func() {
 			}
 			Expect(validator.ValidateUpdate(ctx, validObj, newValidObj)).To(BeNil())
 		})
+		It("Should deny creation of StateRescue object if its redaction patterns are invalid", func() {
+			By("simulating creation of StateRescue object with an invalid attribute pattern")
+			invalidSpecObj = &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "valid-name",
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: "tfstate-default-state",
+					Destination: &terraformv1.BackupDestination{
+						RemoteCluster: &terraformv1.RemoteClusterDestination{
+							KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: "kubeconfig"},
+							Redact:              &terraformv1.RedactOptions{AttributePatterns: []string{"[password"}},
+						},
+					},
+				},
+			}
+			Expect(validator.ValidateCreate(ctx, invalidSpecObj)).Error().To(HaveOccurred())
+
+			invalidSpecObj.Spec.Destination.RemoteCluster.Redact.AttributePatterns = []string{"*password*"}
+			Expect(validator.ValidateCreate(ctx, invalidSpecObj)).To(BeNil())
+		})
+		It("Should deny creation of StateRescue object if its holds do not select snapshots by either serial or tag", func() {
+			By("simulating creation of StateRescue object with a hold selecting snapshots by both serial and tag")
+			serial := int64(42)
+			invalidSpecObj = &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "valid-name",
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: "tfstate-default-state",
+					Holds:           []terraformv1.SnapshotHold{{Name: "audit", Serial: &serial, Tag: "pre-upgrade"}},
+				},
+			}
+			Expect(validator.ValidateCreate(ctx, invalidSpecObj)).Error().To(HaveOccurred())
+
+			By("simulating creation of StateRescue object with a hold selecting snapshots by an invalid tag")
+			invalidSpecObj.Spec.Holds[0].Serial = nil
+			invalidSpecObj.Spec.Holds[0].Tag = "pre upgrade"
+			Expect(validator.ValidateCreate(ctx, invalidSpecObj)).Error().To(HaveOccurred())
+
+			invalidSpecObj.Spec.Holds[0].Tag = "pre-upgrade"
+			Expect(validator.ValidateCreate(ctx, invalidSpecObj)).To(BeNil())
+		})
 	})
 
+	Context("When the controller manager only watches some namespaces", func() {
+		It("Should warn that a StateRescue object outside of the watched namespaces is not managed", func() {
+			By("simulating creation of StateRescue object in a namespace that is not watched")
+			namespacedValidator := StateRescueCustomValidator{WatchNamespaces: []string{"terraform"}}
+			unmanagedObj := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "valid-name",
+					Namespace: "default",
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: "tfstate-default-state",
+				},
+			}
+			warnings, err := namespacedValidator.ValidateCreate(ctx, unmanagedObj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(HaveLen(1))
+
+			By("simulating creation of StateRescue object in a watched namespace")
+			unmanagedObj.Namespace = "terraform"
+			Expect(namespacedValidator.ValidateCreate(ctx, unmanagedObj)).To(BeNil())
+		})
+	})
+
+	Context("When the controller manager has defaults for the StateRescue spec", func() {
+		It("Should apply the defaults to unset fields and record them in an annotation", func() {
+			By("loading the defaults from a file")
+			path := filepath.Join(GinkgoT().TempDir(), "defaults.yaml")
+			Expect(os.WriteFile(path, []byte(`
+deletionPolicy: Retain
+generations: 3
+destination:
+  remoteCluster:
+    kubeconfigSecretRef:
+      name: dr-kubeconfig
+reinitializationPolicy: Restore
+protection:
+  maxResourceDropPercent: 50
+`), 0o600)).To(Succeed())
+			defaults, err := LoadStateRescueDefaults(path)
+			Expect(err).NotTo(HaveOccurred())
+			defaulter := StateRescueCustomDefaulter{Defaults: defaults}
+			requestContext := func(operation admissionv1.Operation) context.Context {
+				return admission.NewContextWithRequest(ctx, admission.Request{
+					AdmissionRequest: admissionv1.AdmissionRequest{Operation: operation},
+				})
+			}
+
+			By("simulating creation of StateRescue object that sets the deletion policy itself")
+			obj := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "valid-name",
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: "tfstate-default-state",
+					DeletionPolicy:  terraformv1.DeletionPolicyDelete,
+				},
+			}
+			Expect(defaulter.Default(requestContext(admissionv1.Create), obj)).To(Succeed())
+			Expect(obj.Spec.DeletionPolicy).To(Equal(terraformv1.DeletionPolicyDelete))
+			Expect(obj.Spec.Generations).To(BeEquivalentTo(3))
+			Expect(obj.Spec.Destination.RemoteCluster.KubeconfigSecretRef.Name).To(Equal("dr-kubeconfig"))
+			Expect(obj.Spec.ReinitializationPolicy).To(Equal(terraformv1.ReinitializationPolicyRestore))
+			Expect(obj.Spec.Protection.MaxResourceDropPercent).To(BeEquivalentTo(50))
+			Expect(obj.Annotations).To(HaveKeyWithValue(DefaultedFieldsAnnotationKey,
+				"destination,generations,protection,reinitializationPolicy"))
+
+			By("simulating an update that opts out of the defaults")
+			obj.Spec.DeletionPolicy = ""
+			obj.Spec.Generations = 0
+			obj.Spec.Destination = nil
+			obj.Spec.ReinitializationPolicy = ""
+			obj.Spec.Protection = nil
+			Expect(defaulter.Default(requestContext(admissionv1.Update), obj)).To(Succeed())
+			Expect(obj.Spec.DeletionPolicy).To(BeEmpty())
+			Expect(obj.Spec.Generations).To(BeZero())
+			Expect(obj.Spec.Destination).To(BeNil())
+			Expect(obj.Spec.ReinitializationPolicy).To(BeEmpty())
+			Expect(obj.Spec.Protection).To(BeNil())
+			Expect(obj.Annotations).To(HaveKeyWithValue(DefaultedFieldsAnnotationKey,
+				"destination,generations,protection,reinitializationPolicy"))
+		})
+		It("Should reject invalid defaults", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "defaults.yaml")
+			Expect(os.WriteFile(path, []byte("deletionPolicy: Keep\n"), 0o600)).To(Succeed())
+			Expect(LoadStateRescueDefaults(path)).Error().To(HaveOccurred())
+			Expect(os.WriteFile(path, []byte("retention: 3\n"), 0o600)).To(Succeed())
+			Expect(LoadStateRescueDefaults(path)).Error().To(HaveOccurred())
+			Expect(os.WriteFile(path, []byte("reinitializationPolicy: Discard\n"), 0o600)).To(Succeed())
+			Expect(LoadStateRescueDefaults(path)).Error().To(HaveOccurred())
+			Expect(os.WriteFile(path, []byte("protection:\n  maxResourceDropPercent: 150\n"), 0o600)).To(Succeed())
+			Expect(LoadStateRescueDefaults(path)).Error().To(HaveOccurred())
+		})
+	})
+
+	Context("When validating the Terraform state secret name of a StateRescue object", func() {
+		It("Should accept workspace names and secret suffixes that contain dashes", func() {
+			for _, name := range []string{"tfstate-default-state", "tfstate-my-workspace-state", "tfstate-default-my-state", "tfstate-prod.eu-state"} {
+				obj := &terraformv1.StateRescue{
+					ObjectMeta: metav1.ObjectMeta{Name: "valid-name"},
+					Spec:       terraformv1.StateRescueSpec{StateSecretName: name},
+				}
+				Expect(validator.ValidateCreate(ctx, obj)).To(BeNil(), name)
+			}
+		})
+		It("Should deny names without a valid workspace name or secret suffix", func() {
+			for _, name := range []string{"tfstate-default", "tfstate--state", "tfstate-default-", "tfstate-Default-state", "tfstate-default_ws-state"} {
+				obj := &terraformv1.StateRescue{
+					ObjectMeta: metav1.ObjectMeta{Name: "valid-name"},
+					Spec:       terraformv1.StateRescueSpec{StateSecretName: name},
+				}
+				Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred(), name)
+			}
+		})
+	})
+
+	Context("When validating a StateRescue object against other objects", func() {
+		It("Should deny overlapping StateRescue objects and references to missing secrets and warn about missing state secrets", func() {
+			crossValidator := StateRescueCustomValidator{Client: k8sClient}
+			newObj := func(name, secretName string) *terraformv1.StateRescue {
+				return &terraformv1.StateRescue{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+					},
+					Spec: terraformv1.StateRescueSpec{
+						StateSecretName: secretName,
+					},
+				}
+			}
+
+			By("creating a StateRescue object for the Terraform state secrets of a workspace")
+			existing := newObj("existing", "tfstate-web-state")
+			Expect(k8sClient.Create(ctx, existing)).To(Succeed())
+
+			By("simulating creation of StateRescue objects covering the same secrets")
+			_, err := crossValidator.ValidateCreate(ctx, newObj("overlapping", "tfstate-web-state-0"))
+			Expect(err).To(MatchError(ContainSubstring("overlaps with StateRescue existing")))
+			_, err = crossValidator.ValidateCreate(ctx, newObj("overlapping", "tfstate-web-st"))
+			Expect(err).To(HaveOccurred())
+			Expect(crossValidator.ValidateUpdate(ctx, existing, existing)).Error().NotTo(HaveOccurred())
+
+			By("simulating creation of a StateRescue object without a matching state secret")
+			apiObj := newObj("api", "tfstate-api-state")
+			var warnings admission.Warnings
+			warnings, err = crossValidator.ValidateCreate(ctx, apiObj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ContainElement(ContainSubstring("no Terraform state secret")))
+
+			By("creating the state secret")
+			stateSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "tfstate-api-state",
+					Namespace: "default",
+					Labels:    map[string]string{"app.kubernetes.io/managed-by": "terraform", "tfstate": "true"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, stateSecret)).To(Succeed())
+			Expect(crossValidator.ValidateCreate(ctx, apiObj)).To(BeNil())
+
+			By("simulating creation of a StateRescue object referring to a missing kubeconfig secret")
+			apiObj.Spec.Destination = &terraformv1.BackupDestination{
+				RemoteCluster: &terraformv1.RemoteClusterDestination{
+					KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: "dr-kubeconfig"},
+				},
+			}
+			Expect(crossValidator.ValidateCreate(ctx, apiObj)).Error().To(HaveOccurred())
+
+			By("creating the kubeconfig secret")
+			kubeconfigSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "dr-kubeconfig",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"kubeconfig": []byte("kubeconfig")},
+			}
+			Expect(k8sClient.Create(ctx, kubeconfigSecret)).To(Succeed())
+			Expect(crossValidator.ValidateCreate(ctx, apiObj)).To(BeNil())
+
+			By("simulating creation of a StateRescue object referring to a missing key of the signing key secret")
+			apiObj.Spec.Signing = &terraformv1.SigningOptions{KeySecretName: "dr-kubeconfig"}
+			Expect(crossValidator.ValidateCreate(ctx, apiObj)).Error().To(HaveOccurred())
+			apiObj.Spec.Signing.Key = "kubeconfig"
+			Expect(crossValidator.ValidateCreate(ctx, apiObj)).To(BeNil())
+
+			By("cleaning up the objects")
+			Expect(k8sClient.Delete(ctx, existing)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, stateSecret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, kubeconfigSecret)).To(Succeed())
+		})
+	})
+
+	Context("When reading and writing StateRescue objects in different API versions", func() {
+		It("Should convert StateRescue objects between v1alpha2 and v1", func() {
+			By("creating a v1alpha2 StateRescue object")
+			alpha := &terraformv1alpha2.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "converted-alpha",
+					Namespace: "default",
+				},
+				Spec: terraformv1alpha2.StateRescueSpec{
+					Targets: []terraformv1alpha2.Target{{SecretName: "tfstate-alpha-state"}},
+					Backup: terraformv1alpha2.BackupSpec{
+						DeletionPolicy: "Retain",
+						Generations:    3,
+					},
+					Rescue: terraformv1alpha2.RescueSpec{Mode: terraformv1alpha2.RescueModeDryRun},
+				},
+			}
+			Expect(k8sClient.Create(ctx, alpha)).To(Succeed())
+
+			By("reading it as a v1 StateRescue object")
+			asV1 := &terraformv1.StateRescue{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(alpha), asV1)).To(Succeed())
+			Expect(asV1.Spec.StateSecretName).To(Equal("tfstate-alpha-state"))
+			Expect(asV1.Spec.DeletionPolicy).To(Equal(terraformv1.DeletionPolicyRetain))
+			Expect(asV1.Spec.Generations).To(Equal(int32(3)))
+			Expect(asV1.Spec.DryRun).To(BeTrue())
+
+			By("creating a v1 StateRescue object")
+			v1Obj := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "converted-v1",
+					Namespace: "default",
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName: "tfstate-v1-state",
+					DiffSummary:     true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, v1Obj)).To(Succeed())
+
+			By("reading it as a v1alpha2 StateRescue object")
+			asAlpha := &terraformv1alpha2.StateRescue{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(v1Obj), asAlpha)).To(Succeed())
+			Expect(asAlpha.Spec.Targets).To(Equal([]terraformv1alpha2.Target{{SecretName: "tfstate-v1-state"}}))
+			Expect(asAlpha.Spec.Backup.DiffSummary).To(BeTrue())
+			Expect(asAlpha.Spec.Rescue.Mode).To(Equal(terraformv1alpha2.RescueModeAuto))
+
+			By("cleaning up the objects")
+			Expect(k8sClient.Delete(ctx, alpha)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, v1Obj)).To(Succeed())
+		})
+
+		It("Should convert every field between v1 and v1alpha2 without loss", func() {
+			now := metav1.NewTime(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+			serial := int64(7)
+			hub := &terraformv1.StateRescue{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "converted-round-trip",
+					Namespace: "default",
+					Labels:    map[string]string{"team": "platform"},
+				},
+				Spec: terraformv1.StateRescueSpec{
+					StateSecretName:        "tfstate-round-trip",
+					DeletionPolicy:         terraformv1.DeletionPolicyOrphan,
+					DryRun:                 true,
+					DiffSummary:            true,
+					Generations:            5,
+					ReinitializationPolicy: terraformv1.ReinitializationPolicyRestore,
+					Holds: []terraformv1.SnapshotHold{
+						{Name: "audit", Serial: &serial, Reason: "audit of serial 7"},
+						{Name: "release", Tag: "v1.2.0"},
+					},
+					Signing: &terraformv1.SigningOptions{
+						Algorithm:     terraformv1.SigningEd25519,
+						KeySecretName: "signing-key",
+						Key:           "private",
+					},
+					Protection: &terraformv1.ProtectionOptions{
+						MaxResourceDropPercent: 25,
+						RequireAcknowledgement: true,
+					},
+					Destination: &terraformv1.BackupDestination{
+						RemoteCluster: &terraformv1.RemoteClusterDestination{
+							KubeconfigSecretRef: terraformv1.SecretKeyReference{Name: "remote", Key: "config"},
+							Namespace:           "replicas",
+							Redact: &terraformv1.RedactOptions{
+								AttributePatterns: []string{"password", "*.secret"},
+							},
+						},
+					},
+				},
+				Status: terraformv1.StateRescueStatus{
+					LastBackupTime:       now,
+					LastRescueTime:       now,
+					LastVerificationTime: now,
+					PlannedActions: []terraformv1.PlannedAction{
+						{Action: terraformv1.ActionCreateBackup, Secret: "tfstate-round-trip"},
+					},
+					RemoteReplication: &terraformv1.RemoteReplicationStatus{
+						LastReplicationTime: now,
+						Lag:                 metav1.Duration{Duration: time.Minute},
+					},
+					SecretHistory: []terraformv1.SecretChange{
+						{Secret: "tfstate-round-trip", Operation: "DELETE", User: "alice", Groups: []string{"admins"}, Time: now},
+					},
+					LastSnapshotRequest: &terraformv1.SnapshotRequest{
+						Tag:       "v1.2.0",
+						Time:      now,
+						Snapshots: []string{"snapshot-tfstate-round-trip-abc"},
+					},
+					HeldSnapshots: []terraformv1.HeldSnapshot{
+						{Snapshot: "snapshot-tfstate-round-trip-abc", Secret: "tfstate-round-trip", Holds: []string{"audit"}},
+					},
+					Conditions: []metav1.Condition{{
+						Type:               terraformv1.ConditionBackupVerified,
+						Status:             metav1.ConditionTrue,
+						Reason:             "Verified",
+						Message:            "1 backups verified",
+						LastTransitionTime: now,
+					}},
+				},
+			}
+
+			By("converting a v1 object to v1alpha2 and back")
+			alpha := &terraformv1alpha2.StateRescue{}
+			Expect(alpha.ConvertFrom(hub.DeepCopy())).To(Succeed())
+			Expect(alpha.Spec.Backup.Holds).To(HaveLen(2))
+			Expect(alpha.Spec.Backup.Signing).NotTo(BeNil())
+			Expect(alpha.Spec.Destination.RemoteCluster.Redact.AttributePatterns).To(Equal([]string{"password", "*.secret"}))
+			Expect(alpha.Spec.Rescue.Mode).To(Equal(terraformv1alpha2.RescueModeDryRun))
+			roundTripped := &terraformv1.StateRescue{}
+			Expect(alpha.DeepCopy().ConvertTo(roundTripped)).To(Succeed())
+			Expect(roundTripped).To(Equal(hub))
+
+			By("converting a v1alpha2 object to v1 and back")
+			converted := &terraformv1.StateRescue{}
+			Expect(alpha.DeepCopy().ConvertTo(converted)).To(Succeed())
+			alphaRoundTripped := &terraformv1alpha2.StateRescue{}
+			Expect(alphaRoundTripped.ConvertFrom(converted)).To(Succeed())
+			Expect(alphaRoundTripped).To(Equal(alpha))
+
+			By("converting a v1alpha2 object in auto mode without a destination")
+			alpha.Spec.Rescue.Mode = terraformv1alpha2.RescueModeAuto
+			alpha.Spec.Destination = nil
+			converted = &terraformv1.StateRescue{}
+			Expect(alpha.DeepCopy().ConvertTo(converted)).To(Succeed())
+			Expect(converted.Spec.DryRun).To(BeFalse())
+			Expect(converted.Spec.Destination).To(BeNil())
+			alphaRoundTripped = &terraformv1alpha2.StateRescue{}
+			Expect(alphaRoundTripped.ConvertFrom(converted)).To(Succeed())
+			Expect(alphaRoundTripped).To(Equal(alpha))
+		})
+	})
 })
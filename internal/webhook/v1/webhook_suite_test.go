@@ -40,6 +40,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	terraformv1 "github.com/hammadzf/tf-state-rescuer/api/v1"
+	terraformv1alpha2 "github.com/hammadzf/tf-state-rescuer/api/v1alpha2"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -68,6 +69,8 @@ var _ = BeforeSuite(func() {
 	var err error
 	err = terraformv1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = terraformv1alpha2.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
 
 	// +kubebuilder:scaffold:scheme
 
@@ -109,7 +112,10 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = SetupStateRescueWebhookWithManager(mgr)
+	err = SetupStateRescueWebhookWithManager(mgr, nil, nil)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = SetupSecretAuditWebhookWithManager(mgr, nil)
 	Expect(err).NotTo(HaveOccurred())
 
 	// +kubebuilder:scaffold:webhook